@@ -0,0 +1,252 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CephConfig holds ceph options by section, for example {"osd": {"osd_max_backfills": "2"}}
+type CephConfig map[string]map[string]string
+
+// The sections of the ceph config that may be set
+var CephConfigSections = []string{"global", "mon", "mgr", "osd", "mds", "client"}
+
+// The ceph.conf defaults of the operator. Options set in the spec replace them.
+var DefaultCephConfig = CephConfig{
+	"global": {
+		"osd_pool_default_pg_autoscale_mode": "warn",
+		"mon_allow_pool_delete":              "false",
+		"osd_pool_default_size":              "2",
+		"osd_pool_default_min_size":          "1",
+	},
+}
+
+// NormalizeCephOption returns the canonical name of an option. Ceph treats spaces,
+// dashes and underscores in option names the same.
+func NormalizeCephOption(name string) string {
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(strings.TrimSpace(name))
+}
+
+// Normalized returns the config with canonical option names
+func (cc CephConfig) Normalized() CephConfig {
+	normalized := CephConfig{}
+	for section, options := range cc {
+		if len(options) == 0 {
+			continue
+		}
+		normalized[section] = map[string]string{}
+		for name, value := range options {
+			normalized[section][NormalizeCephOption(name)] = value
+		}
+	}
+	return normalized
+}
+
+// has returns true if the option is set in any section
+func (cc CephConfig) has(name string) bool {
+	for _, options := range cc {
+		if _, ok := options[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CephConfigOverride renders the defaults of the operator for ceph.conf. The options of the spec
+// are left out: they are set in the ceph config database, which ceph.conf would take precedence over.
+func (kc *KoorCluster) CephConfigOverride() string {
+	spec := kc.Spec.CephConfig.Normalized()
+	var builder strings.Builder
+	for _, section := range CephConfigSections {
+		options := DefaultCephConfig[section]
+		var names []string
+		for name := range options {
+			if !spec.has(name) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		if len(names) == 0 {
+			continue
+		}
+		fmt.Fprintf(&builder, "[%s]\n", section)
+		for _, name := range names {
+			fmt.Fprintf(&builder, "%s = %s\n", name, options[name])
+		}
+	}
+	return builder.String()
+}
+
+// knownCephOptions are the options the webhook accepts. The options of mgr modules,
+// which start with mgr/, are accepted in the mgr section.
+var knownCephOptions = []string{
+	// General
+	"auth_client_required",
+	"auth_cluster_required",
+	"auth_service_required",
+	"cluster_network",
+	"public_network",
+	"debug_mon",
+	"debug_mgr",
+	"debug_osd",
+	"debug_mds",
+	"debug_ms",
+	"debug_rgw",
+	"debug_rbd",
+	"debug_bluestore",
+	"debug_client",
+	"log_to_file",
+	"log_to_stderr",
+	"mon_cluster_log_to_file",
+	"ms_crc_data",
+	"ms_crc_header",
+	"ms_cluster_mode",
+	"ms_service_mode",
+	"ms_client_mode",
+	"rbd_default_features",
+	"rbd_cache",
+	"rbd_cache_size",
+	"rbd_cache_max_dirty",
+	// Monitors
+	"mon_allow_pool_delete",
+	"mon_allow_pool_size_one",
+	"mon_data_avail_warn",
+	"mon_data_avail_crit",
+	"mon_max_pg_per_osd",
+	"mon_osd_down_out_interval",
+	"mon_osd_min_down_reporters",
+	"mon_osd_full_ratio",
+	"mon_osd_nearfull_ratio",
+	"mon_osd_backfillfull_ratio",
+	"mon_pg_warn_min_per_osd",
+	"mon_target_pg_per_osd",
+	"mon_clock_drift_allowed",
+	"mon_warn_on_pool_no_redundancy",
+	"mon_warn_on_insecure_global_id_reclaim",
+	"mon_warn_on_insecure_global_id_reclaim_allowed",
+	"auth_allow_insecure_global_id_reclaim",
+	// Pool defaults
+	"osd_pool_default_size",
+	"osd_pool_default_min_size",
+	"osd_pool_default_pg_num",
+	"osd_pool_default_pgp_num",
+	"osd_pool_default_pg_autoscale_mode",
+	"osd_pool_default_crush_rule",
+	"osd_crush_chooseleaf_type",
+	"osd_crush_update_on_start",
+	// OSDs
+	"osd_max_backfills",
+	"osd_recovery_max_active",
+	"osd_recovery_max_active_hdd",
+	"osd_recovery_max_active_ssd",
+	"osd_recovery_op_priority",
+	"osd_recovery_sleep",
+	"osd_recovery_sleep_hdd",
+	"osd_recovery_sleep_ssd",
+	"osd_mclock_profile",
+	"osd_mclock_override_recovery_settings",
+	"osd_op_queue",
+	"osd_op_num_shards",
+	"osd_op_num_threads_per_shard",
+	"osd_memory_target",
+	"osd_memory_target_autotune",
+	"osd_scrub_begin_hour",
+	"osd_scrub_end_hour",
+	"osd_scrub_begin_week_day",
+	"osd_scrub_end_week_day",
+	"osd_scrub_sleep",
+	"osd_scrub_load_threshold",
+	"osd_scrub_min_interval",
+	"osd_scrub_max_interval",
+	"osd_deep_scrub_interval",
+	"osd_max_scrubs",
+	"osd_heartbeat_grace",
+	"osd_heartbeat_interval",
+	"osd_snap_trim_sleep",
+	"osd_client_message_cap",
+	"bluestore_cache_size",
+	"bluestore_cache_autotune",
+	"bluestore_compression_mode",
+	"bluestore_compression_algorithm",
+	"bluestore_csum_type",
+	"bluestore_min_alloc_size_hdd",
+	"bluestore_min_alloc_size_ssd",
+	"bluestore_prefer_deferred_size_hdd",
+	"bluestore_throttle_bytes",
+	// Managers
+	"mgr_stats_period",
+	"mgr_tick_period",
+	// Metadata servers
+	"mds_cache_memory_limit",
+	"mds_cache_trim_threshold",
+	"mds_max_caps_per_client",
+	"mds_recall_max_caps",
+	"mds_session_blocklist_on_timeout",
+	"mds_session_blocklist_on_evict",
+	// Clients
+	"client_cache_size",
+	"client_oc_size",
+	"client_mount_timeout",
+	"objecter_inflight_ops",
+	"objecter_inflight_op_bytes",
+	"rgw_dns_name",
+	"rgw_enable_usage_log",
+	"rgw_max_chunk_size",
+	"rgw_thread_pool_size",
+}
+
+// isKnownCephOption returns true if the webhook accepts the option in the section
+func isKnownCephOption(section, name string) bool {
+	if section == "mgr" && strings.HasPrefix(name, "mgr/") {
+		return true
+	}
+	return slices.Contains(knownCephOptions, name)
+}
+
+// cephOptionWarning returns why an option value risks data loss or the security of the cluster
+func cephOptionWarning(name, value string) string {
+	switch name {
+	case "auth_client_required", "auth_cluster_required", "auth_service_required":
+		if value == "none" {
+			return "disables the authentication of ceph daemons and clients"
+		}
+	case "mon_allow_pool_delete":
+		if value == "true" {
+			return "allows to delete pools and all of their data"
+		}
+	case "mon_allow_pool_size_one":
+		if value == "true" {
+			return "allows pools without redundancy"
+		}
+	case "osd_pool_default_size", "osd_pool_default_min_size":
+		if value == "1" {
+			return "creates pools that lose data when a single disk fails"
+		}
+	case "ms_crc_data", "ms_crc_header":
+		if value == "false" {
+			return "disables the checksums of network messages"
+		}
+	case "bluestore_csum_type":
+		if value == "none" {
+			return "disables the checksums of stored data"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CephCommand is a read-only ceph command that a KoorCephCommand may run
+// +kubebuilder:validation:Enum=status;health detail;df;osd tree;osd df;osd status;osd pool ls detail;pg stat;mon stat;fs status;versions
+type CephCommand string
+
+// The ceph commands a KoorCephCommand may run. They only read the state of the cluster.
+var ReadOnlyCephCommands = []CephCommand{
+	"status",
+	"health detail",
+	"df",
+	"osd tree",
+	"osd df",
+	"osd status",
+	"osd pool ls detail",
+	"pg stat",
+	"mon stat",
+	"fs status",
+	"versions",
+}
+
+// KoorCephCommandSpec defines the ceph command to run
+type KoorCephCommandSpec struct {
+	// The ceph command without the ceph prefix, for example "osd tree"
+	//+kubebuilder:validation:XValidation:rule="self == oldSelf",message="command is immutable"
+	Command CephCommand `json:"command"`
+	// Runs the command again after the interval, at least 30s. The command runs once if unset.
+	//+kubebuilder:validation:XValidation:rule="duration(self) >= duration('30s')",message="refreshInterval must be at least 30s"
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// The phases of a command
+type CephCommandPhase string
+
+const (
+	// The command ran and its output is in the status
+	CephCommandPhaseSucceeded CephCommandPhase = "Succeeded"
+	// The command failed and is retried
+	CephCommandPhaseFailed CephCommandPhase = "Failed"
+)
+
+// KoorCephCommandStatus defines the observed state of KoorCephCommand
+type KoorCephCommandStatus struct {
+	// The phase of the command
+	Phase CephCommandPhase `json:"phase,omitempty"`
+	// The JSON output of the last successful run
+	//+kubebuilder:validation:Schemaless
+	//+kubebuilder:pruning:PreserveUnknownFields
+	Output *apiextensionsv1.JSON `json:"output,omitempty"`
+	// Why the last run failed
+	Message string `json:"message,omitempty"`
+	// When the command last ran
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Command",type=string,JSONPath=`.spec.command`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Last Run",type=date,JSONPath=`.status.lastRunTime`
+//+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+
+// KoorCephCommand is the Schema for the koorcephcommands API.
+// It runs a read-only ceph command in the toolbox of its namespace.
+type KoorCephCommand struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KoorCephCommandSpec   `json:"spec,omitempty"`
+	Status KoorCephCommandStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KoorCephCommandList contains a list of KoorCephCommand
+type KoorCephCommandList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KoorCephCommand `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KoorCephCommand{}, &KoorCephCommandList{})
+}
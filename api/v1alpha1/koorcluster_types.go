@@ -17,6 +17,13 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -34,14 +41,56 @@ type KoorClusterSpec struct {
 	// Enable monitoring. Requires Prometheus to be pre-installed.
 	//+kubebuilder:default:=true
 	MonitoringEnabled *bool `json:"monitoringEnabled,omitempty"`
+	// The alerts and Grafana dashboards of the operator, created while monitoring is enabled
+	Monitoring MonitoringSpec `json:"monitoring,omitempty"`
 	// Enable the ceph dashboard for viewing cluster status
 	//+kubebuilder:default:=true
 	DashboardEnabled *bool `json:"dashboardEnabled,omitempty"`
+	// Exposes the dashboard outside of the cluster
+	Dashboard DashboardSpec `json:"dashboard,omitempty"`
 	// Installs a debugging toolbox deployment
 	//+kubebuilder:default:=true
 	ToolboxEnabled *bool `json:"toolboxEnabled,omitempty"`
+	// Overrides the image and the placement of the toolbox
+	Toolbox *ToolboxSpec `json:"toolbox,omitempty"`
 	// Specifies the upgrade options for new ceph versions
+	//+kubebuilder:default:={mode:notify}
 	UpgradeOptions UpgradeOptions `json:"upgradeOptions,omitempty"`
+	// The sinks that are notified about upgrades and the health of the cluster
+	//+listType=map
+	//+listMapKey=name
+	Notifications []NotificationSink `json:"notifications,omitempty"`
+	// Specifies the thresholds for storage capacity warnings
+	//+kubebuilder:default:={nearFullPercent:75,poolNearFullPercent:85}
+	CapacityOptions CapacityOptions `json:"capacityOptions,omitempty"`
+	// The RBD block pools. The default pools of the rook-ceph-cluster chart are created if unset.
+	//+listType=map
+	//+listMapKey=name
+	BlockPools []BlockPool `json:"blockPools,omitempty"`
+	// The CephFS filesystems. The default filesystems of the rook-ceph-cluster chart are created if unset.
+	//+listType=map
+	//+listMapKey=name
+	Filesystems []Filesystem `json:"filesystems,omitempty"`
+	// The S3 object stores. The default object stores of the rook-ceph-cluster chart are created if unset.
+	//+listType=map
+	//+listMapKey=name
+	ObjectStores []ObjectStore `json:"objectStores,omitempty"`
+	// The StorageClasses on the block pools and filesystems. Once set, the StorageClasses of the
+	// rook-ceph-cluster chart for the block pools and filesystems in the spec are not created.
+	//+listType=map
+	//+listMapKey=name
+	StorageClasses []StorageClassSpec `json:"storageClasses,omitempty"`
+	// The ceph options by section: global, mon, mgr, osd, mds or client. The operator sets them in the
+	// ceph config database, so the daemons apply changes without a restart. They replace the defaults of the operator.
+	// For example: {"osd": {"osd_max_backfills": "2"}}
+	//+kubebuilder:validation:XValidation:rule="self.all(section, section in ['global', 'mon', 'mgr', 'osd', 'mds', 'client'])",message="the sections must be global, mon, mgr, osd, mds or client"
+	CephConfig CephConfig `json:"cephConfig,omitempty"`
+	// Pins the versions of KSD and ceph. The latest chart and its default ceph image are used if unset.
+	Versions *PinnedVersions `json:"versions,omitempty"`
+	// Stops changing the cluster, for example during manual maintenance: the helm releases, versions,
+	// ceph config, StorageClasses, dashboard, monitoring and the actions of annotations.
+	// The status is still updated. The storage.koor.tech/paused: "true" annotation has the same effect.
+	Paused bool `json:"paused,omitempty"`
 	// The name to use for KSD helm release.
 	//+kubebuilder:default:=ksd
 	KsdReleaseName string `json:"ksdReleaseName,omitempty"`
@@ -50,12 +99,13 @@ type KoorClusterSpec struct {
 	KsdClusterReleaseName string `json:"ksdClusterReleaseName,omitempty"`
 }
 
-// +kubebuilder:validation:Enum=disabled;notify;upgrade
+// +kubebuilder:validation:Enum=disabled;notify;approval;upgrade
 type UpgradeMode string
 
 const (
 	UpgradeModeDisabled UpgradeMode = "disabled"
 	UpgradeModeNotify   UpgradeMode = "notify"
+	UpgradeModeApproval UpgradeMode = "approval"
 	UpgradeModeUpgrade  UpgradeMode = "upgrade"
 )
 
@@ -66,18 +116,436 @@ type UpgradeOptions struct {
 	// The api endpoint used to find the ceph latest version
 	//+kubebuilder:default:="https://versions.koor.tech"
 	Endpoint string `json:"endpoint,omitempty"`
+	// The connection to the version service
+	VersionService VersionServiceOptions `json:"versionService,omitempty"`
 	// The schedule to check for new versions. Uses CRON format as specified by https://github.com/robfig/cron/tree/v3.
 	// Defaults to everyday at midnight in the local timezone.
 	// To change the timezone, prefix the schedule with CRON_TZ=<Timezone>.
 	// For example: "CRON_TZ=UTC 0 0 * * *" is midnight UTC.
 	//+kubebuilder:default:="0 0 * * *"
 	Schedule string `json:"schedule,omitempty"`
+	// The checks that must pass before the KSD or ceph version is changed
+	//+kubebuilder:default:={minAvailablePercent:20}
+	Preflight PreflightOptions `json:"preflight,omitempty"`
+	// The windows in which the KSD or ceph version may be changed.
+	// Versions are changed at any time if no windows are set.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// The upgrade approved in approval mode. It must match the pending upgrade in the status exactly.
+	ApprovedUpgrade *UpgradeTarget `json:"approvedUpgrade,omitempty"`
+	// The checks that must pass after an upgrade
+	//+kubebuilder:default:={timeout:"30m"}
+	Verification VerificationOptions `json:"verification,omitempty"`
+}
+
+// +kubebuilder:validation:XValidation:rule="!(has(self.publicKey) && has(self.publicKeySecret))",message="set either publicKey or publicKeySecret"
+// +kubebuilder:validation:XValidation:rule="!(has(self.allowUnsigned) && self.allowUnsigned && (has(self.publicKey) || has(self.publicKeySecret)))",message="allowUnsigned ignores publicKey and publicKeySecret"
+type VersionServiceOptions struct {
+	// The timeout of a request to the version service
+	//+kubebuilder:default:="30s"
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// How often a failed request is retried, with an exponential backoff
+	//+kubebuilder:default:=3
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=10
+	Retries *int32 `json:"retries,omitempty"`
+	// The Secret with the CA bundle in ca.crt that verifies the version service, in addition to the
+	// system roots, and optionally a client certificate in tls.crt and tls.key for mutual TLS
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// The HTTP proxy to connect through, e.g. http://proxy.example.com:3128.
+	// Defaults to the HTTPS_PROXY and NO_PROXY environment variables of the operator.
+	ProxyURL string `json:"proxyURL,omitempty"`
+	// The PEM encoded Ed25519 or ECDSA public key that signs the responses of the version service and
+	// the offline catalog. Required unless allowUnsigned is set.
+	// Versions without a valid signature are rejected.
+	PublicKey string `json:"publicKey,omitempty"`
+	// The Secret key with the public key, instead of publicKey
+	PublicKeySecret *corev1.SecretKeySelector `json:"publicKeySecret,omitempty"`
+	// Accepts versions without a valid signature, e.g. from a self-hosted version service that does
+	// not sign its responses. Unsigned versions may be tampered with on the way to the operator.
+	AllowUnsigned bool `json:"allowUnsigned,omitempty"`
+	// The ConfigMap with an offline catalog that is used instead of the endpoint. The catalog.json key holds
+	// a response of the version service and the catalog.json.sig key its base64 encoded signature.
+	CatalogConfigMap string `json:"catalogConfigMap,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=rollback;pause
+type VerificationFailurePolicy string
+
+const (
+	// Roll back the helm releases if no ceph daemon runs the new version yet, otherwise pause
+	VerificationFailurePolicyRollback VerificationFailurePolicy = "rollback"
+	// Keep the new versions and raise a warning
+	VerificationFailurePolicyPause VerificationFailurePolicy = "pause"
+)
+
+// The default verification timeout
+const DefaultVerificationTimeout = 30 * time.Minute
+
+type VerificationOptions struct {
+	// How long the ceph daemons have to reach the new version and pass the checks
+	//+kubebuilder:default:="30m"
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// Require ceph to report HEALTH_OK
+	//+kubebuilder:default:=true
+	RequireHealthOK *bool `json:"requireHealthOK,omitempty"`
+	// Writes and reads test data after the upgrade
+	SmokeTest SmokeTestOptions `json:"smokeTest,omitempty"`
+	// What to do when the verification fails
+	//+kubebuilder:default:=pause
+	FailurePolicy VerificationFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// VerificationTimeout returns the verification timeout.
+// An unset timeout, e.g. of objects created before the default, uses the default timeout.
+func (vo VerificationOptions) VerificationTimeout() time.Duration {
+	if vo.Timeout.Duration <= 0 {
+		return DefaultVerificationTimeout
+	}
+	return vo.Timeout.Duration
+}
+
+type SmokeTestOptions struct {
+	// The block pool to write a test RBD image to. The RBD test is skipped if unset.
+	//+kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.][-a-zA-Z0-9_.]*$`
+	BlockPool string `json:"blockPool,omitempty"`
+	// The object store to write a test S3 object to. The S3 test is skipped if unset.
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	ObjectStore string `json:"objectStore,omitempty"`
+}
+
+type UpgradeTarget struct {
+	// The KSD version to upgrade to
+	Ksd string `json:"ksd,omitempty"`
+	// The ceph version to upgrade to
+	Ceph string `json:"ceph,omitempty"`
 }
 
 func (uo UpgradeOptions) IsEnabled() bool {
 	return uo.Mode != UpgradeModeDisabled
 }
 
+type ToolboxSpec struct {
+	// The toolbox image. Defaults to the pinned ceph image, then to the ceph image
+	// the cluster runs, then to the default image of the ceph version the cluster runs.
+	Image string `json:"image,omitempty"`
+	// The tolerations of the toolbox pod
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// The affinity of the toolbox pod
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// The resources of the toolbox container
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// ToolboxImage returns the image of the toolbox, so that its ceph CLI matches the cluster.
+// It never runs a newer ceph than the cluster. An empty image keeps the default of the rook-ceph-cluster chart.
+func (kc *KoorCluster) ToolboxImage() (string, error) {
+	if kc.Spec.Toolbox != nil && kc.Spec.Toolbox.Image != "" {
+		return kc.Spec.Toolbox.Image, nil
+	}
+	image, err := kc.Spec.Versions.CephImage()
+	if err != nil || image != "" {
+		return image, err
+	}
+	if kc.Status.CephImage != "" {
+		return kc.Status.CephImage, nil
+	}
+	if version := kc.Status.CurrentVersions.Ceph; version != "" {
+		if !strings.HasPrefix(version, "v") {
+			version = "v" + version
+		}
+		return (&PinnedVersions{Ceph: version}).CephImage()
+	}
+	return "", nil
+}
+
+// The default image for ceph versions given as a tag
+const DefaultCephImage = "quay.io/ceph/ceph"
+
+type PinnedVersions struct {
+	// The KSD version. For example: v1.11.1
+	Ksd string `json:"ksd,omitempty"`
+	// The version of the rook-ceph and rook-ceph-cluster charts. Defaults to the KSD version.
+	Chart string `json:"chart,omitempty"`
+	// The ceph image or tag, optionally with a digest.
+	// For example: v17.2.6, quay.io/ceph/ceph:v17.2.6 or v17.2.6@sha256:<digest>
+	Ceph string `json:"ceph,omitempty"`
+}
+
+// ChartVersion returns the pinned chart version or an empty string to use the latest chart
+func (pv *PinnedVersions) ChartVersion() string {
+	if pv == nil {
+		return ""
+	}
+	if pv.Chart != "" {
+		return pv.Chart
+	}
+	return pv.Ksd
+}
+
+// CephImage returns the pinned ceph image. Tags are added to the default ceph image.
+func (pv *PinnedVersions) CephImage() (string, error) {
+	if pv == nil || pv.Ceph == "" {
+		return "", nil
+	}
+	image := pv.Ceph
+	name, _, _ := strings.Cut(image, "@")
+	if !strings.ContainsAny(name, ":/") {
+		image = DefaultCephImage + ":" + image
+	}
+	if _, err := reference.ParseNormalizedNamed(image); err != nil {
+		return "", fmt.Errorf("invalid ceph image %q: %w", pv.Ceph, err)
+	}
+	return image, nil
+}
+
+// CephVersion returns the tag of the pinned ceph image
+func (pv *PinnedVersions) CephVersion() (string, error) {
+	image, err := pv.CephImage()
+	if err != nil || image == "" {
+		return "", err
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	if tagged, ok := named.(reference.Tagged); ok {
+		return tagged.Tag(), nil
+	}
+	return "", nil
+}
+
+type MaintenanceWindow struct {
+	// The start of the window in CRON format. For example: "0 22 * * 6" is Saturday at 22:00.
+	Start string `json:"start"`
+	// How long the window stays open, for example 4h
+	Duration metav1.Duration `json:"duration"`
+	// The IANA timezone of the start time, for example Europe/Berlin
+	//+kubebuilder:default:=UTC
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// Schedule parses the start of the window in its timezone
+func (mw MaintenanceWindow) Schedule() (cron.Schedule, error) {
+	timeZone := mw.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	return cron.ParseStandard(fmt.Sprintf("CRON_TZ=%s %s", timeZone, mw.Start))
+}
+
+type PreflightOptions struct {
+	// Require ceph to report HEALTH_OK
+	//+kubebuilder:default:=true
+	RequireHealthOK *bool `json:"requireHealthOK,omitempty"`
+	// Require that no placement groups are degraded or backfilling
+	//+kubebuilder:default:=true
+	RequireCleanPGs *bool `json:"requireCleanPGs,omitempty"`
+	// Require all OSDs to be up and in
+	//+kubebuilder:default:=true
+	RequireAllOsdsUp *bool `json:"requireAllOsdsUp,omitempty"`
+	// The minimum percentage of the raw capacity that must be available. Set to 0 to disable.
+	//+kubebuilder:default:=20
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=100
+	MinAvailablePercent int32 `json:"minAvailablePercent,omitempty"`
+}
+
+type MonitoringSpec struct {
+	// Creates a PrometheusRule with the ceph alerts of the operator. Requires the Prometheus Operator.
+	//+kubebuilder:default:=true
+	Alerts *bool `json:"alerts,omitempty"`
+	// The labels of the PrometheusRule, so that the rule selector of Prometheus selects it
+	RuleLabels map[string]string `json:"ruleLabels,omitempty"`
+	// The labels added to every alert, for example to route the alerts in Alertmanager
+	AlertLabels map[string]string `json:"alertLabels,omitempty"`
+	// When the alerts fire. The capacity warning uses capacityOptions.nearFullPercent
+	// and the pool quota warning capacityOptions.poolNearFullPercent.
+	AlertThresholds AlertThresholds `json:"alertThresholds,omitempty"`
+	// Creates ConfigMaps with Grafana dashboards for the Grafana sidecar
+	//+kubebuilder:default:=true
+	Dashboards *bool `json:"dashboards,omitempty"`
+	// The labels of the dashboard ConfigMaps that the Grafana sidecar watches
+	//+kubebuilder:default:={"grafana_dashboard":"1"}
+	DashboardLabels map[string]string `json:"dashboardLabels,omitempty"`
+}
+
+type AlertThresholds struct {
+	// How long ceph reports HEALTH_ERR before the critical alert fires
+	//+kubebuilder:default:="5m"
+	HealthErrorFor metav1.Duration `json:"healthErrorFor,omitempty"`
+	// How long ceph reports HEALTH_WARN before the warning fires
+	//+kubebuilder:default:="15m"
+	HealthWarningFor metav1.Duration `json:"healthWarningFor,omitempty"`
+	// How long OSDs are down before the alert fires
+	//+kubebuilder:default:="5m"
+	OsdDownFor metav1.Duration `json:"osdDownFor,omitempty"`
+	// The percentage of the raw capacity in use at which the critical capacity alert fires
+	//+kubebuilder:default:=85
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=100
+	FullPercent int32 `json:"fullPercent,omitempty"`
+}
+
+type CapacityOptions struct {
+	// The percentage of the raw capacity in use at which a NearFull warning event is raised
+	//+kubebuilder:default:=75
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=100
+	NearFullPercent int32 `json:"nearFullPercent,omitempty"`
+	// The percentage of a pool quota in use at which a PoolNearFull warning event is raised
+	//+kubebuilder:default:=85
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=100
+	PoolNearFullPercent int32 `json:"poolNearFullPercent,omitempty"`
+}
+
+// The default capacity thresholds in percent
+const (
+	DefaultNearFullPercent     = 75
+	DefaultPoolNearFullPercent = 85
+)
+
+// Thresholds returns the NearFull and PoolNearFull thresholds.
+// Unset thresholds, e.g. of objects created before the defaults, use the default thresholds.
+func (co CapacityOptions) Thresholds() (nearFull int32, poolNearFull int32) {
+	nearFull, poolNearFull = co.NearFullPercent, co.PoolNearFullPercent
+	if nearFull == 0 {
+		nearFull = DefaultNearFullPercent
+	}
+	if poolNearFull == 0 {
+		poolNearFull = DefaultPoolNearFullPercent
+	}
+	return nearFull, poolNearFull
+}
+
+// PoolSpec describes how a ceph pool stores its data. The fields match the rook pool spec.
+// +kubebuilder:validation:XValidation:rule="has(self.replicated) != has(self.erasureCoded)",message="exactly one of replicated or erasureCoded must be set"
+type PoolSpec struct {
+	// The failure domain across which the copies or chunks are spread, for example host, zone or osd
+	//+kubebuilder:default:=host
+	FailureDomain string `json:"failureDomain,omitempty"`
+	// The device class of the OSDs that store the pool, for example hdd or ssd. All OSDs are used if unset.
+	DeviceClass string `json:"deviceClass,omitempty"`
+	// Stores copies of the data
+	Replicated *ReplicatedSpec `json:"replicated,omitempty"`
+	// Splits the data into data and coding chunks
+	ErasureCoded *ErasureCodedSpec `json:"erasureCoded,omitempty"`
+	// Limits the data stored in the pool
+	Quotas *QuotaSpec `json:"quotas,omitempty"`
+}
+
+// Width returns the number of failure domains the pool spreads its data across
+func (ps *PoolSpec) Width() int32 {
+	switch {
+	case ps.Replicated != nil:
+		return ps.Replicated.Size
+	case ps.ErasureCoded != nil:
+		return ps.ErasureCoded.DataChunks + ps.ErasureCoded.CodingChunks
+	}
+	return 0
+}
+
+type ReplicatedSpec struct {
+	// The number of copies of the data
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default:=3
+	Size int32 `json:"size"`
+}
+
+type ErasureCodedSpec struct {
+	// The number of data chunks
+	//+kubebuilder:validation:Minimum=2
+	DataChunks int32 `json:"dataChunks"`
+	// The number of coding chunks, which is the number of failure domains that may fail
+	//+kubebuilder:validation:Minimum=1
+	CodingChunks int32 `json:"codingChunks"`
+}
+
+type QuotaSpec struct {
+	// The maximum size of the data in the pool, for example 100Gi
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+	// The maximum number of objects in the pool
+	//+kubebuilder:validation:Minimum=1
+	MaxObjects *int64 `json:"maxObjects,omitempty"`
+}
+
+type BlockPool struct {
+	// The name of the pool, which is also the name of its StorageClass
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name     string `json:"name"`
+	PoolSpec `json:",inline"`
+}
+
+// NamedPoolSpec is a filesystem data pool
+type NamedPoolSpec struct {
+	// The name of the pool, which is prefixed with the name of the filesystem
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name     string `json:"name"`
+	PoolSpec `json:",inline"`
+}
+
+// +kubebuilder:validation:XValidation:rule="has(self.metadataPool.replicated)",message="the metadata pool must be replicated"
+// +kubebuilder:validation:XValidation:rule="has(self.dataPools[0].replicated)",message="the first data pool must be replicated"
+type Filesystem struct {
+	// The name of the filesystem, which is also the name of its StorageClass
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// The pool for the filesystem metadata
+	MetadataPool PoolSpec `json:"metadataPool"`
+	// The pools for the file data. The first pool is the default pool and must be replicated.
+	// The StorageClass stores the data in the last pool.
+	//+kubebuilder:validation:MinItems=1
+	DataPools []NamedPoolSpec `json:"dataPools"`
+	// The number of active metadata servers, each with a standby
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default:=1
+	ActiveMDS int32 `json:"activeMDS,omitempty"`
+}
+
+// +kubebuilder:validation:XValidation:rule="has(self.metadataPool.replicated)",message="the metadata pool must be replicated"
+type ObjectStore struct {
+	// The name of the object store, which is also the name of its StorageClass
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// The pool for the bucket indexes and metadata
+	MetadataPool PoolSpec `json:"metadataPool"`
+	// The pool for the objects
+	DataPool PoolSpec `json:"dataPool"`
+	// The number of RGW instances
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default:=1
+	Instances int32 `json:"instances,omitempty"`
+}
+
+// StorageClassSpec describes a StorageClass on a block pool or a filesystem
+// +kubebuilder:validation:XValidation:rule="has(self.blockPool) != has(self.filesystem)",message="exactly one of blockPool or filesystem must be set"
+type StorageClassSpec struct {
+	// The name of the StorageClass
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// The block pool that stores the RBD volumes
+	BlockPool string `json:"blockPool,omitempty"`
+	// The filesystem that stores the CephFS volumes. The volumes use the last data pool
+	// if the filesystem is in the spec, otherwise its default data pool.
+	Filesystem string `json:"filesystem,omitempty"`
+	// Makes the StorageClass the cluster default. Other StorageClasses lose the default flag.
+	Default bool `json:"default,omitempty"`
+	// What happens to the volumes when their claims are deleted
+	//+kubebuilder:validation:Enum=Delete;Retain
+	//+kubebuilder:default:=Delete
+	ReclaimPolicy corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// Allows resizing the volumes
+	//+kubebuilder:default:=true
+	AllowVolumeExpansion *bool `json:"allowVolumeExpansion,omitempty"`
+	// The mount options of the volumes, for example discard
+	MountOptions []string `json:"mountOptions,omitempty"`
+	// Restricts the nodes the volumes may be used on
+	AllowedTopologies []corev1.TopologySelectorTerm `json:"allowedTopologies,omitempty"`
+	// Creates a VolumeSnapshotClass with the name of the StorageClass. Its deletion policy is the reclaim policy.
+	// Requires the VolumeSnapshot CRDs and the snapshot controller.
+	Snapshots bool `json:"snapshots,omitempty"`
+}
+
 // KoorClusterStatus defines the observed state of KoorCluster
 type KoorClusterStatus struct {
 	// The total resources available in the cluster nodes
@@ -86,8 +554,329 @@ type KoorClusterStatus struct {
 	MeetsMinimumResources bool `json:"meetsMinimumResources"`
 	// The current versions of rook and ceph
 	CurrentVersions ProductVersions `json:"currentVersions,omitempty"`
+	// The ceph image the CephCluster runs
+	CephImage string `json:"cephImage,omitempty"`
+	// True while the running daemons report more than one version, e.g. during a rollout
+	MixedVersions bool `json:"mixedVersions,omitempty"`
 	// The latest versions of rook and ceph
 	LatestVersions *DetailedProductVersions `json:"latestVersions,omitempty"`
+	// The upgrade that waits for approval in approval mode
+	PendingUpgrade *UpgradeTarget `json:"pendingUpgrade,omitempty"`
+	// The state of the running or last version upgrade
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+	// The start of the next maintenance window, unset while a window is open
+	NextMaintenanceWindow *metav1.Time `json:"nextMaintenanceWindow,omitempty"`
+	// The state of the rook CephCluster
+	CephCluster *CephClusterStatus `json:"cephCluster,omitempty"`
+	// The nodes that run OSDs, without the decommissioned nodes. Unset while no node is decommissioned.
+	StorageNodes []string `json:"storageNodes,omitempty"`
+	// The nodes removed from the storage nodes by a KoorNodeDecommission. Rook creates no OSDs on them.
+	DecommissionedNodes []string `json:"decommissionedNodes,omitempty"`
+	// The OSDs that ceph reports down
+	DownOsds []DownOsd `json:"downOsds,omitempty"`
+	// The pools that use more of their quota than the PoolNearFull threshold
+	NearFullPools []string `json:"nearFullPools,omitempty"`
+	// The StorageClasses managed by the operator
+	StorageClasses []StorageClassStatus `json:"storageClasses,omitempty"`
+	// How to reach the dashboard
+	Dashboard *DashboardStatus `json:"dashboard,omitempty"`
+	// The options of the spec that the operator set in the ceph config database
+	CephConfig CephConfig `json:"cephConfig,omitempty"`
+	// The deliveries to the notification sinks
+	//+listType=map
+	//+listMapKey=name
+	Notifications []NotificationSinkStatus `json:"notifications,omitempty"`
+	// The latest observations of the KoorCluster state
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Condition types of the KoorCluster
+const (
+	// The preflight checks for a version change passed
+	ConditionPreflightPassed = "PreflightPassed"
+	// A maintenance window is open, so the versions may be changed
+	ConditionInMaintenanceWindow = "InMaintenanceWindow"
+	// The pending upgrade is approved
+	ConditionUpgradeApproved = "UpgradeApproved"
+	// A version upgrade is running
+	ConditionUpgrading = "Upgrading"
+	// The cluster passed the checks after the last upgrade
+	ConditionUpgradeVerified = "UpgradeVerified"
+	// The operator does not change the helm releases and versions
+	ConditionPaused = "Paused"
+	// Ceph reports OSDs down
+	ConditionOsdsDown = "OsdsDown"
+	// The used raw capacity is above the NearFull threshold
+	ConditionNearFull = "NearFull"
+	// The options of the spec are set in the ceph config database
+	ConditionCephConfigApplied = "CephConfigApplied"
+	// The PrometheusRule of the operator exists. False while the Prometheus Operator is not installed.
+	ConditionMonitoringReady = "MonitoringReady"
+	// An upgrade to a version that fixes security vulnerabilities is available
+	ConditionSecurityUpdateAvailable = "SecurityUpdateAvailable"
+)
+
+// The steps of a version upgrade, in the order they are run
+type UpgradeStep string
+
+const (
+	// Upgrade the rook-ceph chart with the KSD operator
+	UpgradeStepOperator UpgradeStep = "UpgradeOperator"
+	// Wait for the rook operator to be ready
+	UpgradeStepWaitForOperator UpgradeStep = "WaitForOperator"
+	// Upgrade the rook-ceph-cluster chart with the ceph image
+	UpgradeStepCeph UpgradeStep = "UpgradeCeph"
+	// Wait for all ceph daemons to run the new version
+	UpgradeStepWaitForDaemons UpgradeStep = "WaitForDaemons"
+	// Check the health of the cluster and run the smoke tests
+	UpgradeStepVerify UpgradeStep = "Verify"
+)
+
+type UpgradeResult string
+
+const (
+	UpgradeResultSucceeded  UpgradeResult = "Succeeded"
+	UpgradeResultFailed     UpgradeResult = "Failed"
+	UpgradeResultRolledBack UpgradeResult = "RolledBack"
+)
+
+type UpgradeStatus struct {
+	// The chart version before the upgrade
+	FromChartVersion string `json:"fromChartVersion,omitempty"`
+	// The chart version to upgrade to
+	ChartVersion string `json:"chartVersion,omitempty"`
+	// The ceph version before the upgrade
+	FromCephVersion string `json:"fromCephVersion,omitempty"`
+	// The pinned or approved ceph version to upgrade to, empty if the chart default is used
+	CephVersion string `json:"cephVersion,omitempty"`
+	// The ceph image of the ceph version to upgrade to
+	CephImage string `json:"cephImage,omitempty"`
+	// The step that is running, empty once the upgrade completed
+	Step UpgradeStep `json:"step,omitempty"`
+	// The percentage of the completed steps
+	Progress int32 `json:"progress"`
+	// When the upgrade started
+	StartedAt metav1.Time `json:"startedAt"`
+	// When the upgrade completed
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// The result of the upgrade once it completed
+	Result UpgradeResult `json:"result,omitempty"`
+	// A summary of the result
+	Message string `json:"message,omitempty"`
+	// The steps of the upgrade with their timings
+	Steps []UpgradeStepStatus `json:"steps,omitempty"`
+}
+
+// InProgress returns true while the upgrade has steps left
+func (us *UpgradeStatus) InProgress() bool {
+	return us != nil && us.Step != ""
+}
+
+type UpgradeStepStatus struct {
+	// The name of the step
+	Name UpgradeStep `json:"name"`
+	// When the step started
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// When the step completed
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// The ceph health as reported by `ceph health`
+type CephHealth string
+
+const (
+	CephHealthOK   CephHealth = "HEALTH_OK"
+	CephHealthWarn CephHealth = "HEALTH_WARN"
+	CephHealthErr  CephHealth = "HEALTH_ERR"
+)
+
+type CephClusterStatus struct {
+	// The name of the CephCluster
+	Name string `json:"name"`
+	// The phase of the CephCluster, e.g. Ready or Progressing
+	Phase string `json:"phase,omitempty"`
+	// The overall ceph health
+	Health CephHealth `json:"health,omitempty"`
+	// The ceph health checks that are currently failing
+	HealthChecks []CephHealthCheck `json:"healthChecks,omitempty"`
+	// The raw storage capacity of the cluster
+	Capacity CephCapacity `json:"capacity,omitempty"`
+	// The usage of each pool
+	Pools []CephPoolUsage `json:"pools,omitempty"`
+	// The number of OSDs by state
+	Osds *CephOsdCounts `json:"osds,omitempty"`
+	// The number of placement groups by state
+	Pgs *CephPgCounts `json:"pgs,omitempty"`
+}
+
+type CephHealthCheck struct {
+	// The name of the health check, e.g. OSD_DOWN
+	Name string `json:"name"`
+	// The severity of the health check
+	Severity CephHealth `json:"severity,omitempty"`
+	// The message reported by ceph
+	Message string `json:"message,omitempty"`
+}
+
+type CephCapacity struct {
+	// Raw storage in total
+	Total *resource.Quantity `json:"total,omitempty"`
+	// Raw storage used
+	Used *resource.Quantity `json:"used,omitempty"`
+	// Raw storage available
+	Available *resource.Quantity `json:"available,omitempty"`
+}
+
+// UsedPercent returns the percentage of the raw capacity in use
+func (c CephCapacity) UsedPercent() (int32, bool) {
+	if c.Total == nil || c.Used == nil || c.Total.IsZero() {
+		return 0, false
+	}
+	return int32(c.Used.Value() * 100 / c.Total.Value()), true
+}
+
+type CephPoolUsage struct {
+	// The name of the pool
+	Name string `json:"name"`
+	// The data stored in the pool, before replication
+	Stored *resource.Quantity `json:"stored,omitempty"`
+	// The data that can still be stored in the pool, before replication
+	MaxAvailable *resource.Quantity `json:"maxAvailable,omitempty"`
+	// The quota of the pool, unset if the pool has no quota
+	Quota *resource.Quantity `json:"quota,omitempty"`
+}
+
+// QuotaUsedPercent returns the percentage of the pool quota in use
+func (p CephPoolUsage) QuotaUsedPercent() (int32, bool) {
+	if p.Quota == nil || p.Stored == nil || p.Quota.IsZero() {
+		return 0, false
+	}
+	return int32(p.Stored.Value() * 100 / p.Quota.Value()), true
+}
+
+type CephOsdCounts struct {
+	// The number of OSDs
+	Total int32 `json:"total"`
+	// The number of OSDs that are running
+	Up int32 `json:"up"`
+	// The number of OSDs that are part of the data placement
+	In int32 `json:"in"`
+}
+
+type CephPgCounts struct {
+	// The number of placement groups
+	Total int32 `json:"total"`
+	// The number of placement groups with fewer copies than configured
+	Degraded int32 `json:"degraded"`
+	// The number of placement groups that are moving data
+	Backfilling int32 `json:"backfilling"`
+}
+
+type StorageClassStatus struct {
+	// The name of the StorageClass
+	Name string `json:"name"`
+	// The CSI driver of the StorageClass
+	Provisioner string `json:"provisioner"`
+	// The block pool or filesystem the StorageClass stores the volumes in
+	Pool string `json:"pool"`
+	// Whether the StorageClass is the cluster default
+	Default bool `json:"default,omitempty"`
+	// The VolumeSnapshotClass for the volumes of the StorageClass
+	VolumeSnapshotClass string `json:"volumeSnapshotClass,omitempty"`
+}
+
+// How the dashboard is exposed
+// +kubebuilder:validation:Enum=None;Ingress;LoadBalancer
+type DashboardExposure string
+
+const (
+	// The dashboard is only reachable inside the cluster
+	DashboardExposureNone DashboardExposure = "None"
+	// An Ingress routes the host to the dashboard
+	DashboardExposureIngress DashboardExposure = "Ingress"
+	// A LoadBalancer Service exposes the dashboard port
+	DashboardExposureLoadBalancer DashboardExposure = "LoadBalancer"
+)
+
+// A cert-manager issuer
+type IssuerReference struct {
+	// The name of the issuer
+	//+kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// The kind of the issuer
+	//+kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	//+kubebuilder:default:=Issuer
+	Kind string `json:"kind,omitempty"`
+}
+
+// DashboardSpec exposes the ceph dashboard
+// +kubebuilder:validation:XValidation:rule="!(has(self.tlsSecretName) && size(self.tlsSecretName) > 0 && has(self.issuer))",message="set either tlsSecretName or issuer"
+type DashboardSpec struct {
+	// How the dashboard is exposed
+	//+kubebuilder:default:=None
+	Expose DashboardExposure `json:"expose,omitempty"`
+	// The host name of the dashboard. Required for an Ingress and a certificate of the issuer.
+	Host string `json:"host,omitempty"`
+	// The IngressClass of the Ingress
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+	// The annotations of the Ingress or the LoadBalancer Service
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// The Secret with the TLS certificate of the dashboard
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// The cert-manager issuer of the TLS certificate of the dashboard
+	Issuer *IssuerReference `json:"issuer,omitempty"`
+	// The URL of the dashboard, for example behind a proxy. Defaults to the URL of the Ingress or the Service.
+	ExternalURL string `json:"externalURL,omitempty"`
+}
+
+// The exposure, defaulting to None
+func (ds *DashboardSpec) Exposure() DashboardExposure {
+	if ds.Expose == "" {
+		return DashboardExposureNone
+	}
+	return ds.Expose
+}
+
+type DashboardStatus struct {
+	// The URL of the dashboard
+	URL string `json:"url,omitempty"`
+	// The user of the dashboard
+	Username string `json:"username,omitempty"`
+	// The Secret key with the password of the user
+	PasswordSecret *corev1.SecretKeySelector `json:"passwordSecret,omitempty"`
+	// When the operator last rotated the password
+	PasswordRotatedAt *metav1.Time `json:"passwordRotatedAt,omitempty"`
+	// The TLS Secret and its version that the operator installed in the dashboard for the LoadBalancer Service
+	InstalledCertificate string `json:"installedCertificate,omitempty"`
+}
+
+type DownOsd struct {
+	// The id of the OSD
+	ID int `json:"id"`
+	// The node of the OSD
+	Node string `json:"node,omitempty"`
+	// The disk of the OSD, for example /dev/sdb
+	Device string `json:"device,omitempty"`
+	// The device id of the disk as reported by ceph, <vendor>_<model>_<serial>
+	DeviceID string `json:"deviceId,omitempty"`
+}
+
+// String describes the OSD for events and conditions
+func (d DownOsd) String() string {
+	description := fmt.Sprintf("osd.%d", d.ID)
+	if d.Node != "" {
+		description += " on node " + d.Node
+	}
+	if d.Device != "" {
+		description += " device " + d.Device
+	}
+	return description
+}
+
+func (s *CephClusterStatus) IsHealthy() bool {
+	return s != nil && s.Health == CephHealthOK
 }
 
 type ProductVersions struct {
@@ -108,14 +897,86 @@ type DetailedProductVersions struct {
 	Ksd *DetailedVersion `json:"ksd,omitempty"`
 	// The detailed version of Ceph
 	Ceph *DetailedVersion `json:"ceph,omitempty"`
+	// When the current versions reach their end of life
+	CurrentEndOfLife *EndOfLifeDates `json:"currentEndOfLife,omitempty"`
+}
+
+// The end of life dates of the products
+type EndOfLifeDates struct {
+	// The end of life of the koor Operator version
+	KoorOperator *metav1.Time `json:"koorOperator,omitempty"`
+	// The end of life of the KSD version
+	Ksd *metav1.Time `json:"ksd,omitempty"`
+	// The end of life of the Ceph version
+	Ceph *metav1.Time `json:"ceph,omitempty"`
 }
 
+// How urgent an update to a version is
+// +kubebuilder:validation:Enum=Routine;Security;Critical
+type VersionSeverity string
+
+const (
+	// The version brings bug fixes and features
+	VersionSeverityRoutine VersionSeverity = "Routine"
+	// The version fixes security vulnerabilities
+	VersionSeveritySecurity VersionSeverity = "Security"
+	// The version fixes critical security vulnerabilities and should be installed right away
+	VersionSeverityCritical VersionSeverity = "Critical"
+)
+
 type DetailedVersion struct {
 	Version        string `json:"version,omitempty"`
 	ImageUri       string `json:"imageUri,omitempty"`
 	ImageHash      string `json:"imageHash,omitempty"`
 	HelmRepository string `json:"helmRepository,omitempty"`
 	HelmChart      string `json:"helmChart,omitempty"`
+	// The version of the helm chart. Defaults to the version.
+	HelmChartVersion string `json:"helmChartVersion,omitempty"`
+	// The release notes of this version
+	ReleaseNotesURL string `json:"releaseNotesUrl,omitempty"`
+	// How urgent the update to this version is. Defaults to Routine.
+	Severity VersionSeverity `json:"severity,omitempty"`
+	// The security advisories fixed by this version, for example CVE-2023-43040
+	Advisories []string `json:"advisories,omitempty"`
+	// When this version reaches its end of life
+	EndOfLife *metav1.Time `json:"endOfLife,omitempty"`
+}
+
+// ChartVersion returns the version of the helm chart that installs this version
+func (dv *DetailedVersion) ChartVersion() string {
+	if dv.HelmChartVersion != "" {
+		return dv.HelmChartVersion
+	}
+	return dv.Version
+}
+
+// IsSecurityUpdate returns true if the version fixes security vulnerabilities
+func (dv *DetailedVersion) IsSecurityUpdate() bool {
+	return dv.Severity == VersionSeveritySecurity || dv.Severity == VersionSeverityCritical
+}
+
+// Image returns the image of the version, pinned to its hash if the version service reports one
+func (dv *DetailedVersion) Image() string {
+	if dv.ImageUri == "" || dv.ImageHash == "" || strings.Contains(dv.ImageUri, "@") {
+		return dv.ImageUri
+	}
+	digest := dv.ImageHash
+	if !strings.Contains(digest, ":") {
+		digest = "sha256:" + digest
+	}
+	return dv.ImageUri + "@" + digest
+}
+
+// VerifyImageDigest returns an error if the image is pinned to a different digest than the version
+func (dv *DetailedVersion) VerifyImageDigest(image string) error {
+	_, digest, ok := strings.Cut(image, "@")
+	if !ok || dv.ImageHash == "" {
+		return nil
+	}
+	if strings.TrimPrefix(digest, "sha256:") != strings.TrimPrefix(dv.ImageHash, "sha256:") {
+		return fmt.Errorf("the digest of image %s does not match the hash %s of version %s", image, dv.ImageHash, dv.Version)
+	}
+	return nil
 }
 
 type Resources struct {
@@ -169,8 +1030,23 @@ func (k *KoorCluster) IsBeingDeleted() bool {
 	return !k.ObjectMeta.DeletionTimestamp.IsZero()
 }
 
+// IsPaused returns true if the spec or the annotation pauses the reconciliation
+func (k *KoorCluster) IsPaused() bool {
+	return k.Spec.Paused || k.Annotations[PausedAnnotation] == "true"
+}
+
 const KoorClusterFinalizerName = "storage.koor.tech/finalizer"
 
+// Setting the annotation to "true" pauses the reconciliation like spec.paused
+const PausedAnnotation = "storage.koor.tech/paused"
+
+// The ids of the OSDs to replace, separated by commas. The operator creates a KoorOsdReplacement
+// for each OSD and removes the annotation.
+const ReplaceOsdsAnnotation = "storage.koor.tech/replace-osds"
+
+// Setting the annotation to "true" rotates the password of the dashboard. The operator removes the annotation.
+const RotateDashboardPasswordAnnotation = "storage.koor.tech/rotate-dashboard-password"
+
 //+kubebuilder:object:root=true
 
 // KoorClusterList contains a list of KoorCluster
@@ -17,6 +17,17 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/docker/distribution/reference"
 	"github.com/robfig/cron/v3"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -55,14 +66,18 @@ var _ webhook.Validator = &KoorCluster{}
 func (r *KoorCluster) ValidateCreate() (admission.Warnings, error) {
 	koorclusterlog.Info("validate create", "name", r.Name)
 
-	return r.validateKoorCluster()
+	return r.validateKoorCluster(nil)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *KoorCluster) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
 	koorclusterlog.Info("validate update", "name", r.Name)
 
-	return r.validateKoorCluster()
+	oldKoorCluster, ok := old.(*KoorCluster)
+	if !ok {
+		return nil, apierrors.NewBadRequest("expected a KoorCluster")
+	}
+	return r.validateKoorCluster(oldKoorCluster)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -73,15 +88,31 @@ func (r *KoorCluster) ValidateDelete() (admission.Warnings, error) {
 	return nil, nil
 }
 
-func (r *KoorCluster) validateKoorCluster() (admission.Warnings, error) {
+func (r *KoorCluster) validateKoorCluster(old *KoorCluster) (admission.Warnings, error) {
 	var allErrs field.ErrorList
 	if err := r.validateUpgradeSchedule(); err != nil {
 		allErrs = append(allErrs, err)
 	}
+	allErrs = append(allErrs, r.validateMaintenanceWindows()...)
+	warnings, errs := r.validateVersions(old)
+	allErrs = append(allErrs, errs...)
+	poolWarnings, errs := r.validatePools()
+	warnings = append(warnings, poolWarnings...)
+	allErrs = append(allErrs, errs...)
+	allErrs = append(allErrs, r.validateStorageClasses()...)
+	if err := r.validateToolbox(); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	allErrs = append(allErrs, r.validateDashboard()...)
+	allErrs = append(allErrs, r.validateNotifications()...)
+	allErrs = append(allErrs, r.validateVersionService()...)
+	configWarnings, errs := r.validateCephConfig()
+	warnings = append(warnings, configWarnings...)
+	allErrs = append(allErrs, errs...)
 	if len(allErrs) == 0 {
-		return nil, nil
+		return warnings, nil
 	}
-	return nil, apierrors.NewInvalid(
+	return warnings, apierrors.NewInvalid(
 		schema.GroupKind{Group: "storage.koor.tech", Kind: "KoorCluster"},
 		r.Name, allErrs)
 }
@@ -97,3 +128,383 @@ func (r *KoorCluster) validateUpgradeSchedule() *field.Error {
 	}
 	return nil
 }
+
+func (r *KoorCluster) validateMaintenanceWindows() field.ErrorList {
+	var allErrs field.ErrorList
+	windowsPath := field.NewPath("spec").Child("upgradeOptions").Child("maintenanceWindows")
+	for i, window := range r.Spec.UpgradeOptions.MaintenanceWindows {
+		if window.TimeZone != "" {
+			if _, err := time.LoadLocation(window.TimeZone); err != nil {
+				allErrs = append(allErrs, field.Invalid(windowsPath.Index(i).Child("timeZone"), window.TimeZone, err.Error()))
+				continue
+			}
+		}
+		if _, err := window.Schedule(); err != nil {
+			allErrs = append(allErrs, field.Invalid(windowsPath.Index(i).Child("start"), window.Start, err.Error()))
+		}
+		if window.Duration.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(windowsPath.Index(i).Child("duration"), window.Duration.String(),
+				"must be greater than zero"))
+		}
+	}
+	return allErrs
+}
+
+// validateVersions checks the pinned versions against the upgrade path of the version service,
+// which the operator records in the status. Pinned versions that did not change are not checked again.
+func (r *KoorCluster) validateVersions(old *KoorCluster) (admission.Warnings, field.ErrorList) {
+	versions := r.Spec.Versions
+	if versions == nil {
+		return nil, nil
+	}
+	var oldVersions PinnedVersions
+	if old != nil && old.Spec.Versions != nil {
+		oldVersions = *old.Spec.Versions
+	}
+
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+	versionsPath := field.NewPath("spec").Child("versions")
+	latest := r.Status.LatestVersions
+	if latest == nil {
+		latest = &DetailedProductVersions{}
+	}
+
+	if versions.Chart != "" {
+		if _, err := semver.NewVersion(versions.Chart); err != nil {
+			allErrs = append(allErrs, field.Invalid(versionsPath.Child("chart"), versions.Chart, err.Error()))
+		}
+	}
+
+	if versions.Ksd != "" && versions.Ksd != oldVersions.Ksd {
+		warning, err := validateUpgradePath(versionsPath.Child("ksd"), versions.Ksd,
+			r.Status.CurrentVersions.Ksd, latest.Ksd)
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	if versions.Ceph != "" && versions.Ceph != oldVersions.Ceph {
+		cephPath := versionsPath.Child("ceph")
+		cephVersion, err := versions.CephVersion()
+		switch {
+		case err != nil:
+			allErrs = append(allErrs, field.Invalid(cephPath, versions.Ceph, err.Error()))
+		case cephVersion == "":
+			warnings = append(warnings, fmt.Sprintf(
+				"The upgrade path to ceph image %s cannot be verified without a tag", versions.Ceph))
+		default:
+			warning, err := validateUpgradePath(cephPath, cephVersion, r.Status.CurrentVersions.Ceph, latest.Ceph)
+			if warning != "" {
+				warnings = append(warnings, warning)
+			}
+			if err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+	}
+
+	return warnings, allErrs
+}
+
+// validateUpgradePath checks that the pinned version is neither older than the running version
+// nor newer than the latest version the version service allows to upgrade to
+func validateUpgradePath(path *field.Path, pinned, current string, latest *DetailedVersion) (string, *field.Error) {
+	pinnedVersion, err := semver.NewVersion(pinned)
+	if err != nil {
+		return "", field.Invalid(path, pinned, err.Error())
+	}
+	if current == "" {
+		// Nothing is installed yet
+		return "", nil
+	}
+	currentVersion, err := semver.NewVersion(current)
+	if err != nil {
+		return fmt.Sprintf("The running version %s cannot be compared to %s", current, pinned), nil
+	}
+
+	if pinnedVersion.LessThan(currentVersion) {
+		return "", field.Forbidden(path, fmt.Sprintf("downgrades from %s to %s are not supported", current, pinned))
+	}
+	if pinnedVersion.Equal(currentVersion) {
+		return "", nil
+	}
+
+	if latest == nil || latest.Version == "" {
+		return fmt.Sprintf("The upgrade from %s to %s cannot be verified until the version service has been queried",
+			current, pinned), nil
+	}
+	latestVersion, err := semver.NewVersion(latest.Version)
+	if err != nil {
+		return fmt.Sprintf("The latest version %s cannot be compared to %s", latest.Version, pinned), nil
+	}
+	if pinnedVersion.GreaterThan(latestVersion) {
+		return "", field.Forbidden(path, fmt.Sprintf("%s skips versions, the version service allows upgrades from %s up to %s",
+			pinned, current, latest.Version))
+	}
+	return "", nil
+}
+
+// storageNodeCount returns the number of nodes rook may create OSDs on, as recorded in the status,
+// or 0 if it is not known yet
+func (r *KoorCluster) storageNodeCount() int32 {
+	if len(r.Status.StorageNodes) > 0 {
+		return int32(len(r.Status.StorageNodes))
+	}
+	if nodes := r.Status.TotalResources.Nodes; nodes != nil {
+		return int32(nodes.Value())
+	}
+	return 0
+}
+
+// validatePools checks that the pools of the block pools, filesystems and object stores
+// fit on the storage nodes when their failure domain is host
+func (r *KoorCluster) validatePools() (admission.Warnings, field.ErrorList) {
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+	nodes := r.storageNodeCount()
+	specPath := field.NewPath("spec")
+
+	for i, pool := range r.Spec.BlockPools {
+		path := specPath.Child("blockPools").Index(i)
+		if pool.ErasureCoded != nil {
+			warnings = append(warnings, fmt.Sprintf(
+				"Block pool %s is erasure coded, its StorageClass is not created because RBD needs a replicated pool for the image metadata",
+				pool.Name))
+		}
+		w, err := validatePool(path, pool.PoolSpec, nodes)
+		warnings = append(warnings, w...)
+		allErrs = append(allErrs, err...)
+	}
+
+	for i, filesystem := range r.Spec.Filesystems {
+		path := specPath.Child("filesystems").Index(i)
+		w, err := validatePool(path.Child("metadataPool"), filesystem.MetadataPool, nodes)
+		warnings = append(warnings, w...)
+		allErrs = append(allErrs, err...)
+		for j, pool := range filesystem.DataPools {
+			w, err := validatePool(path.Child("dataPools").Index(j), pool.PoolSpec, nodes)
+			warnings = append(warnings, w...)
+			allErrs = append(allErrs, err...)
+		}
+	}
+
+	for i, objectStore := range r.Spec.ObjectStores {
+		path := specPath.Child("objectStores").Index(i)
+		w, err := validatePool(path.Child("metadataPool"), objectStore.MetadataPool, nodes)
+		warnings = append(warnings, w...)
+		allErrs = append(allErrs, err...)
+		w, err = validatePool(path.Child("dataPool"), objectStore.DataPool, nodes)
+		warnings = append(warnings, w...)
+		allErrs = append(allErrs, err...)
+	}
+
+	return warnings, allErrs
+}
+
+func validatePool(path *field.Path, pool PoolSpec, nodes int32) (admission.Warnings, field.ErrorList) {
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+
+	if pool.Replicated != nil && pool.Replicated.Size == 1 {
+		warnings = append(warnings, fmt.Sprintf("%s stores a single copy of the data, which is lost if an OSD fails",
+			path.Child("replicated", "size")))
+	}
+	if pool.Quotas != nil && pool.Quotas.MaxSize != nil && pool.Quotas.MaxSize.Sign() <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("quotas", "maxSize"), pool.Quotas.MaxSize.String(),
+			"must be greater than zero"))
+	}
+
+	// Other failure domains cannot be checked against the nodes
+	failureDomain := pool.FailureDomain
+	if failureDomain != "" && failureDomain != "host" {
+		return warnings, allErrs
+	}
+	if width := pool.Width(); nodes > 0 && width > nodes {
+		allErrs = append(allErrs, field.Invalid(path, width, fmt.Sprintf(
+			"the pool spreads its data across %d hosts, but the cluster has %d storage nodes", width, nodes)))
+	}
+	return warnings, allErrs
+}
+
+// validateStorageClasses checks that at most one StorageClass is the default and that the
+// StorageClasses use the block pools and filesystems of the spec, if the spec sets them
+func (r *KoorCluster) validateStorageClasses() field.ErrorList {
+	var allErrs field.ErrorList
+	classesPath := field.NewPath("spec").Child("storageClasses")
+	defaultClass := ""
+	for i, storageClass := range r.Spec.StorageClasses {
+		path := classesPath.Index(i)
+		if storageClass.Default {
+			if defaultClass != "" {
+				allErrs = append(allErrs, field.Invalid(path.Child("default"), true,
+					fmt.Sprintf("%s is already the default StorageClass", defaultClass)))
+			}
+			defaultClass = storageClass.Name
+		}
+
+		if storageClass.BlockPool != "" && len(r.Spec.BlockPools) > 0 {
+			index := slices.IndexFunc(r.Spec.BlockPools, func(pool BlockPool) bool {
+				return pool.Name == storageClass.BlockPool
+			})
+			switch {
+			case index < 0:
+				allErrs = append(allErrs, field.NotFound(path.Child("blockPool"), storageClass.BlockPool))
+			case r.Spec.BlockPools[index].ErasureCoded != nil:
+				allErrs = append(allErrs, field.Invalid(path.Child("blockPool"), storageClass.BlockPool,
+					"RBD volumes need a replicated pool"))
+			}
+		}
+		if storageClass.Filesystem != "" && len(r.Spec.Filesystems) > 0 &&
+			!slices.ContainsFunc(r.Spec.Filesystems, func(filesystem Filesystem) bool {
+				return filesystem.Name == storageClass.Filesystem
+			}) {
+			allErrs = append(allErrs, field.NotFound(path.Child("filesystem"), storageClass.Filesystem))
+		}
+	}
+	return allErrs
+}
+
+func (r *KoorCluster) validateToolbox() *field.Error {
+	if r.Spec.Toolbox == nil || r.Spec.Toolbox.Image == "" {
+		return nil
+	}
+	image := r.Spec.Toolbox.Image
+	if _, err := reference.ParseNormalizedNamed(image); err != nil {
+		return field.Invalid(field.NewPath("spec").Child("toolbox").Child("image"), image, err.Error())
+	}
+	return nil
+}
+
+// validateCephConfig rejects unknown sections and options, and warns about options that risk data loss
+func (r *KoorCluster) validateCephConfig() (admission.Warnings, field.ErrorList) {
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+	configPath := field.NewPath("spec").Child("cephConfig")
+	for section, options := range r.Spec.CephConfig {
+		sectionPath := configPath.Key(section)
+		if !slices.Contains(CephConfigSections, section) {
+			allErrs = append(allErrs, field.NotSupported(sectionPath, section, CephConfigSections))
+			continue
+		}
+		seen := map[string]string{}
+		for name, value := range options {
+			optionPath := sectionPath.Key(name)
+			option := NormalizeCephOption(name)
+			if other, ok := seen[option]; ok {
+				allErrs = append(allErrs, field.Duplicate(optionPath, fmt.Sprintf("%s is the same option as %s", name, other)))
+				continue
+			}
+			seen[option] = name
+			switch {
+			case !isKnownCephOption(section, option):
+				allErrs = append(allErrs, field.Invalid(optionPath, name, "unknown ceph option"))
+			case strings.TrimSpace(value) == "":
+				allErrs = append(allErrs, field.Required(optionPath, "the value must not be empty"))
+			default:
+				if warning := cephOptionWarning(option, value); warning != "" {
+					warnings = append(warnings, fmt.Sprintf("The ceph option %s %s", option, warning))
+				}
+			}
+		}
+	}
+	return warnings, allErrs
+}
+
+func (r *KoorCluster) validateDashboard() field.ErrorList {
+	var allErrs field.ErrorList
+	dashboard := r.Spec.Dashboard
+	dashboardPath := field.NewPath("spec").Child("dashboard")
+	exposure := dashboard.Exposure()
+	if exposure == DashboardExposureIngress && dashboard.Host == "" {
+		allErrs = append(allErrs, field.Required(dashboardPath.Child("host"), "an Ingress needs a host"))
+	}
+	if dashboard.Issuer != nil && dashboard.Host == "" {
+		allErrs = append(allErrs, field.Required(dashboardPath.Child("host"), "the certificate of the issuer needs a host"))
+	}
+	if exposure == DashboardExposureNone {
+		if dashboard.TLSSecretName != "" {
+			allErrs = append(allErrs, field.Invalid(dashboardPath.Child("tlsSecretName"), dashboard.TLSSecretName,
+				"the dashboard must be exposed through an Ingress or a LoadBalancer"))
+		}
+		if dashboard.Issuer != nil {
+			allErrs = append(allErrs, field.Invalid(dashboardPath.Child("issuer"), dashboard.Issuer.Name,
+				"the dashboard must be exposed through an Ingress or a LoadBalancer"))
+		}
+	}
+	if dashboard.ExternalURL != "" {
+		if !isHTTPURL(dashboard.ExternalURL) {
+			allErrs = append(allErrs, field.Invalid(dashboardPath.Child("externalURL"), dashboard.ExternalURL,
+				"must be an absolute http or https URL"))
+		}
+	}
+	return allErrs
+}
+
+// isHTTPURL returns true for absolute http and https URLs
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (r *KoorCluster) validateNotifications() field.ErrorList {
+	var allErrs field.ErrorList
+	sinksPath := field.NewPath("spec").Child("notifications")
+	validateURL := func(path *field.Path, value string) {
+		if value != "" && !isHTTPURL(value) {
+			allErrs = append(allErrs, field.Invalid(path, value, "must be an absolute http or https URL"))
+		}
+	}
+	for i, sink := range r.Spec.Notifications {
+		sinkPath := sinksPath.Index(i)
+		if sink.Webhook != nil {
+			validateURL(sinkPath.Child("webhook", "url"), sink.Webhook.URL)
+		}
+		if sink.Slack != nil {
+			validateURL(sinkPath.Child("slack", "url"), sink.Slack.URL)
+		}
+		if sink.Alertmanager != nil {
+			validateURL(sinkPath.Child("alertmanager", "url"), sink.Alertmanager.URL)
+		}
+		if email := sink.Email; email != nil {
+			if _, err := mail.ParseAddress(email.From); err != nil {
+				allErrs = append(allErrs, field.Invalid(sinkPath.Child("email", "from"), email.From, err.Error()))
+			}
+			for j, to := range email.To {
+				if _, err := mail.ParseAddress(to); err != nil {
+					allErrs = append(allErrs, field.Invalid(sinkPath.Child("email", "to").Index(j), to, err.Error()))
+				}
+			}
+			if email.PasswordSecret != nil && email.Username == "" {
+				allErrs = append(allErrs, field.Required(sinkPath.Child("email", "username"), "the password needs a user"))
+			}
+		}
+	}
+	return allErrs
+}
+
+func (r *KoorCluster) validateVersionService() field.ErrorList {
+	var allErrs field.ErrorList
+	options := r.Spec.UpgradeOptions
+	optionsPath := field.NewPath("spec").Child("upgradeOptions")
+	if options.Endpoint != "" && !isHTTPURL(options.Endpoint) {
+		allErrs = append(allErrs, field.Invalid(optionsPath.Child("endpoint"), options.Endpoint,
+			"must be an absolute http or https URL"))
+	}
+	if proxy := options.VersionService.ProxyURL; proxy != "" && !isHTTPURL(proxy) {
+		allErrs = append(allErrs, field.Invalid(optionsPath.Child("versionService", "proxyURL"), proxy,
+			"must be an absolute http or https URL"))
+	}
+	if key := options.VersionService.PublicKey; key != "" {
+		if block, _ := pem.Decode([]byte(key)); block == nil {
+			allErrs = append(allErrs, field.Invalid(optionsPath.Child("versionService", "publicKey"), key,
+				"must be a PEM encoded public key"))
+		} else if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			allErrs = append(allErrs, field.Invalid(optionsPath.Child("versionService", "publicKey"), key, err.Error()))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("KoorCluster webhook", func() {
+	var koorCluster *KoorCluster
+
+	BeforeEach(func() {
+		koorCluster = &KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "default"},
+			Spec: KoorClusterSpec{
+				UpgradeOptions: UpgradeOptions{Mode: UpgradeModeNotify, Schedule: "0 0 * * *"},
+			},
+			Status: KoorClusterStatus{
+				CurrentVersions: ProductVersions{Ksd: "v1.11.0", Ceph: "v17.2.5"},
+				LatestVersions: &DetailedProductVersions{
+					Ksd:  &DetailedVersion{Version: "1.11.2"},
+					Ceph: &DetailedVersion{Version: "17.2.6"},
+				},
+			},
+		}
+	})
+
+	Context("Pinned versions", func() {
+		DescribeTable("Should resolve the ceph image",
+			func(ceph, expectedImage, expectedVersion string) {
+				versions := &PinnedVersions{Ceph: ceph}
+				Expect(versions.CephImage()).To(Equal(expectedImage))
+				Expect(versions.CephVersion()).To(Equal(expectedVersion))
+			},
+			Entry("from a tag", "v17.2.6", "quay.io/ceph/ceph:v17.2.6", "v17.2.6"),
+			Entry("from an image", "registry.local:5000/ceph/ceph:v17.2.6", "registry.local:5000/ceph/ceph:v17.2.6", "v17.2.6"),
+			Entry("from a tag with a digest",
+				"v17.2.6@sha256:9c067c50038de818e10ab7887929b6bd496d5dcfe55fa1343854a54e61a82fab",
+				"quay.io/ceph/ceph:v17.2.6@sha256:9c067c50038de818e10ab7887929b6bd496d5dcfe55fa1343854a54e61a82fab",
+				"v17.2.6"),
+		)
+
+		It("Should derive the toolbox image from the ceph image", func() {
+			koorCluster.Status.LatestVersions.Ceph = &DetailedVersion{
+				Version:   "18.2.0",
+				ImageUri:  "quay.io/ceph/ceph:v18.2.0",
+				ImageHash: "9c067c50038de818e10ab7887929b6bd496d5dcfe55fa1343854a54e61a82fab",
+			}
+			Expect(koorCluster.ToolboxImage()).To(Equal("quay.io/ceph/ceph:v17.2.5"))
+
+			koorCluster.Status.CephImage = "registry.local/ceph/ceph:v17.2.5"
+			Expect(koorCluster.ToolboxImage()).To(Equal("registry.local/ceph/ceph:v17.2.5"))
+
+			koorCluster.Spec.Versions = &PinnedVersions{Ceph: "v17.2.6"}
+			Expect(koorCluster.ToolboxImage()).To(Equal("quay.io/ceph/ceph:v17.2.6"))
+
+			koorCluster.Spec.Toolbox = &ToolboxSpec{Image: "registry.local/ceph/ceph:v17.2.6"}
+			Expect(koorCluster.ToolboxImage()).To(Equal("registry.local/ceph/ceph:v17.2.6"))
+		})
+
+		It("Should verify the digest of the ceph image", func() {
+			koorCluster.Status.LatestVersions.Ceph = &DetailedVersion{
+				Version:   "17.2.6",
+				ImageUri:  "quay.io/ceph/ceph:v17.2.6",
+				ImageHash: "9c067c50038de818e10ab7887929b6bd496d5dcfe55fa1343854a54e61a82fab",
+			}
+			Expect(koorCluster.Status.LatestVersions.Ceph.VerifyImageDigest(
+				"quay.io/ceph/ceph:v17.2.6@sha256:1111111111111111111111111111111111111111111111111111111111111111")).
+				To(MatchError(ContainSubstring("does not match the hash")))
+			Expect(koorCluster.Status.LatestVersions.Ceph.VerifyImageDigest(
+				"quay.io/ceph/ceph:v17.2.6@sha256:9c067c50038de818e10ab7887929b6bd496d5dcfe55fa1343854a54e61a82fab")).To(Succeed())
+			Expect(koorCluster.Status.LatestVersions.Ceph.VerifyImageDigest("quay.io/ceph/ceph:v17.2.6")).To(Succeed())
+		})
+
+		It("Should block invalid toolbox images", func() {
+			koorCluster.Spec.Toolbox = &ToolboxSpec{Image: "Ceph:latest"}
+			Expect(koorCluster.ValidateCreate()).Error().To(MatchError(ContainSubstring("spec.toolbox.image")))
+		})
+
+		It("Should default the chart version to the KSD version", func() {
+			Expect((*PinnedVersions)(nil).ChartVersion()).To(BeEmpty())
+			Expect((&PinnedVersions{Ksd: "v1.11.1"}).ChartVersion()).To(Equal("v1.11.1"))
+			Expect((&PinnedVersions{Ksd: "v1.11.1", Chart: "v1.11.1-1"}).ChartVersion()).To(Equal("v1.11.1-1"))
+		})
+
+		It("Should allow upgrades up to the latest versions", func() {
+			koorCluster.Spec.Versions = &PinnedVersions{Ksd: "v1.11.1", Ceph: "v17.2.6"}
+			Expect(koorCluster.ValidateCreate()).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should block downgrades", func() {
+			koorCluster.Spec.Versions = &PinnedVersions{Ksd: "v1.10.9"}
+			_, err := koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("downgrades from v1.11.0 to v1.10.9 are not supported")))
+		})
+
+		It("Should block version skips", func() {
+			koorCluster.Spec.Versions = &PinnedVersions{Ceph: "v18.2.0"}
+			_, err := koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("v18.2.0 skips versions")))
+		})
+
+		It("Should warn when the upgrade path is unknown", func() {
+			koorCluster.Status.LatestVersions = nil
+			koorCluster.Spec.Versions = &PinnedVersions{Ksd: "v1.11.1"}
+			warnings, err := koorCluster.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+		})
+
+		It("Should not check unchanged versions again", func() {
+			old := koorCluster.DeepCopy()
+			old.Spec.Versions = &PinnedVersions{Ksd: "v1.12.0"}
+			koorCluster.Spec.Versions = &PinnedVersions{Ksd: "v1.12.0"}
+			Expect(koorCluster.ValidateUpdate(old)).Error().NotTo(HaveOccurred())
+		})
+	})
+
+	Context("Pools", func() {
+		replicated := func(size int32) PoolSpec {
+			return PoolSpec{FailureDomain: "host", Replicated: &ReplicatedSpec{Size: size}}
+		}
+
+		BeforeEach(func() {
+			nodes := resource.MustParse("3")
+			koorCluster.Status.TotalResources.Nodes = &nodes
+		})
+
+		It("Should allow pools that fit on the nodes", func() {
+			koorCluster.Spec.BlockPools = []BlockPool{{Name: "replicapool", PoolSpec: replicated(3)}}
+			koorCluster.Spec.Filesystems = []Filesystem{{
+				Name:         "cephfs",
+				MetadataPool: replicated(3),
+				DataPools:    []NamedPoolSpec{{Name: "data0", PoolSpec: replicated(3)}},
+			}}
+			koorCluster.Spec.ObjectStores = []ObjectStore{{
+				Name:         "s3",
+				MetadataPool: replicated(3),
+				DataPool: PoolSpec{FailureDomain: "host",
+					ErasureCoded: &ErasureCodedSpec{DataChunks: 2, CodingChunks: 1}},
+			}}
+			Expect(koorCluster.ValidateCreate()).To(BeEmpty())
+		})
+
+		It("Should block pools that need more hosts than the storage nodes", func() {
+			koorCluster.Spec.ObjectStores = []ObjectStore{{
+				Name:         "s3",
+				MetadataPool: replicated(3),
+				DataPool: PoolSpec{FailureDomain: "host",
+					ErasureCoded: &ErasureCodedSpec{DataChunks: 4, CodingChunks: 2}},
+			}}
+			_, err := koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring(
+				"spec.objectStores[0].dataPool: Invalid value: 6: the pool spreads its data across 6 hosts, but the cluster has 3 storage nodes")))
+		})
+
+		It("Should count the storage nodes left after decommissioning", func() {
+			koorCluster.Status.StorageNodes = []string{"storage-1", "storage-2"}
+			koorCluster.Spec.BlockPools = []BlockPool{{Name: "replicapool", PoolSpec: replicated(3)}}
+			_, err := koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("the cluster has 2 storage nodes")))
+		})
+
+		It("Should not check other failure domains", func() {
+			pool := replicated(5)
+			pool.FailureDomain = "osd"
+			koorCluster.Spec.BlockPools = []BlockPool{{Name: "replicapool", PoolSpec: pool}}
+			Expect(koorCluster.ValidateCreate()).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should warn about pools without redundancy and erasure coded block pools", func() {
+			koorCluster.Spec.BlockPools = []BlockPool{
+				{Name: "scratch", PoolSpec: replicated(1)},
+				{Name: "ec", PoolSpec: PoolSpec{FailureDomain: "host",
+					ErasureCoded: &ErasureCodedSpec{DataChunks: 2, CodingChunks: 1}}},
+			}
+			warnings, err := koorCluster.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(
+				ContainSubstring("spec.blockPools[0].replicated.size stores a single copy"),
+				ContainSubstring("Block pool ec is erasure coded"),
+			))
+		})
+	})
+
+	Context("StorageClasses", func() {
+		It("Should allow a single default StorageClass", func() {
+			koorCluster.Spec.StorageClasses = []StorageClassSpec{
+				{Name: "fast", BlockPool: "replicapool", Default: true},
+				{Name: "shared", Filesystem: "cephfs"},
+			}
+			Expect(koorCluster.ValidateCreate()).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should block a second default StorageClass", func() {
+			koorCluster.Spec.StorageClasses = []StorageClassSpec{
+				{Name: "fast", BlockPool: "replicapool", Default: true},
+				{Name: "shared", Filesystem: "cephfs", Default: true},
+			}
+			_, err := koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("fast is already the default StorageClass")))
+		})
+
+		It("Should block pools and filesystems that are not in the spec", func() {
+			koorCluster.Spec.BlockPools = []BlockPool{
+				{Name: "replicapool", PoolSpec: PoolSpec{Replicated: &ReplicatedSpec{Size: 3}}},
+				{Name: "ecpool", PoolSpec: PoolSpec{ErasureCoded: &ErasureCodedSpec{DataChunks: 2, CodingChunks: 1}}},
+			}
+			koorCluster.Spec.Filesystems = []Filesystem{{Name: "cephfs"}}
+			koorCluster.Spec.StorageClasses = []StorageClassSpec{
+				{Name: "fast", BlockPool: "fastpool"},
+				{Name: "ec", BlockPool: "ecpool"},
+				{Name: "shared", Filesystem: "sharedfs"},
+			}
+			_, err := koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring(`spec.storageClasses[0].blockPool: Not found: "fastpool"`)))
+			Expect(err).To(MatchError(ContainSubstring("spec.storageClasses[1].blockPool: Invalid value: \"ecpool\": RBD volumes need a replicated pool")))
+			Expect(err).To(MatchError(ContainSubstring(`spec.storageClasses[2].filesystem: Not found: "sharedfs"`)))
+		})
+	})
+
+	Context("Ceph config", func() {
+		It("Should allow known options", func() {
+			koorCluster.Spec.CephConfig = CephConfig{
+				"osd": {"osd max backfills": "2"},
+				"mgr": {"mgr/dashboard/ssl": "true"},
+			}
+			Expect(koorCluster.ValidateCreate()).To(BeEmpty())
+		})
+
+		It("Should block unknown sections and options", func() {
+			koorCluster.Spec.CephConfig = CephConfig{
+				"rgw":    {"rgw_dns_name": "s3.example.com"},
+				"osd":    {"osd_max_backfill": "2", "osd_memory_target": " "},
+				"global": {"osd pool default size": "3", "osd_pool_default_size": "3"},
+			}
+			_, err := koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring(`spec.cephConfig[rgw]: Unsupported value: "rgw"`)))
+			Expect(err).To(MatchError(ContainSubstring(`spec.cephConfig[osd][osd_max_backfill]: Invalid value: "osd_max_backfill": unknown ceph option`)))
+			Expect(err).To(MatchError(ContainSubstring(`spec.cephConfig[osd][osd_memory_target]: Required value`)))
+			Expect(err).To(MatchError(ContainSubstring("is the same option as")))
+		})
+
+		It("Should warn about dangerous options", func() {
+			koorCluster.Spec.CephConfig = CephConfig{
+				"global": {"auth_cluster_required": "none", "mon_allow_pool_delete": "true"},
+			}
+			warnings, err := koorCluster.ValidateCreate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(
+				"The ceph option auth_cluster_required disables the authentication of ceph daemons and clients",
+				"The ceph option mon_allow_pool_delete allows to delete pools and all of their data",
+			))
+		})
+	})
+
+	Context("Dashboard", func() {
+		It("Should allow an Ingress with an issuer", func() {
+			koorCluster.Spec.Dashboard = DashboardSpec{
+				Expose: DashboardExposureIngress,
+				Host:   "ceph.example.com",
+				Issuer: &IssuerReference{Name: "letsencrypt", Kind: "ClusterIssuer"},
+			}
+			Expect(koorCluster.ValidateCreate()).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should block incomplete exposures", func() {
+			koorCluster.Spec.Dashboard = DashboardSpec{
+				Expose:      DashboardExposureIngress,
+				ExternalURL: "ceph.example.com",
+			}
+			_, err := koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.dashboard.host: Required value: an Ingress needs a host")))
+			Expect(err).To(MatchError(ContainSubstring("spec.dashboard.externalURL: Invalid value")))
+
+			koorCluster.Spec.Dashboard = DashboardSpec{TLSSecretName: "dashboard-tls"}
+			_, err = koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.dashboard.tlsSecretName: Invalid value")))
+		})
+	})
+
+	Context("Notifications", func() {
+		It("Should allow valid sinks", func() {
+			koorCluster.Spec.Notifications = []NotificationSink{
+				{Name: "ops", Webhook: &WebhookSink{URL: "https://ops.example.com/hook"}},
+				{Name: "alerts", Alertmanager: &AlertmanagerSink{URL: "http://alertmanager-operated.monitoring:9093"}},
+				{Name: "mail", Email: &EmailSink{Host: "smtp.example.com", From: "Ceph <ceph@example.com>", To: []string{"ops@example.com"}}},
+			}
+			Expect(koorCluster.ValidateCreate()).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should block invalid URLs and addresses", func() {
+			koorCluster.Spec.Notifications = []NotificationSink{
+				{Name: "ops", Webhook: &WebhookSink{URL: "ops.example.com/hook"}},
+				{Name: "mail", Email: &EmailSink{
+					Host:           "smtp.example.com",
+					From:           "ceph@example.com",
+					To:             []string{"ops"},
+					PasswordSecret: &corev1.SecretKeySelector{Key: "password"},
+				}},
+			}
+			_, err := koorCluster.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.notifications[0].webhook.url: Invalid value")))
+			Expect(err).To(MatchError(ContainSubstring("spec.notifications[1].email.to[0]: Invalid value")))
+			Expect(err).To(MatchError(ContainSubstring("spec.notifications[1].email.username: Required value")))
+		})
+	})
+
+	It("Should block invalid version service URLs", func() {
+		koorCluster.Spec.UpgradeOptions.Endpoint = "versions.koor.tech"
+		koorCluster.Spec.UpgradeOptions.VersionService.ProxyURL = "proxy:3128"
+		_, err := koorCluster.ValidateCreate()
+		Expect(err).To(MatchError(ContainSubstring("spec.upgradeOptions.endpoint: Invalid value")))
+		Expect(err).To(MatchError(ContainSubstring("spec.upgradeOptions.versionService.proxyURL: Invalid value")))
+	})
+
+	It("Should block invalid public keys", func() {
+		koorCluster.Spec.UpgradeOptions.VersionService.PublicKey = "ssh-ed25519 AAAA"
+		Expect(koorCluster.ValidateCreate()).Error().To(MatchError(ContainSubstring(
+			"spec.upgradeOptions.versionService.publicKey: Invalid value")))
+
+		koorCluster.Spec.UpgradeOptions.VersionService.PublicKey = "-----BEGIN PUBLIC KEY-----\n" +
+			"MCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE=\n" +
+			"-----END PUBLIC KEY-----\n"
+		Expect(koorCluster.ValidateCreate()).Error().NotTo(HaveOccurred())
+	})
+})
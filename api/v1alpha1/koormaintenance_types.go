@@ -0,0 +1,120 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KoorMaintenanceSpec defines the nodes to put into maintenance
+// +kubebuilder:validation:XValidation:rule="size(self.nodes) > 0 || size(self.failureDomains) > 0",message="nodes or failureDomains must be set"
+type KoorMaintenanceSpec struct {
+	// The names of the nodes to put into maintenance
+	//+kubebuilder:default:={}
+	Nodes []string `json:"nodes"`
+	// The failure domains to put into maintenance. All nodes with the label value are included.
+	//+kubebuilder:default:={}
+	FailureDomains []FailureDomain `json:"failureDomains"`
+	// Cordon and drain the nodes once the ceph flags are set
+	//+kubebuilder:default:=true
+	Drain *bool `json:"drain,omitempty"`
+	// Set when the work on the nodes is finished. The nodes are uncordoned, the ceph flags are unset
+	// and the maintenance completes once all placement groups are active+clean again.
+	Done bool `json:"done,omitempty"`
+}
+
+type FailureDomain struct {
+	// The node label of the failure domain, for example topology.rook.io/rack
+	//+kubebuilder:default:="topology.kubernetes.io/zone"
+	Label string `json:"label,omitempty"`
+	// The value of the label, which is also the name of the ceph CRUSH bucket
+	//+kubebuilder:validation:MinLength=1
+	Value string `json:"value"`
+}
+
+// The phases of a maintenance, in the order they are run
+type MaintenancePhase string
+
+const (
+	// The ceph flags are set and the nodes are cordoned and drained one after another
+	MaintenancePhaseDraining MaintenancePhase = "Draining"
+	// The nodes are ready for the work
+	MaintenancePhaseReady MaintenancePhase = "Ready"
+	// The flags are unset and the placement groups recover
+	MaintenancePhaseRecovering MaintenancePhase = "Recovering"
+	// All placement groups are active+clean
+	MaintenancePhaseCompleted MaintenancePhase = "Completed"
+)
+
+// KoorMaintenanceStatus defines the observed state of KoorMaintenance
+type KoorMaintenanceStatus struct {
+	// The phase of the maintenance
+	Phase MaintenancePhase `json:"phase,omitempty"`
+	// The nodes in maintenance
+	Nodes []string `json:"nodes,omitempty"`
+	// The ceph CRUSH buckets with the noout flag
+	CrushBuckets []string `json:"crushBuckets,omitempty"`
+	// The nodes cordoned by the operator. They are uncordoned when the maintenance is done.
+	CordonedNodes []string `json:"cordonedNodes,omitempty"`
+	// The nodes without pods left to evict
+	DrainedNodes []string `json:"drainedNodes,omitempty"`
+	// What the maintenance waits for
+	Message string `json:"message,omitempty"`
+	// When the maintenance started
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// When all placement groups were active+clean again
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// IsActive returns true while the ceph flags may be set
+func (s *KoorMaintenanceStatus) IsActive() bool {
+	return s.Phase != "" && s.Phase != MaintenancePhaseCompleted
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// KoorMaintenance is the Schema for the koormaintenances API
+type KoorMaintenance struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KoorMaintenanceSpec   `json:"spec,omitempty"`
+	Status KoorMaintenanceStatus `json:"status,omitempty"`
+}
+
+func (k *KoorMaintenance) IsBeingDeleted() bool {
+	return !k.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+const KoorMaintenanceFinalizerName = "storage.koor.tech/maintenance-finalizer"
+
+//+kubebuilder:object:root=true
+
+// KoorMaintenanceList contains a list of KoorMaintenance
+type KoorMaintenanceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KoorMaintenance `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KoorMaintenance{}, &KoorMaintenanceList{})
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KoorNodeDecommissionSpec defines the node to remove from the ceph cluster
+type KoorNodeDecommissionSpec struct {
+	// The name of the node to remove
+	//+kubebuilder:validation:MinLength=1
+	//+kubebuilder:validation:XValidation:rule="self == oldSelf",message="nodeName is immutable"
+	NodeName string `json:"nodeName"`
+}
+
+// The phases of a decommission, in the order they are run
+type DecommissionPhase string
+
+const (
+	// The OSDs are marked out and their data migrates to the other OSDs
+	DecommissionPhaseMigrating DecommissionPhase = "Migrating"
+	// The node is removed from the storage nodes of the CephCluster
+	DecommissionPhaseRemovingNode DecommissionPhase = "RemovingNode"
+	// The OSDs are stopped and purged from the ceph cluster
+	DecommissionPhasePurging DecommissionPhase = "Purging"
+	// The node holds no OSDs anymore
+	DecommissionPhaseCompleted DecommissionPhase = "Completed"
+)
+
+// KoorNodeDecommissionStatus defines the observed state of KoorNodeDecommission
+type KoorNodeDecommissionStatus struct {
+	// The phase of the decommission
+	Phase DecommissionPhase `json:"phase,omitempty"`
+	// The name of the node in the CephCluster storage nodes
+	StorageNodeName string `json:"storageNodeName,omitempty"`
+	// The OSDs of the node
+	Osds []int `json:"osds,omitempty"`
+	// The OSDs that are purged
+	PurgedOsds []int `json:"purgedOsds,omitempty"`
+	// The placement groups still stored on the OSDs of the node
+	RemainingPGs int64 `json:"remainingPGs,omitempty"`
+	// What the decommission waits for
+	Message string `json:"message,omitempty"`
+	// When the decommission started
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// When the OSDs were purged
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// The latest observations of the decommission
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Condition types of the KoorNodeDecommission
+const (
+	// The node is removed from the storage nodes of the CephCluster.
+	// False with the reason why the KoorCluster did not apply the storage nodes yet.
+	ConditionStorageNodesApplied = "StorageNodesApplied"
+)
+
+// RemovesNode returns true once the node must not be a storage node anymore
+func (s *KoorNodeDecommissionStatus) RemovesNode() bool {
+	switch s.Phase {
+	case DecommissionPhaseRemovingNode, DecommissionPhasePurging, DecommissionPhaseCompleted:
+		return true
+	}
+	return false
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.spec.nodeName`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// KoorNodeDecommission is the Schema for the koornodedecommissions API.
+// Keep the resource until the node is removed from Kubernetes, otherwise the node is used for storage again.
+type KoorNodeDecommission struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KoorNodeDecommissionSpec   `json:"spec,omitempty"`
+	Status KoorNodeDecommissionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KoorNodeDecommissionList contains a list of KoorNodeDecommission
+type KoorNodeDecommissionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KoorNodeDecommission `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KoorNodeDecommission{}, &KoorNodeDecommissionList{})
+}
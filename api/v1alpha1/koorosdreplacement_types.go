@@ -0,0 +1,110 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KoorOsdReplacementSpec defines the OSD to replace
+type KoorOsdReplacementSpec struct {
+	// The id of the OSD to replace
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:XValidation:rule="self == oldSelf",message="osdId is immutable"
+	OsdID int `json:"osdId"`
+	// The replacement disk on the node of the OSD, for example /dev/sdc.
+	// Defaults to the disk of the failed OSD, which is found by its serial.
+	//+kubebuilder:validation:Pattern=`^/dev/`
+	Device string `json:"device,omitempty"`
+}
+
+// The phases of a replacement, in the order they are run
+type ReplacementPhase string
+
+const (
+	// The OSD is marked out and the replacement waits until it is safe to destroy
+	ReplacementPhaseMigrating ReplacementPhase = "Migrating"
+	// The OSD is stopped and purged from the ceph cluster
+	ReplacementPhasePurging ReplacementPhase = "Purging"
+	// A job wipes the replacement disk
+	ReplacementPhaseZapping ReplacementPhase = "Zapping"
+	// Rook creates a new OSD on the replacement disk
+	ReplacementPhaseRecreating ReplacementPhase = "Recreating"
+	// The new OSD is up
+	ReplacementPhaseCompleted ReplacementPhase = "Completed"
+	// The replacement disk could not be wiped
+	ReplacementPhaseFailed ReplacementPhase = "Failed"
+)
+
+// KoorOsdReplacementStatus defines the observed state of KoorOsdReplacement
+type KoorOsdReplacementStatus struct {
+	// The phase of the replacement
+	Phase ReplacementPhase `json:"phase,omitempty"`
+	// The node of the OSD
+	Node string `json:"node,omitempty"`
+	// The disk that is wiped for the new OSD
+	Device string `json:"device,omitempty"`
+	// The device id of the disk of the failed OSD. The disk is only wiped if its serial matches.
+	// Empty if spec.device names another disk.
+	DeviceID string `json:"deviceId,omitempty"`
+	// The OSDs of the node before rook creates the new OSD
+	HostOsds []int `json:"hostOsds,omitempty"`
+	// The id of the new OSD
+	NewOsdID *int `json:"newOsdId,omitempty"`
+	// What the replacement waits for
+	Message string `json:"message,omitempty"`
+	// When the replacement started
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// When the new OSD was up
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// IsFinished returns true once the replacement completed or failed
+func (s *KoorOsdReplacementStatus) IsFinished() bool {
+	return s.Phase == ReplacementPhaseCompleted || s.Phase == ReplacementPhaseFailed
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="OSD",type=integer,JSONPath=`.spec.osdId`
+//+kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.status.node`
+//+kubebuilder:printcolumn:name="Device",type=string,JSONPath=`.status.device`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// KoorOsdReplacement is the Schema for the koorosdreplacements API
+type KoorOsdReplacement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KoorOsdReplacementSpec   `json:"spec,omitempty"`
+	Status KoorOsdReplacementStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KoorOsdReplacementList contains a list of KoorOsdReplacement
+type KoorOsdReplacementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KoorOsdReplacement `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KoorOsdReplacement{}, &KoorOsdReplacementList{})
+}
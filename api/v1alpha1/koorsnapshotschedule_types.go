@@ -0,0 +1,83 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KoorSnapshotScheduleSpec defines which volumes to snapshot and when
+type KoorSnapshotScheduleSpec struct {
+	// When to take the snapshots. Uses CRON format as specified by https://github.com/robfig/cron/tree/v3.
+	// To change the timezone, prefix the schedule with CRON_TZ=<Timezone>.
+	// For example: "CRON_TZ=UTC 0 1 * * *" is 01:00 UTC.
+	//+kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+	// Selects the PersistentVolumeClaims in the namespace of the schedule. An empty selector selects all claims.
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+	// The VolumeSnapshotClass of the snapshots. The default VolumeSnapshotClass is used if unset.
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// The number of snapshots to keep for each claim. Older snapshots are deleted.
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default:=7
+	Retention int32 `json:"retention,omitempty"`
+	// Stops taking snapshots. Existing snapshots are kept.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// KoorSnapshotScheduleStatus defines the observed state of KoorSnapshotSchedule
+type KoorSnapshotScheduleStatus struct {
+	// When the last snapshots were taken
+	LastSnapshotTime *metav1.Time `json:"lastSnapshotTime,omitempty"`
+	// When the next snapshots are taken
+	NextSnapshotTime *metav1.Time `json:"nextSnapshotTime,omitempty"`
+	// The number of snapshots taken by the schedule that still exist
+	Snapshots int32 `json:"snapshots,omitempty"`
+	// The result of the last run
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+//+kubebuilder:printcolumn:name="Last Snapshot",type=date,JSONPath=`.status.lastSnapshotTime`
+//+kubebuilder:printcolumn:name="Snapshots",type=integer,JSONPath=`.status.snapshots`
+
+// KoorSnapshotSchedule is the Schema for the koorsnapshotschedules API.
+// The snapshots are kept when the schedule is deleted.
+// +kubebuilder:validation:XValidation:rule="size(self.metadata.name) <= 63",message="the name is used as a label value and may have at most 63 characters"
+type KoorSnapshotSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KoorSnapshotScheduleSpec   `json:"spec,omitempty"`
+	Status KoorSnapshotScheduleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KoorSnapshotScheduleList contains a list of KoorSnapshotSchedule
+type KoorSnapshotScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KoorSnapshotSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KoorSnapshotSchedule{}, &KoorSnapshotScheduleList{})
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The events the operator notifies the sinks about
+// +kubebuilder:validation:Enum=UpgradeAvailable;UpgradeFinished;UpgradeFailed;HealthDegraded;ResourcesInsufficient
+type NotificationEvent string
+
+const (
+	// The version service reports newer versions
+	NotificationEventUpgradeAvailable NotificationEvent = "UpgradeAvailable"
+	// An upgrade succeeded
+	NotificationEventUpgradeFinished NotificationEvent = "UpgradeFinished"
+	// An upgrade failed or was rolled back
+	NotificationEventUpgradeFailed NotificationEvent = "UpgradeFailed"
+	// Ceph reports HEALTH_WARN or HEALTH_ERR
+	NotificationEventHealthDegraded NotificationEvent = "HealthDegraded"
+	// The nodes do not meet the minimum recommended resources
+	NotificationEventResourcesInsufficient NotificationEvent = "ResourcesInsufficient"
+)
+
+// All notification events, in the order they are delivered
+var NotificationEvents = []NotificationEvent{
+	NotificationEventUpgradeAvailable,
+	NotificationEventUpgradeFinished,
+	NotificationEventUpgradeFailed,
+	NotificationEventHealthDegraded,
+	NotificationEventResourcesInsufficient,
+}
+
+// +kubebuilder:validation:XValidation:rule="[has(self.webhook), has(self.slack), has(self.email), has(self.alertmanager)].filter(x, x).size() == 1",message="exactly one of webhook, slack, email or alertmanager must be set"
+type NotificationSink struct {
+	// The name of the sink, which identifies its deliveries in the status
+	//+kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// The events sent to the sink, all events if empty
+	Events []NotificationEvent `json:"events,omitempty"`
+	// Posts the notification as JSON
+	Webhook *WebhookSink `json:"webhook,omitempty"`
+	// Posts the notification to a Slack-compatible incoming webhook
+	Slack *SlackSink `json:"slack,omitempty"`
+	// Sends the notification by email
+	Email *EmailSink `json:"email,omitempty"`
+	// Posts the notification as an alert to the API of Alertmanager
+	Alertmanager *AlertmanagerSink `json:"alertmanager,omitempty"`
+}
+
+// Accepts returns true if the sink receives the event
+func (ns *NotificationSink) Accepts(event NotificationEvent) bool {
+	return len(ns.Events) == 0 || slices.Contains(ns.Events, event)
+}
+
+// +kubebuilder:validation:XValidation:rule="has(self.url) != has(self.urlSecret)",message="exactly one of url or urlSecret must be set"
+type WebhookSink struct {
+	// The URL the notification is posted to
+	URL string `json:"url,omitempty"`
+	// The Secret key with the URL, for URLs that contain a token
+	URLSecret *corev1.SecretKeySelector `json:"urlSecret,omitempty"`
+}
+
+type SlackSink struct {
+	WebhookSink `json:",inline"`
+	// Overrides the channel of the incoming webhook
+	Channel string `json:"channel,omitempty"`
+}
+
+type EmailSink struct {
+	// The host of the SMTP server
+	//+kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+	// The port of the SMTP server
+	//+kubebuilder:default:=587
+	Port int32 `json:"port,omitempty"`
+	// The user to authenticate with, no authentication if empty
+	Username string `json:"username,omitempty"`
+	// The Secret key with the password of the user
+	PasswordSecret *corev1.SecretKeySelector `json:"passwordSecret,omitempty"`
+	// The sender address
+	//+kubebuilder:validation:MinLength=1
+	From string `json:"from"`
+	// The recipient addresses
+	//+kubebuilder:validation:MinItems=1
+	To []string `json:"to"`
+}
+
+type AlertmanagerSink struct {
+	// The URL of Alertmanager, e.g. http://alertmanager-operated.monitoring:9093
+	//+kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+}
+
+type NotificationSinkStatus struct {
+	// The name of the sink
+	Name string `json:"name"`
+	// The notifications delivered to the sink by event. The operator removes an event once it
+	// no longer applies, so that it is delivered again when it recurs.
+	Delivered map[NotificationEvent]string `json:"delivered,omitempty"`
+	// The last delivery to the sink
+	LastDelivery *NotificationDelivery `json:"lastDelivery,omitempty"`
+}
+
+type NotificationDelivery struct {
+	// The event of the notification
+	Event NotificationEvent `json:"event"`
+	// Identifies the notification to deliver it once
+	Key string `json:"key"`
+	// When the notification was last sent
+	Time metav1.Time `json:"time"`
+	// The number of attempts to deliver the notification
+	Attempts int32 `json:"attempts"`
+	// Whether the last attempt succeeded
+	Succeeded bool `json:"succeeded"`
+	// The error of the last attempt
+	Error string `json:"error,omitempty"`
+}
@@ -21,182 +21,1624 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertThresholds) DeepCopyInto(out *AlertThresholds) {
+	*out = *in
+	out.HealthErrorFor = in.HealthErrorFor
+	out.HealthWarningFor = in.HealthWarningFor
+	out.OsdDownFor = in.OsdDownFor
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertThresholds.
+func (in *AlertThresholds) DeepCopy() *AlertThresholds {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertThresholds)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertmanagerSink) DeepCopyInto(out *AlertmanagerSink) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertmanagerSink.
+func (in *AlertmanagerSink) DeepCopy() *AlertmanagerSink {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertmanagerSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlockPool) DeepCopyInto(out *BlockPool) {
+	*out = *in
+	in.PoolSpec.DeepCopyInto(&out.PoolSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockPool.
+func (in *BlockPool) DeepCopy() *BlockPool {
+	if in == nil {
+		return nil
+	}
+	out := new(BlockPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacityOptions) DeepCopyInto(out *CapacityOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacityOptions.
+func (in *CapacityOptions) DeepCopy() *CapacityOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacityOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CephCapacity) DeepCopyInto(out *CephCapacity) {
+	*out = *in
+	if in.Total != nil {
+		in, out := &in.Total, &out.Total
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Used != nil {
+		in, out := &in.Used, &out.Used
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Available != nil {
+		in, out := &in.Available, &out.Available
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CephCapacity.
+func (in *CephCapacity) DeepCopy() *CephCapacity {
+	if in == nil {
+		return nil
+	}
+	out := new(CephCapacity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CephClusterStatus) DeepCopyInto(out *CephClusterStatus) {
+	*out = *in
+	if in.HealthChecks != nil {
+		in, out := &in.HealthChecks, &out.HealthChecks
+		*out = make([]CephHealthCheck, len(*in))
+		copy(*out, *in)
+	}
+	in.Capacity.DeepCopyInto(&out.Capacity)
+	if in.Pools != nil {
+		in, out := &in.Pools, &out.Pools
+		*out = make([]CephPoolUsage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Osds != nil {
+		in, out := &in.Osds, &out.Osds
+		*out = new(CephOsdCounts)
+		**out = **in
+	}
+	if in.Pgs != nil {
+		in, out := &in.Pgs, &out.Pgs
+		*out = new(CephPgCounts)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CephClusterStatus.
+func (in *CephClusterStatus) DeepCopy() *CephClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CephClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in CephConfig) DeepCopyInto(out *CephConfig) {
+	{
+		in := &in
+		*out = make(CephConfig, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CephConfig.
+func (in CephConfig) DeepCopy() CephConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CephConfig)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CephHealthCheck) DeepCopyInto(out *CephHealthCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CephHealthCheck.
+func (in *CephHealthCheck) DeepCopy() *CephHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(CephHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CephOsdCounts) DeepCopyInto(out *CephOsdCounts) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CephOsdCounts.
+func (in *CephOsdCounts) DeepCopy() *CephOsdCounts {
+	if in == nil {
+		return nil
+	}
+	out := new(CephOsdCounts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CephPgCounts) DeepCopyInto(out *CephPgCounts) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CephPgCounts.
+func (in *CephPgCounts) DeepCopy() *CephPgCounts {
+	if in == nil {
+		return nil
+	}
+	out := new(CephPgCounts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CephPoolUsage) DeepCopyInto(out *CephPoolUsage) {
+	*out = *in
+	if in.Stored != nil {
+		in, out := &in.Stored, &out.Stored
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MaxAvailable != nil {
+		in, out := &in.MaxAvailable, &out.MaxAvailable
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CephPoolUsage.
+func (in *CephPoolUsage) DeepCopy() *CephPoolUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(CephPoolUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DashboardSpec) DeepCopyInto(out *DashboardSpec) {
+	*out = *in
+	if in.IngressClassName != nil {
+		in, out := &in.IngressClassName, &out.IngressClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Issuer != nil {
+		in, out := &in.Issuer, &out.Issuer
+		*out = new(IssuerReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DashboardSpec.
+func (in *DashboardSpec) DeepCopy() *DashboardSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DashboardSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DashboardStatus) DeepCopyInto(out *DashboardStatus) {
+	*out = *in
+	if in.PasswordSecret != nil {
+		in, out := &in.PasswordSecret, &out.PasswordSecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PasswordRotatedAt != nil {
+		in, out := &in.PasswordRotatedAt, &out.PasswordRotatedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DashboardStatus.
+func (in *DashboardStatus) DeepCopy() *DashboardStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DashboardStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DetailedProductVersions) DeepCopyInto(out *DetailedProductVersions) {
 	*out = *in
 	if in.KoorOperator != nil {
 		in, out := &in.KoorOperator, &out.KoorOperator
 		*out = new(DetailedVersion)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Ksd != nil {
 		in, out := &in.Ksd, &out.Ksd
 		*out = new(DetailedVersion)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ceph != nil {
+		in, out := &in.Ceph, &out.Ceph
+		*out = new(DetailedVersion)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CurrentEndOfLife != nil {
+		in, out := &in.CurrentEndOfLife, &out.CurrentEndOfLife
+		*out = new(EndOfLifeDates)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DetailedProductVersions.
+func (in *DetailedProductVersions) DeepCopy() *DetailedProductVersions {
+	if in == nil {
+		return nil
+	}
+	out := new(DetailedProductVersions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DetailedVersion) DeepCopyInto(out *DetailedVersion) {
+	*out = *in
+	if in.Advisories != nil {
+		in, out := &in.Advisories, &out.Advisories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EndOfLife != nil {
+		in, out := &in.EndOfLife, &out.EndOfLife
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DetailedVersion.
+func (in *DetailedVersion) DeepCopy() *DetailedVersion {
+	if in == nil {
+		return nil
+	}
+	out := new(DetailedVersion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DownOsd) DeepCopyInto(out *DownOsd) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DownOsd.
+func (in *DownOsd) DeepCopy() *DownOsd {
+	if in == nil {
+		return nil
+	}
+	out := new(DownOsd)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmailSink) DeepCopyInto(out *EmailSink) {
+	*out = *in
+	if in.PasswordSecret != nil {
+		in, out := &in.PasswordSecret, &out.PasswordSecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmailSink.
+func (in *EmailSink) DeepCopy() *EmailSink {
+	if in == nil {
+		return nil
+	}
+	out := new(EmailSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndOfLifeDates) DeepCopyInto(out *EndOfLifeDates) {
+	*out = *in
+	if in.KoorOperator != nil {
+		in, out := &in.KoorOperator, &out.KoorOperator
+		*out = (*in).DeepCopy()
+	}
+	if in.Ksd != nil {
+		in, out := &in.Ksd, &out.Ksd
+		*out = (*in).DeepCopy()
+	}
+	if in.Ceph != nil {
+		in, out := &in.Ceph, &out.Ceph
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndOfLifeDates.
+func (in *EndOfLifeDates) DeepCopy() *EndOfLifeDates {
+	if in == nil {
+		return nil
+	}
+	out := new(EndOfLifeDates)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ErasureCodedSpec) DeepCopyInto(out *ErasureCodedSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ErasureCodedSpec.
+func (in *ErasureCodedSpec) DeepCopy() *ErasureCodedSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ErasureCodedSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailureDomain) DeepCopyInto(out *FailureDomain) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailureDomain.
+func (in *FailureDomain) DeepCopy() *FailureDomain {
+	if in == nil {
+		return nil
+	}
+	out := new(FailureDomain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Filesystem) DeepCopyInto(out *Filesystem) {
+	*out = *in
+	in.MetadataPool.DeepCopyInto(&out.MetadataPool)
+	if in.DataPools != nil {
+		in, out := &in.DataPools, &out.DataPools
+		*out = make([]NamedPoolSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Filesystem.
+func (in *Filesystem) DeepCopy() *Filesystem {
+	if in == nil {
+		return nil
+	}
+	out := new(Filesystem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IssuerReference) DeepCopyInto(out *IssuerReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IssuerReference.
+func (in *IssuerReference) DeepCopy() *IssuerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(IssuerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorCephCommand) DeepCopyInto(out *KoorCephCommand) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorCephCommand.
+func (in *KoorCephCommand) DeepCopy() *KoorCephCommand {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorCephCommand)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorCephCommand) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorCephCommandList) DeepCopyInto(out *KoorCephCommandList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KoorCephCommand, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorCephCommandList.
+func (in *KoorCephCommandList) DeepCopy() *KoorCephCommandList {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorCephCommandList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorCephCommandList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorCephCommandSpec) DeepCopyInto(out *KoorCephCommandSpec) {
+	*out = *in
+	if in.RefreshInterval != nil {
+		in, out := &in.RefreshInterval, &out.RefreshInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorCephCommandSpec.
+func (in *KoorCephCommandSpec) DeepCopy() *KoorCephCommandSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorCephCommandSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorCephCommandStatus) DeepCopyInto(out *KoorCephCommandStatus) {
+	*out = *in
+	if in.Output != nil {
+		in, out := &in.Output, &out.Output
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastRunTime != nil {
+		in, out := &in.LastRunTime, &out.LastRunTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorCephCommandStatus.
+func (in *KoorCephCommandStatus) DeepCopy() *KoorCephCommandStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorCephCommandStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorCluster) DeepCopyInto(out *KoorCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorCluster.
+func (in *KoorCluster) DeepCopy() *KoorCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorClusterList) DeepCopyInto(out *KoorClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KoorCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorClusterList.
+func (in *KoorClusterList) DeepCopy() *KoorClusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorClusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorClusterSpec) DeepCopyInto(out *KoorClusterSpec) {
+	*out = *in
+	if in.UseAllDevices != nil {
+		in, out := &in.UseAllDevices, &out.UseAllDevices
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MonitoringEnabled != nil {
+		in, out := &in.MonitoringEnabled, &out.MonitoringEnabled
+		*out = new(bool)
+		**out = **in
+	}
+	in.Monitoring.DeepCopyInto(&out.Monitoring)
+	if in.DashboardEnabled != nil {
+		in, out := &in.DashboardEnabled, &out.DashboardEnabled
+		*out = new(bool)
+		**out = **in
+	}
+	in.Dashboard.DeepCopyInto(&out.Dashboard)
+	if in.ToolboxEnabled != nil {
+		in, out := &in.ToolboxEnabled, &out.ToolboxEnabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Toolbox != nil {
+		in, out := &in.Toolbox, &out.Toolbox
+		*out = new(ToolboxSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.UpgradeOptions.DeepCopyInto(&out.UpgradeOptions)
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = make([]NotificationSink, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.CapacityOptions = in.CapacityOptions
+	if in.BlockPools != nil {
+		in, out := &in.BlockPools, &out.BlockPools
+		*out = make([]BlockPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Filesystems != nil {
+		in, out := &in.Filesystems, &out.Filesystems
+		*out = make([]Filesystem, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ObjectStores != nil {
+		in, out := &in.ObjectStores, &out.ObjectStores
+		*out = make([]ObjectStore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StorageClasses != nil {
+		in, out := &in.StorageClasses, &out.StorageClasses
+		*out = make([]StorageClassSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CephConfig != nil {
+		in, out := &in.CephConfig, &out.CephConfig
+		*out = make(CephConfig, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Versions != nil {
+		in, out := &in.Versions, &out.Versions
+		*out = new(PinnedVersions)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorClusterSpec.
+func (in *KoorClusterSpec) DeepCopy() *KoorClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorClusterStatus) DeepCopyInto(out *KoorClusterStatus) {
+	*out = *in
+	in.TotalResources.DeepCopyInto(&out.TotalResources)
+	out.CurrentVersions = in.CurrentVersions
+	if in.LatestVersions != nil {
+		in, out := &in.LatestVersions, &out.LatestVersions
+		*out = new(DetailedProductVersions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingUpgrade != nil {
+		in, out := &in.PendingUpgrade, &out.PendingUpgrade
+		*out = new(UpgradeTarget)
+		**out = **in
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(UpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NextMaintenanceWindow != nil {
+		in, out := &in.NextMaintenanceWindow, &out.NextMaintenanceWindow
+		*out = (*in).DeepCopy()
+	}
+	if in.CephCluster != nil {
+		in, out := &in.CephCluster, &out.CephCluster
+		*out = new(CephClusterStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StorageNodes != nil {
+		in, out := &in.StorageNodes, &out.StorageNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DecommissionedNodes != nil {
+		in, out := &in.DecommissionedNodes, &out.DecommissionedNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DownOsds != nil {
+		in, out := &in.DownOsds, &out.DownOsds
+		*out = make([]DownOsd, len(*in))
+		copy(*out, *in)
+	}
+	if in.NearFullPools != nil {
+		in, out := &in.NearFullPools, &out.NearFullPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StorageClasses != nil {
+		in, out := &in.StorageClasses, &out.StorageClasses
+		*out = make([]StorageClassStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Dashboard != nil {
+		in, out := &in.Dashboard, &out.Dashboard
+		*out = new(DashboardStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CephConfig != nil {
+		in, out := &in.CephConfig, &out.CephConfig
+		*out = make(CephConfig, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = make([]NotificationSinkStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorClusterStatus.
+func (in *KoorClusterStatus) DeepCopy() *KoorClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorMaintenance) DeepCopyInto(out *KoorMaintenance) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorMaintenance.
+func (in *KoorMaintenance) DeepCopy() *KoorMaintenance {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorMaintenance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorMaintenance) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorMaintenanceList) DeepCopyInto(out *KoorMaintenanceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KoorMaintenance, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorMaintenanceList.
+func (in *KoorMaintenanceList) DeepCopy() *KoorMaintenanceList {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorMaintenanceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorMaintenanceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorMaintenanceSpec) DeepCopyInto(out *KoorMaintenanceSpec) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make([]FailureDomain, len(*in))
+		copy(*out, *in)
+	}
+	if in.Drain != nil {
+		in, out := &in.Drain, &out.Drain
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorMaintenanceSpec.
+func (in *KoorMaintenanceSpec) DeepCopy() *KoorMaintenanceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorMaintenanceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorMaintenanceStatus) DeepCopyInto(out *KoorMaintenanceStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CrushBuckets != nil {
+		in, out := &in.CrushBuckets, &out.CrushBuckets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CordonedNodes != nil {
+		in, out := &in.CordonedNodes, &out.CordonedNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DrainedNodes != nil {
+		in, out := &in.DrainedNodes, &out.DrainedNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorMaintenanceStatus.
+func (in *KoorMaintenanceStatus) DeepCopy() *KoorMaintenanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorMaintenanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorNodeDecommission) DeepCopyInto(out *KoorNodeDecommission) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorNodeDecommission.
+func (in *KoorNodeDecommission) DeepCopy() *KoorNodeDecommission {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorNodeDecommission)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorNodeDecommission) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorNodeDecommissionList) DeepCopyInto(out *KoorNodeDecommissionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KoorNodeDecommission, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorNodeDecommissionList.
+func (in *KoorNodeDecommissionList) DeepCopy() *KoorNodeDecommissionList {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorNodeDecommissionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorNodeDecommissionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorNodeDecommissionSpec) DeepCopyInto(out *KoorNodeDecommissionSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorNodeDecommissionSpec.
+func (in *KoorNodeDecommissionSpec) DeepCopy() *KoorNodeDecommissionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorNodeDecommissionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorNodeDecommissionStatus) DeepCopyInto(out *KoorNodeDecommissionStatus) {
+	*out = *in
+	if in.Osds != nil {
+		in, out := &in.Osds, &out.Osds
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.PurgedOsds != nil {
+		in, out := &in.PurgedOsds, &out.PurgedOsds
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorNodeDecommissionStatus.
+func (in *KoorNodeDecommissionStatus) DeepCopy() *KoorNodeDecommissionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorNodeDecommissionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorOsdReplacement) DeepCopyInto(out *KoorOsdReplacement) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorOsdReplacement.
+func (in *KoorOsdReplacement) DeepCopy() *KoorOsdReplacement {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorOsdReplacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorOsdReplacement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorOsdReplacementList) DeepCopyInto(out *KoorOsdReplacementList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KoorOsdReplacement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorOsdReplacementList.
+func (in *KoorOsdReplacementList) DeepCopy() *KoorOsdReplacementList {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorOsdReplacementList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorOsdReplacementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorOsdReplacementSpec) DeepCopyInto(out *KoorOsdReplacementSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorOsdReplacementSpec.
+func (in *KoorOsdReplacementSpec) DeepCopy() *KoorOsdReplacementSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorOsdReplacementSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorOsdReplacementStatus) DeepCopyInto(out *KoorOsdReplacementStatus) {
+	*out = *in
+	if in.HostOsds != nil {
+		in, out := &in.HostOsds, &out.HostOsds
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.NewOsdID != nil {
+		in, out := &in.NewOsdID, &out.NewOsdID
+		*out = new(int)
+		**out = **in
+	}
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorOsdReplacementStatus.
+func (in *KoorOsdReplacementStatus) DeepCopy() *KoorOsdReplacementStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorOsdReplacementStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorSnapshotSchedule) DeepCopyInto(out *KoorSnapshotSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorSnapshotSchedule.
+func (in *KoorSnapshotSchedule) DeepCopy() *KoorSnapshotSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorSnapshotSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorSnapshotSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorSnapshotScheduleList) DeepCopyInto(out *KoorSnapshotScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KoorSnapshotSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorSnapshotScheduleList.
+func (in *KoorSnapshotScheduleList) DeepCopy() *KoorSnapshotScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorSnapshotScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KoorSnapshotScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorSnapshotScheduleSpec) DeepCopyInto(out *KoorSnapshotScheduleSpec) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorSnapshotScheduleSpec.
+func (in *KoorSnapshotScheduleSpec) DeepCopy() *KoorSnapshotScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorSnapshotScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KoorSnapshotScheduleStatus) DeepCopyInto(out *KoorSnapshotScheduleStatus) {
+	*out = *in
+	if in.LastSnapshotTime != nil {
+		in, out := &in.LastSnapshotTime, &out.LastSnapshotTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextSnapshotTime != nil {
+		in, out := &in.NextSnapshotTime, &out.NextSnapshotTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorSnapshotScheduleStatus.
+func (in *KoorSnapshotScheduleStatus) DeepCopy() *KoorSnapshotScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KoorSnapshotScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringSpec) DeepCopyInto(out *MonitoringSpec) {
+	*out = *in
+	if in.Alerts != nil {
+		in, out := &in.Alerts, &out.Alerts
+		*out = new(bool)
+		**out = **in
+	}
+	if in.RuleLabels != nil {
+		in, out := &in.RuleLabels, &out.RuleLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AlertLabels != nil {
+		in, out := &in.AlertLabels, &out.AlertLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	out.AlertThresholds = in.AlertThresholds
+	if in.Dashboards != nil {
+		in, out := &in.Dashboards, &out.Dashboards
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DashboardLabels != nil {
+		in, out := &in.DashboardLabels, &out.DashboardLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringSpec.
+func (in *MonitoringSpec) DeepCopy() *MonitoringSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamedPoolSpec) DeepCopyInto(out *NamedPoolSpec) {
+	*out = *in
+	in.PoolSpec.DeepCopyInto(&out.PoolSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamedPoolSpec.
+func (in *NamedPoolSpec) DeepCopy() *NamedPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NamedPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationDelivery) DeepCopyInto(out *NotificationDelivery) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationDelivery.
+func (in *NotificationDelivery) DeepCopy() *NotificationDelivery {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationDelivery)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationSink) DeepCopyInto(out *NotificationSink) {
+	*out = *in
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]NotificationEvent, len(*in))
+		copy(*out, *in)
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(WebhookSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Slack != nil {
+		in, out := &in.Slack, &out.Slack
+		*out = new(SlackSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Email != nil {
+		in, out := &in.Email, &out.Email
+		*out = new(EmailSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Alertmanager != nil {
+		in, out := &in.Alertmanager, &out.Alertmanager
+		*out = new(AlertmanagerSink)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationSink.
+func (in *NotificationSink) DeepCopy() *NotificationSink {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationSinkStatus) DeepCopyInto(out *NotificationSinkStatus) {
+	*out = *in
+	if in.Delivered != nil {
+		in, out := &in.Delivered, &out.Delivered
+		*out = make(map[NotificationEvent]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
-	if in.Ceph != nil {
-		in, out := &in.Ceph, &out.Ceph
-		*out = new(DetailedVersion)
-		**out = **in
+	if in.LastDelivery != nil {
+		in, out := &in.LastDelivery, &out.LastDelivery
+		*out = new(NotificationDelivery)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DetailedProductVersions.
-func (in *DetailedProductVersions) DeepCopy() *DetailedProductVersions {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationSinkStatus.
+func (in *NotificationSinkStatus) DeepCopy() *NotificationSinkStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(DetailedProductVersions)
+	out := new(NotificationSinkStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DetailedVersion) DeepCopyInto(out *DetailedVersion) {
+func (in *ObjectStore) DeepCopyInto(out *ObjectStore) {
 	*out = *in
+	in.MetadataPool.DeepCopyInto(&out.MetadataPool)
+	in.DataPool.DeepCopyInto(&out.DataPool)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DetailedVersion.
-func (in *DetailedVersion) DeepCopy() *DetailedVersion {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectStore.
+func (in *ObjectStore) DeepCopy() *ObjectStore {
 	if in == nil {
 		return nil
 	}
-	out := new(DetailedVersion)
+	out := new(ObjectStore)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KoorCluster) DeepCopyInto(out *KoorCluster) {
+func (in *PinnedVersions) DeepCopyInto(out *PinnedVersions) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorCluster.
-func (in *KoorCluster) DeepCopy() *KoorCluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinnedVersions.
+func (in *PinnedVersions) DeepCopy() *PinnedVersions {
 	if in == nil {
 		return nil
 	}
-	out := new(KoorCluster)
+	out := new(PinnedVersions)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *KoorCluster) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KoorClusterList) DeepCopyInto(out *KoorClusterList) {
+func (in *PoolSpec) DeepCopyInto(out *PoolSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]KoorCluster, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Replicated != nil {
+		in, out := &in.Replicated, &out.Replicated
+		*out = new(ReplicatedSpec)
+		**out = **in
+	}
+	if in.ErasureCoded != nil {
+		in, out := &in.ErasureCoded, &out.ErasureCoded
+		*out = new(ErasureCodedSpec)
+		**out = **in
+	}
+	if in.Quotas != nil {
+		in, out := &in.Quotas, &out.Quotas
+		*out = new(QuotaSpec)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorClusterList.
-func (in *KoorClusterList) DeepCopy() *KoorClusterList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoolSpec.
+func (in *PoolSpec) DeepCopy() *PoolSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(KoorClusterList)
+	out := new(PoolSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *KoorClusterList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KoorClusterSpec) DeepCopyInto(out *KoorClusterSpec) {
+func (in *PreflightOptions) DeepCopyInto(out *PreflightOptions) {
 	*out = *in
-	if in.UseAllDevices != nil {
-		in, out := &in.UseAllDevices, &out.UseAllDevices
+	if in.RequireHealthOK != nil {
+		in, out := &in.RequireHealthOK, &out.RequireHealthOK
 		*out = new(bool)
 		**out = **in
 	}
-	if in.MonitoringEnabled != nil {
-		in, out := &in.MonitoringEnabled, &out.MonitoringEnabled
+	if in.RequireCleanPGs != nil {
+		in, out := &in.RequireCleanPGs, &out.RequireCleanPGs
 		*out = new(bool)
 		**out = **in
 	}
-	if in.DashboardEnabled != nil {
-		in, out := &in.DashboardEnabled, &out.DashboardEnabled
+	if in.RequireAllOsdsUp != nil {
+		in, out := &in.RequireAllOsdsUp, &out.RequireAllOsdsUp
 		*out = new(bool)
 		**out = **in
 	}
-	if in.ToolboxEnabled != nil {
-		in, out := &in.ToolboxEnabled, &out.ToolboxEnabled
-		*out = new(bool)
-		**out = **in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreflightOptions.
+func (in *PreflightOptions) DeepCopy() *PreflightOptions {
+	if in == nil {
+		return nil
 	}
-	out.UpgradeOptions = in.UpgradeOptions
+	out := new(PreflightOptions)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorClusterSpec.
-func (in *KoorClusterSpec) DeepCopy() *KoorClusterSpec {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProductVersions) DeepCopyInto(out *ProductVersions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProductVersions.
+func (in *ProductVersions) DeepCopy() *ProductVersions {
 	if in == nil {
 		return nil
 	}
-	out := new(KoorClusterSpec)
+	out := new(ProductVersions)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KoorClusterStatus) DeepCopyInto(out *KoorClusterStatus) {
+func (in *QuotaSpec) DeepCopyInto(out *QuotaSpec) {
 	*out = *in
-	in.TotalResources.DeepCopyInto(&out.TotalResources)
-	out.CurrentVersions = in.CurrentVersions
-	if in.LatestVersions != nil {
-		in, out := &in.LatestVersions, &out.LatestVersions
-		*out = new(DetailedProductVersions)
-		(*in).DeepCopyInto(*out)
+	if in.MaxSize != nil {
+		in, out := &in.MaxSize, &out.MaxSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MaxObjects != nil {
+		in, out := &in.MaxObjects, &out.MaxObjects
+		*out = new(int64)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KoorClusterStatus.
-func (in *KoorClusterStatus) DeepCopy() *KoorClusterStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaSpec.
+func (in *QuotaSpec) DeepCopy() *QuotaSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(KoorClusterStatus)
+	out := new(QuotaSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProductVersions) DeepCopyInto(out *ProductVersions) {
+func (in *ReplicatedSpec) DeepCopyInto(out *ReplicatedSpec) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProductVersions.
-func (in *ProductVersions) DeepCopy() *ProductVersions {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicatedSpec.
+func (in *ReplicatedSpec) DeepCopy() *ReplicatedSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ProductVersions)
+	out := new(ReplicatedSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -236,9 +1678,128 @@ func (in *Resources) DeepCopy() *Resources {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SlackSink) DeepCopyInto(out *SlackSink) {
+	*out = *in
+	in.WebhookSink.DeepCopyInto(&out.WebhookSink)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SlackSink.
+func (in *SlackSink) DeepCopy() *SlackSink {
+	if in == nil {
+		return nil
+	}
+	out := new(SlackSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SmokeTestOptions) DeepCopyInto(out *SmokeTestOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmokeTestOptions.
+func (in *SmokeTestOptions) DeepCopy() *SmokeTestOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(SmokeTestOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageClassSpec) DeepCopyInto(out *StorageClassSpec) {
+	*out = *in
+	if in.AllowVolumeExpansion != nil {
+		in, out := &in.AllowVolumeExpansion, &out.AllowVolumeExpansion
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedTopologies != nil {
+		in, out := &in.AllowedTopologies, &out.AllowedTopologies
+		*out = make([]corev1.TopologySelectorTerm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageClassSpec.
+func (in *StorageClassSpec) DeepCopy() *StorageClassSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageClassSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageClassStatus) DeepCopyInto(out *StorageClassStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageClassStatus.
+func (in *StorageClassStatus) DeepCopy() *StorageClassStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageClassStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ToolboxSpec) DeepCopyInto(out *ToolboxSpec) {
+	*out = *in
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ToolboxSpec.
+func (in *ToolboxSpec) DeepCopy() *ToolboxSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ToolboxSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpgradeOptions) DeepCopyInto(out *UpgradeOptions) {
 	*out = *in
+	in.VersionService.DeepCopyInto(&out.VersionService)
+	in.Preflight.DeepCopyInto(&out.Preflight)
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.ApprovedUpgrade != nil {
+		in, out := &in.ApprovedUpgrade, &out.ApprovedUpgrade
+		*out = new(UpgradeTarget)
+		**out = **in
+	}
+	in.Verification.DeepCopyInto(&out.Verification)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeOptions.
@@ -250,3 +1811,136 @@ func (in *UpgradeOptions) DeepCopy() *UpgradeOptions {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeStatus) DeepCopyInto(out *UpgradeStatus) {
+	*out = *in
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]UpgradeStepStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeStatus.
+func (in *UpgradeStatus) DeepCopy() *UpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeStepStatus) DeepCopyInto(out *UpgradeStepStatus) {
+	*out = *in
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeStepStatus.
+func (in *UpgradeStepStatus) DeepCopy() *UpgradeStepStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeStepStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeTarget) DeepCopyInto(out *UpgradeTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeTarget.
+func (in *UpgradeTarget) DeepCopy() *UpgradeTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VerificationOptions) DeepCopyInto(out *VerificationOptions) {
+	*out = *in
+	out.Timeout = in.Timeout
+	if in.RequireHealthOK != nil {
+		in, out := &in.RequireHealthOK, &out.RequireHealthOK
+		*out = new(bool)
+		**out = **in
+	}
+	out.SmokeTest = in.SmokeTest
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerificationOptions.
+func (in *VerificationOptions) DeepCopy() *VerificationOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(VerificationOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VersionServiceOptions) DeepCopyInto(out *VersionServiceOptions) {
+	*out = *in
+	out.Timeout = in.Timeout
+	if in.Retries != nil {
+		in, out := &in.Retries, &out.Retries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PublicKeySecret != nil {
+		in, out := &in.PublicKeySecret, &out.PublicKeySecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VersionServiceOptions.
+func (in *VersionServiceOptions) DeepCopy() *VersionServiceOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(VersionServiceOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookSink) DeepCopyInto(out *WebhookSink) {
+	*out = *in
+	if in.URLSecret != nil {
+		in, out := &in.URLSecret, &out.URLSecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookSink.
+func (in *WebhookSink) DeepCopy() *WebhookSink {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookSink)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// upgradePlan returns the versions that differ from the latest versions or nil if everything is up to date
+func upgradePlan(
+	current storagev1alpha1.ProductVersions,
+	latest *storagev1alpha1.DetailedProductVersions,
+) *storagev1alpha1.UpgradeTarget {
+	if latest == nil {
+		return nil
+	}
+
+	plan := &storagev1alpha1.UpgradeTarget{}
+	if latest.Ksd != nil && latest.Ksd.Version != "" && !sameVersion(latest.Ksd.Version, current.Ksd) {
+		plan.Ksd = latest.Ksd.Version
+	}
+	if latest.Ceph != nil && latest.Ceph.Version != "" && !sameVersion(latest.Ceph.Version, current.Ceph) {
+		plan.Ceph = latest.Ceph.Version
+	}
+
+	if *plan == (storagev1alpha1.UpgradeTarget{}) {
+		return nil
+	}
+	return plan
+}
+
+func formatUpgradeTarget(target *storagev1alpha1.UpgradeTarget) string {
+	var versions []string
+	if target.Ksd != "" {
+		versions = append(versions, "KSD "+target.Ksd)
+	}
+	if target.Ceph != "" {
+		versions = append(versions, "ceph "+target.Ceph)
+	}
+	return strings.Join(versions, " and ")
+}
+
+// reconcileUpgradePlan publishes the pending upgrade in approval mode and checks whether it is approved
+func (r *KoorClusterReconciler) reconcileUpgradePlan(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+	status := &koorCluster.Status
+	options := koorCluster.Spec.UpgradeOptions
+
+	if options.Mode != storagev1alpha1.UpgradeModeApproval {
+		status.PendingUpgrade = nil
+		meta.RemoveStatusCondition(&status.Conditions, storagev1alpha1.ConditionUpgradeApproved)
+		return nil
+	}
+
+	plan := upgradePlan(status.CurrentVersions, status.LatestVersions)
+	status.PendingUpgrade = plan
+	if plan == nil {
+		meta.RemoveStatusCondition(&status.Conditions, storagev1alpha1.ConditionUpgradeApproved)
+		return nil
+	}
+
+	approved := options.ApprovedUpgrade
+	switch {
+	case approved == nil:
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:   storagev1alpha1.ConditionUpgradeApproved,
+			Status: metav1.ConditionFalse,
+			Reason: "WaitingForApproval",
+			Message: fmt.Sprintf("The upgrade to %s waits for approval in spec.upgradeOptions.approvedUpgrade",
+				formatUpgradeTarget(plan)),
+		})
+	case *approved == *plan:
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionUpgradeApproved,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Approved",
+			Message: fmt.Sprintf("The upgrade to %s is approved", formatUpgradeTarget(plan)),
+		})
+	default:
+		message := fmt.Sprintf("The approved upgrade to %s does not match the pending upgrade to %s",
+			formatUpgradeTarget(approved), formatUpgradeTarget(plan))
+		condition := meta.FindStatusCondition(status.Conditions, storagev1alpha1.ConditionUpgradeApproved)
+		if condition == nil || condition.Message != message {
+			// Only notify once for every stale approval
+			log.Info(message)
+			r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "UpgradeApprovalStale", message)
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionUpgradeApproved,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ApprovalStale",
+			Message: message,
+		})
+	}
+	return nil
+}
+
+// approvedUpgrade returns the pending upgrade once it is approved
+func approvedUpgrade(koorCluster *storagev1alpha1.KoorCluster) *storagev1alpha1.UpgradeTarget {
+	if koorCluster.Spec.UpgradeOptions.Mode != storagev1alpha1.UpgradeModeApproval ||
+		!meta.IsStatusConditionTrue(koorCluster.Status.Conditions, storagev1alpha1.ConditionUpgradeApproved) {
+		return nil
+	}
+	return koorCluster.Status.PendingUpgrade
+}
+
+// approvedChartVersion returns the chart version to install in approval mode. The deployed
+// version is kept until the KSD upgrade is approved.
+func approvedChartVersion(koorCluster *storagev1alpha1.KoorCluster, deployedVersion string) string {
+	plan := approvedUpgrade(koorCluster)
+	if plan == nil || plan.Ksd == "" {
+		return deployedVersion
+	}
+	// The version service names the chart version of the KSD version
+	if latest := koorCluster.Status.LatestVersions; latest != nil && latest.Ksd != nil && sameVersion(latest.Ksd.Version, plan.Ksd) {
+		return latest.Ksd.ChartVersion()
+	}
+	return plan.Ksd
+}
+
+// targetCephVersion returns the ceph version the cluster should run: the pinned version or, in approval mode,
+// the approved version. It is empty if the ceph version does not change or follows the chart default.
+func targetCephVersion(koorCluster *storagev1alpha1.KoorCluster) (string, error) {
+	version, err := koorCluster.Spec.Versions.CephVersion()
+	if err != nil || version != "" {
+		return version, err
+	}
+	if plan := approvedUpgrade(koorCluster); plan != nil {
+		return plan.Ceph, nil
+	}
+	return "", nil
+}
+
+// targetCephImage returns the ceph image of the cluster chart values. A pinned image is used as is. In approval
+// mode, a running upgrade keeps its image, an approved ceph upgrade uses the image of the version service, and
+// the cluster otherwise keeps the image it runs, so that a new chart does not change ceph without approval.
+// An empty image keeps the default of the rook-ceph-cluster chart.
+func targetCephImage(koorCluster *storagev1alpha1.KoorCluster) (string, error) {
+	image, err := koorCluster.Spec.Versions.CephImage()
+	if err != nil || image != "" {
+		return image, err
+	}
+	if koorCluster.Spec.UpgradeOptions.Mode != storagev1alpha1.UpgradeModeApproval {
+		return "", nil
+	}
+	if upgrade := koorCluster.Status.Upgrade; upgrade.InProgress() && upgrade.CephImage != "" {
+		return upgrade.CephImage, nil
+	}
+	if plan := approvedUpgrade(koorCluster); plan != nil && plan.Ceph != "" {
+		if latest := koorCluster.Status.LatestVersions; latest != nil && latest.Ceph != nil &&
+			latest.Ceph.ImageUri != "" && sameVersion(latest.Ceph.Version, plan.Ceph) {
+			return latest.Ceph.Image(), nil
+		}
+		return storagev1alpha1.DefaultCephImage + ":" + plan.Ceph, nil
+	}
+	return koorCluster.Status.CephImage, nil
+}
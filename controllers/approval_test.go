@@ -0,0 +1,211 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	hc "github.com/mittwald/go-helm-client"
+	hcmock "github.com/mittwald/go-helm-client/mock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+var _ = Describe("Upgrade approval", func() {
+	var (
+		koorCluster *storagev1alpha1.KoorCluster
+		recorder    *record.FakeRecorder
+		reconciler  *KoorClusterReconciler
+	)
+
+	BeforeEach(func() {
+		recorder = record.NewFakeRecorder(10)
+		reconciler = &KoorClusterReconciler{Recorder: recorder}
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "approval", Namespace: "default"},
+			Spec: storagev1alpha1.KoorClusterSpec{
+				UpgradeOptions: storagev1alpha1.UpgradeOptions{Mode: storagev1alpha1.UpgradeModeApproval},
+			},
+			Status: storagev1alpha1.KoorClusterStatus{
+				CurrentVersions: storagev1alpha1.ProductVersions{Ksd: "v1.11.0", Ceph: "v17.2.5"},
+				LatestVersions: &storagev1alpha1.DetailedProductVersions{
+					Ksd:  &storagev1alpha1.DetailedVersion{Version: "v1.11.1"},
+					Ceph: &storagev1alpha1.DetailedVersion{Version: "v17.2.5"},
+				},
+			},
+		}
+	})
+
+	approvedCondition := func() *metav1.Condition {
+		return meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionUpgradeApproved)
+	}
+
+	It("Should publish the pending upgrade and wait for approval", func() {
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.PendingUpgrade).To(Equal(&storagev1alpha1.UpgradeTarget{Ksd: "v1.11.1"}))
+		Expect(approvedCondition().Reason).To(Equal("WaitingForApproval"))
+		Expect(approvedChartVersion(koorCluster, "v1.11.0")).To(Equal("v1.11.0"))
+	})
+
+	It("Should apply an approval that matches the pending upgrade", func() {
+		koorCluster.Spec.UpgradeOptions.ApprovedUpgrade = &storagev1alpha1.UpgradeTarget{Ksd: "v1.11.1"}
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(approvedCondition().Status).To(Equal(metav1.ConditionTrue))
+		Expect(approvedChartVersion(koorCluster, "v1.11.0")).To(Equal("v1.11.1"))
+	})
+
+	It("Should invalidate the approval when a newer version is available", func() {
+		koorCluster.Spec.UpgradeOptions.ApprovedUpgrade = &storagev1alpha1.UpgradeTarget{Ksd: "v1.11.1"}
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+
+		koorCluster.Status.LatestVersions.Ksd.Version = "v1.11.2"
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.PendingUpgrade).To(Equal(&storagev1alpha1.UpgradeTarget{Ksd: "v1.11.2"}))
+		Expect(approvedCondition().Reason).To(Equal("ApprovalStale"))
+		Expect(approvedChartVersion(koorCluster, "v1.11.0")).To(Equal("v1.11.0"))
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(<-recorder.Events).To(ContainSubstring("UpgradeApprovalStale"))
+	})
+
+	It("Should clear the plan when the cluster is up to date", func() {
+		koorCluster.Status.CurrentVersions.Ksd = "v1.11.1"
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.PendingUpgrade).To(BeNil())
+		Expect(approvedCondition()).To(BeNil())
+	})
+
+	It("Should compare versions without the v prefix", func() {
+		koorCluster.Status.LatestVersions.Ksd = &storagev1alpha1.DetailedVersion{Version: "1.11.1", HelmChartVersion: "v1.11.3"}
+		koorCluster.Status.LatestVersions.Ceph.Version = "17.2.5"
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.PendingUpgrade).To(Equal(&storagev1alpha1.UpgradeTarget{Ksd: "1.11.1"}))
+
+		koorCluster.Spec.UpgradeOptions.ApprovedUpgrade = &storagev1alpha1.UpgradeTarget{Ksd: "1.11.1"}
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(approvedChartVersion(koorCluster, "v1.11.0")).To(Equal("v1.11.3"))
+
+		koorCluster.Status.CurrentVersions.Ksd = "v1.11.1"
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.PendingUpgrade).To(BeNil())
+	})
+
+	It("Should install the approved chart version", func() {
+		mockCtrl := gomock.NewController(GinkgoT())
+		mockHelmClient := hcmock.NewMockClient(mockCtrl)
+		koorCluster.Spec.UpgradeOptions.ApprovedUpgrade = &storagev1alpha1.UpgradeTarget{Ksd: "v1.11.1"}
+		koorCluster.Spec.UpgradeOptions.Preflight.RequireHealthOK = new(bool)
+		koorCluster.Spec.UpgradeOptions.Preflight.RequireCleanPGs = new(bool)
+		koorCluster.Spec.UpgradeOptions.Preflight.RequireAllOsdsUp = new(bool)
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+
+		gomock.InOrder(
+			mockHelmClient.EXPECT().GetRelease("ksd").Return(&release.Release{
+				Chart: &chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.0"}},
+			}, nil),
+			mockHelmClient.EXPECT().GetChart("koor-release/rook-ceph", gomock.Cond(func(x any) bool {
+				return x.(*action.ChartPathOptions).Version == "v1.11.1"
+			})).Return(
+				&chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.1"}}, "", nil),
+			expectRelease(mockHelmClient, "ksd-cluster", "v1.11.0"),
+			expectInstall(mockHelmClient, "ksd", "v1.11.1"),
+		)
+
+		reconciler.Client = newFakeClient()
+		operatorChartSpec := &hc.ChartSpec{ReleaseName: "ksd", ChartName: "koor-release/rook-ceph"}
+		clusterChartSpec := &hc.ChartSpec{ReleaseName: "ksd-cluster", ChartName: "koor-release/rook-ceph-cluster"}
+		Expect(reconciler.reconcileUpgrade(context.Background(), koorCluster, mockHelmClient,
+			operatorChartSpec, clusterChartSpec)).To(Succeed())
+		Expect(koorCluster.Status.Upgrade.ChartVersion).To(Equal("v1.11.1"))
+	})
+
+	It("Should install the chart version of the approved KSD version", func() {
+		koorCluster.Status.LatestVersions.Ksd.HelmChartVersion = "v1.11.3"
+		koorCluster.Spec.UpgradeOptions.ApprovedUpgrade = &storagev1alpha1.UpgradeTarget{Ksd: "v1.11.1"}
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(approvedChartVersion(koorCluster, "v1.11.0")).To(Equal("v1.11.3"))
+	})
+
+	It("Should upgrade ceph to the approved version", func() {
+		const (
+			runningImage = "quay.io/ceph/ceph:v17.2.5"
+			imageHash    = "0f6fd0e0a06ecab1bc8ed8bd2e4a7c8d10ec4d1d7ca7fd8c79c01c5c7bd7b4a5"
+		)
+		cephImage := func() any {
+			cephClusterSpec := renderClusterValues(koorCluster)["cephClusterSpec"].(map[string]any)
+			cephVersion, ok := cephClusterSpec["cephVersion"].(map[string]any)
+			if !ok {
+				return nil
+			}
+			return cephVersion["image"]
+		}
+
+		koorCluster.Status.CurrentVersions.Ksd = "v1.11.1"
+		koorCluster.Status.CephImage = runningImage
+		koorCluster.Status.LatestVersions.Ceph = &storagev1alpha1.DetailedVersion{
+			Version: "v17.2.6", ImageUri: "quay.io/ceph/ceph:v17.2.6", ImageHash: imageHash,
+		}
+		koorCluster.Spec.UpgradeOptions.Preflight.RequireHealthOK = new(bool)
+		koorCluster.Spec.UpgradeOptions.Preflight.RequireCleanPGs = new(bool)
+		koorCluster.Spec.UpgradeOptions.Preflight.RequireAllOsdsUp = new(bool)
+
+		By("keeping the running ceph image until the upgrade is approved")
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.PendingUpgrade).To(Equal(&storagev1alpha1.UpgradeTarget{Ceph: "v17.2.6"}))
+		Expect(cephImage()).To(Equal(runningImage))
+
+		By("rendering the approved ceph image")
+		koorCluster.Spec.UpgradeOptions.ApprovedUpgrade = &storagev1alpha1.UpgradeTarget{Ceph: "v17.2.6"}
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		approvedImage := "quay.io/ceph/ceph:v17.2.6@sha256:" + imageHash
+		Expect(cephImage()).To(Equal(approvedImage))
+
+		By("starting a staged ceph upgrade")
+		mockHelmClient := hcmock.NewMockClient(gomock.NewController(GinkgoT()))
+		mockHelmClient.EXPECT().GetRelease("ksd").Return(&release.Release{
+			Chart: &chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.1"}},
+		}, nil)
+		mockHelmClient.EXPECT().GetChart("koor-release/rook-ceph", gomock.Any()).Return(
+			&chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.1"}}, "", nil)
+		expectRelease(mockHelmClient, "ksd-cluster", "v1.11.1")
+		expectInstall(mockHelmClient, "ksd-cluster", "v1.11.1")
+
+		reconciler.Client = newFakeClient()
+		operatorChartSpec := &hc.ChartSpec{ReleaseName: "ksd", ChartName: "koor-release/rook-ceph"}
+		clusterChartSpec := &hc.ChartSpec{ReleaseName: "ksd-cluster", ChartName: "koor-release/rook-ceph-cluster"}
+		Expect(reconciler.reconcileUpgrade(context.Background(), koorCluster, mockHelmClient,
+			operatorChartSpec, clusterChartSpec)).To(Succeed())
+		upgrade := koorCluster.Status.Upgrade
+		Expect(upgrade.CephVersion).To(Equal("v17.2.6"))
+		Expect(upgrade.CephImage).To(Equal(approvedImage))
+		Expect(upgrade.Step).To(Equal(storagev1alpha1.UpgradeStepWaitForDaemons))
+
+		By("keeping the upgrade image while the upgrade runs")
+		koorCluster.Status.CurrentVersions.Ceph = "v17.2.6"
+		Expect(reconciler.reconcileUpgradePlan(context.Background(), koorCluster)).To(Succeed())
+		Expect(cephImage()).To(Equal(approvedImage))
+	})
+})
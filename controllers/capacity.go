@@ -0,0 +1,105 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+const (
+	// The service and port of the ceph mgr prometheus module, as created by rook
+	cephMgrServiceName = "rook-ceph-mgr"
+	cephMgrMetricsPort = 9283
+)
+
+func cephMgrMetricsEndpoint(namespace string) string {
+	return fmt.Sprintf("http://%s.%s.svc:%d/metrics", cephMgrServiceName, namespace, cephMgrMetricsPort)
+}
+
+// reconcileCapacity adds the pool and OSD usage to the CephCluster status and
+// raises events when the cluster or a pool becomes nearly full.
+func (r *KoorClusterReconciler) reconcileCapacity(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+	cephStatus := koorCluster.Status.CephCluster
+	if cephStatus == nil {
+		koorCluster.Status.NearFullPools = nil
+		meta.RemoveStatusCondition(&koorCluster.Status.Conditions, storagev1alpha1.ConditionNearFull)
+		return nil
+	}
+
+	endpoint := cephMgrMetricsEndpoint(koorCluster.Namespace)
+	usage, err := r.metrics.Usage(ctx, endpoint)
+	if err != nil {
+		// The mgr may not be running yet, the rest of the status is still valid
+		log.Error(err, "unable to read ceph usage", "endpoint", endpoint)
+	} else {
+		cephStatus.Pools = usage.Pools
+		cephStatus.Osds = usage.Osds
+		cephStatus.Pgs = usage.Pgs
+	}
+
+	nearFull, poolNearFull := koorCluster.Spec.CapacityOptions.Thresholds()
+	if percent, ok := cephStatus.Capacity.UsedPercent(); ok {
+		message := fmt.Sprintf("Ceph cluster is %d%% full, the threshold is %d%%", percent, nearFull)
+		if percent < nearFull {
+			meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+				Type:    storagev1alpha1.ConditionNearFull,
+				Status:  metav1.ConditionFalse,
+				Reason:  "BelowThreshold",
+				Message: message,
+			})
+		} else {
+			if !meta.IsStatusConditionTrue(koorCluster.Status.Conditions, storagev1alpha1.ConditionNearFull) {
+				r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "NearFull", message)
+			}
+			meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+				Type:    storagev1alpha1.ConditionNearFull,
+				Status:  metav1.ConditionTrue,
+				Reason:  "NearFull",
+				Message: message,
+			})
+		}
+	}
+
+	// Without the usage the pools are unknown, so the near full pools are kept
+	if err != nil {
+		return nil
+	}
+	previous := koorCluster.Status.NearFullPools
+	var nearFullPools []string
+	for _, pool := range cephStatus.Pools {
+		if percent, ok := pool.QuotaUsedPercent(); ok && percent >= poolNearFull {
+			if !slices.Contains(previous, pool.Name) {
+				r.Recorder.Eventf(koorCluster, corev1.EventTypeWarning, "PoolNearFull",
+					"Pool %s uses %d%% of its quota, the threshold is %d%%", pool.Name, percent, poolNearFull)
+			}
+			nearFullPools = append(nearFullPools, pool.Name)
+		}
+	}
+	koorCluster.Status.NearFullPools = nearFullPools
+
+	return nil
+}
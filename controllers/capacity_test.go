@@ -0,0 +1,92 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+var _ = Describe("Capacity warnings", func() {
+	var (
+		recorder    *record.FakeRecorder
+		reconciler  *KoorClusterReconciler
+		koorCluster *storagev1alpha1.KoorCluster
+	)
+
+	BeforeEach(func() {
+		mockMetrics := mocks.NewMockCephMetrics(gomock.NewController(GinkgoT()))
+		mockMetrics.EXPECT().Usage(gomock.Any(), gomock.Any()).Return(&utils.CephUsage{
+			Pools: []storagev1alpha1.CephPoolUsage{{
+				Name:   "replicapool",
+				Stored: resource.NewQuantity(50, resource.DecimalSI),
+				Quota:  resource.NewQuantity(100, resource.DecimalSI),
+			}},
+		}, nil).AnyTimes()
+		recorder = record.NewFakeRecorder(10)
+		reconciler = &KoorClusterReconciler{Client: newFakeClient(), Recorder: recorder, metrics: mockMetrics}
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "koor", Namespace: "default"},
+			Status: storagev1alpha1.KoorClusterStatus{
+				CephCluster: &storagev1alpha1.CephClusterStatus{
+					Capacity: storagev1alpha1.CephCapacity{
+						Total: resource.NewQuantity(100, resource.DecimalSI),
+						Used:  resource.NewQuantity(50, resource.DecimalSI),
+					},
+				},
+			},
+		}
+	})
+
+	It("Should use the default thresholds without capacity options", func() {
+		Expect(reconciler.reconcileCapacity(context.Background(), koorCluster)).To(Succeed())
+		Expect(recorder.Events).NotTo(Receive())
+	})
+
+	It("Should warn above the thresholds", func() {
+		koorCluster.Spec.CapacityOptions = storagev1alpha1.CapacityOptions{NearFullPercent: 50, PoolNearFullPercent: 40}
+		Expect(reconciler.reconcileCapacity(context.Background(), koorCluster)).To(Succeed())
+		Expect(recorder.Events).To(Receive(ContainSubstring("Warning NearFull Ceph cluster is 50% full")))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Warning PoolNearFull Pool replicapool uses 50% of its quota")))
+		Expect(meta.IsStatusConditionTrue(koorCluster.Status.Conditions, storagev1alpha1.ConditionNearFull)).To(BeTrue())
+		Expect(koorCluster.Status.NearFullPools).To(Equal([]string{"replicapool"}))
+
+		By("Not warning again while the cluster and the pool stay nearly full")
+		Expect(reconciler.reconcileCapacity(context.Background(), koorCluster)).To(Succeed())
+		Expect(recorder.Events).NotTo(Receive())
+
+		By("Warning again once the cluster is nearly full again")
+		koorCluster.Spec.CapacityOptions.NearFullPercent = 60
+		Expect(reconciler.reconcileCapacity(context.Background(), koorCluster)).To(Succeed())
+		Expect(meta.IsStatusConditionFalse(koorCluster.Status.Conditions, storagev1alpha1.ConditionNearFull)).To(BeTrue())
+		koorCluster.Spec.CapacityOptions.NearFullPercent = 50
+		Expect(reconciler.reconcileCapacity(context.Background(), koorCluster)).To(Succeed())
+		Expect(recorder.Events).To(Receive(ContainSubstring("Warning NearFull Ceph cluster is 50% full")))
+		Expect(recorder.Events).NotTo(Receive())
+	})
+})
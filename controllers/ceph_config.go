@@ -0,0 +1,116 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// reconcileCephConfig sets the options of the spec in the ceph config database, so that the daemons
+// apply them without a restart, and removes the options that were removed from the spec
+func (r *KoorClusterReconciler) reconcileCephConfig(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+	status := &koorCluster.Status
+	desired := koorCluster.Spec.CephConfig.Normalized()
+	if len(desired) == 0 && len(status.CephConfig) == 0 {
+		meta.RemoveStatusCondition(&status.Conditions, storagev1alpha1.ConditionCephConfigApplied)
+		return nil
+	}
+	if meta.IsStatusConditionTrue(status.Conditions, storagev1alpha1.ConditionCephConfigApplied) &&
+		equality.Semantic.DeepEqual(desired, status.CephConfig) {
+		return nil
+	}
+	if status.CephCluster == nil {
+		// The options are set once ceph runs
+		return nil
+	}
+
+	if err := r.applyCephConfig(ctx, koorCluster.Namespace, desired, status.CephConfig); err != nil {
+		// The toolbox may not be running, the options are set in the next reconcile
+		log.Error(err, "Cannot apply the ceph config")
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionCephConfigApplied,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ApplyFailed",
+			Message: err.Error(),
+		})
+		r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "CephConfigFailed", err.Error())
+		return nil
+	}
+
+	if len(desired) == 0 {
+		desired = nil
+	}
+	status.CephConfig = desired
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionCephConfigApplied,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Applied",
+		Message: "The ceph options are set in the config database",
+	})
+	r.Recorder.Event(koorCluster, corev1.EventTypeNormal, "CephConfigApplied", "Set the ceph options in the config database")
+	return nil
+}
+
+// applyCephConfig removes the options that are no longer desired and sets the changed options
+func (r *KoorClusterReconciler) applyCephConfig(
+	ctx context.Context,
+	namespace string,
+	desired, applied storagev1alpha1.CephConfig,
+) error {
+	for _, section := range storagev1alpha1.CephConfigSections {
+		for _, name := range sortedOptions(applied[section]) {
+			if _, ok := desired[section][name]; ok {
+				continue
+			}
+			if _, err := r.toolbox.Exec(ctx, namespace, nil, "ceph", "config", "rm", section, name); err != nil {
+				return fmt.Errorf("cannot remove ceph option %s of %s: %w", name, section, err)
+			}
+		}
+	}
+	for _, section := range storagev1alpha1.CephConfigSections {
+		for _, name := range sortedOptions(desired[section]) {
+			value := desired[section][name]
+			if old, ok := applied[section][name]; ok && old == value {
+				continue
+			}
+			if _, err := r.toolbox.Exec(ctx, namespace, nil, "ceph", "config", "set", section, name, value); err != nil {
+				return fmt.Errorf("cannot set ceph option %s of %s: %w", name, section, err)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedOptions(options map[string]string) []string {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+)
+
+var _ = Describe("Ceph config", func() {
+	const namespace = "rook-ceph"
+
+	var (
+		ctx         context.Context
+		recorder    *record.FakeRecorder
+		mockToolbox *mocks.MockCephToolbox
+		reconciler  *KoorClusterReconciler
+		koorCluster *storagev1alpha1.KoorCluster
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		recorder = record.NewFakeRecorder(10)
+		mockToolbox = mocks.NewMockCephToolbox(gomock.NewController(GinkgoT()))
+		reconciler = &KoorClusterReconciler{Recorder: recorder, toolbox: mockToolbox}
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "koor", Namespace: namespace},
+			Spec: storagev1alpha1.KoorClusterSpec{
+				CephConfig: storagev1alpha1.CephConfig{
+					"osd":    {"osd max backfills": "2"},
+					"global": {"osd_pool_default_size": "3"},
+				},
+			},
+			Status: storagev1alpha1.KoorClusterStatus{
+				CephCluster: &storagev1alpha1.CephClusterStatus{},
+			},
+		}
+	})
+
+	It("Should set the changed options and remove the removed options", func() {
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "config", "set", "global", "osd_pool_default_size", "3"),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "config", "set", "osd", "osd_max_backfills", "2"),
+		)
+		Expect(reconciler.reconcileCephConfig(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.CephConfig).To(Equal(storagev1alpha1.CephConfig{
+			"osd":    {"osd_max_backfills": "2"},
+			"global": {"osd_pool_default_size": "3"},
+		}))
+		Expect(meta.IsStatusConditionTrue(koorCluster.Status.Conditions,
+			storagev1alpha1.ConditionCephConfigApplied)).To(BeTrue())
+
+		// Nothing changed
+		Expect(reconciler.reconcileCephConfig(ctx, koorCluster)).To(Succeed())
+
+		koorCluster.Spec.CephConfig = storagev1alpha1.CephConfig{"osd": {"osd_max_backfills": "4"}}
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "config", "rm", "global", "osd_pool_default_size"),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "config", "set", "osd", "osd_max_backfills", "4"),
+		)
+		Expect(reconciler.reconcileCephConfig(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.CephConfig).To(Equal(storagev1alpha1.CephConfig{"osd": {"osd_max_backfills": "4"}}))
+	})
+
+	It("Should retry when the options cannot be set", func() {
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "config", "set", "global", "osd_pool_default_size", "3").
+			Return("", errors.New("no ceph toolbox is running in namespace rook-ceph"))
+		Expect(reconciler.reconcileCephConfig(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.CephConfig).To(BeEmpty())
+		condition := meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionCephConfigApplied)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(recorder.Events).To(Receive(ContainSubstring("CephConfigFailed")))
+	})
+
+	It("Should leave the options of the spec out of ceph.conf", func() {
+		configOverride := renderClusterValues(koorCluster)["configOverride"]
+		Expect(configOverride).To(Equal("[global]\n" +
+			"mon_allow_pool_delete = false\n" +
+			"osd_pool_default_min_size = 1\n" +
+			"osd_pool_default_pg_autoscale_mode = warn\n"))
+	})
+})
@@ -0,0 +1,200 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// The rook CephCluster kind. We use unstructured objects to avoid depending on the rook api.
+var cephClusterGVK = schema.GroupVersionKind{
+	Group:   "ceph.rook.io",
+	Version: "v1",
+	Kind:    "CephCluster",
+}
+
+// getCephCluster returns the CephCluster in the namespace or nil if there is none,
+// including when the rook CRDs are not installed yet.
+func (r *KoorClusterReconciler) getCephCluster(ctx context.Context, namespace string) (*unstructured.Unstructured, error) {
+	return findCephCluster(ctx, r.Client, namespace)
+}
+
+func findCephCluster(ctx context.Context, c client.Reader, namespace string) (*unstructured.Unstructured, error) {
+	cephClusterList := &unstructured.UnstructuredList{}
+	cephClusterList.SetGroupVersionKind(cephClusterGVK.GroupVersion().WithKind(cephClusterGVK.Kind + "List"))
+	if err := c.List(ctx, cephClusterList, client.InNamespace(namespace)); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if len(cephClusterList.Items) == 0 {
+		return nil, nil
+	}
+	// rook only supports one CephCluster per namespace
+	return &cephClusterList.Items[0], nil
+}
+
+// reconcileCephCluster copies the state of the CephCluster into the KoorCluster status
+func (r *KoorClusterReconciler) reconcileCephCluster(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+
+	if err := r.watchCephClusters(); err != nil {
+		log.Error(err, "Cannot watch CephClusters")
+		return err
+	}
+
+	cephCluster, err := r.getCephCluster(ctx, koorCluster.Namespace)
+	if err != nil {
+		log.Error(err, "unable to fetch CephCluster")
+		return err
+	}
+	if cephCluster == nil {
+		koorCluster.Status.CephCluster = nil
+		return nil
+	}
+
+	koorCluster.Status.CephCluster = getCephClusterStatus(cephCluster)
+	return nil
+}
+
+func getCephClusterStatus(cephCluster *unstructured.Unstructured) *storagev1alpha1.CephClusterStatus {
+	obj := cephCluster.Object
+	status := &storagev1alpha1.CephClusterStatus{
+		Name: cephCluster.GetName(),
+	}
+	status.Phase, _, _ = unstructured.NestedString(obj, "status", "phase")
+	health, _, _ := unstructured.NestedString(obj, "status", "ceph", "health")
+	status.Health = storagev1alpha1.CephHealth(health)
+
+	details, _, _ := unstructured.NestedMap(obj, "status", "ceph", "details")
+	for name := range details {
+		check := storagev1alpha1.CephHealthCheck{Name: name}
+		severity, _, _ := unstructured.NestedString(details, name, "severity")
+		check.Severity = storagev1alpha1.CephHealth(severity)
+		check.Message, _, _ = unstructured.NestedString(details, name, "message")
+		status.HealthChecks = append(status.HealthChecks, check)
+	}
+	sort.Slice(status.HealthChecks, func(i, j int) bool {
+		return status.HealthChecks[i].Name < status.HealthChecks[j].Name
+	})
+
+	status.Capacity.Total = nestedQuantity(obj, "status", "ceph", "capacity", "bytesTotal")
+	status.Capacity.Used = nestedQuantity(obj, "status", "ceph", "capacity", "bytesUsed")
+	status.Capacity.Available = nestedQuantity(obj, "status", "ceph", "capacity", "bytesAvailable")
+	return status
+}
+
+// nestedQuantity returns a numeric field as a binary quantity or nil if it is not set
+func nestedQuantity(obj map[string]any, fields ...string) *resource.Quantity {
+	value, found, err := unstructured.NestedFieldNoCopy(obj, fields...)
+	if err != nil || !found {
+		return nil
+	}
+	switch v := value.(type) {
+	case int64:
+		return resource.NewQuantity(v, resource.BinarySI)
+	case float64:
+		return resource.NewQuantity(int64(v), resource.BinarySI)
+	}
+	return nil
+}
+
+// watchCephClusters starts watching CephClusters once the rook CRDs are installed.
+// The watch cannot be set up with the manager because the CRDs are installed by the
+// rook-ceph chart, and watching a missing kind fails the controller.
+func (r *KoorClusterReconciler) watchCephClusters() error {
+	r.watchMutex.Lock()
+	defer r.watchMutex.Unlock()
+	if r.controller == nil || r.watchingCephClusters {
+		return nil
+	}
+
+	if _, err := r.RESTMapper().RESTMapping(cephClusterGVK.GroupKind(), cephClusterGVK.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			// Try again on the next reconcile
+			return nil
+		}
+		return err
+	}
+
+	cephCluster := &unstructured.Unstructured{}
+	cephCluster.SetGroupVersionKind(cephClusterGVK)
+	err := r.controller.Watch(
+		source.Kind(r.cache, cephCluster),
+		handler.EnqueueRequestsFromMapFunc(r.findKoorClustersInNamespace),
+		predicate.Funcs{
+			UpdateFunc: func(ue event.UpdateEvent) bool {
+				oldCephCluster, ok := ue.ObjectOld.(*unstructured.Unstructured)
+				if !ok {
+					return false
+				}
+				newCephCluster, ok := ue.ObjectNew.(*unstructured.Unstructured)
+				if !ok {
+					return false
+				}
+				return !reflect.DeepEqual(oldCephCluster.Object["status"], newCephCluster.Object["status"])
+			},
+			GenericFunc: func(ge event.GenericEvent) bool {
+				return false
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	r.watchingCephClusters = true
+	return nil
+}
+
+func (r *KoorClusterReconciler) findKoorClustersInNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	koorClusterList := &storagev1alpha1.KoorClusterList{}
+	if err := r.List(ctx, koorClusterList, client.InNamespace(obj.GetNamespace())); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(koorClusterList.Items))
+	for i := range koorClusterList.Items {
+		item := &koorClusterList.Items[i]
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      item.GetName(),
+				Namespace: item.GetNamespace(),
+			},
+		}
+	}
+	return requests
+}
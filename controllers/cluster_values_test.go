@@ -0,0 +1,161 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// renderClusterValues renders the values of the rook-ceph-cluster chart like reconcileHelm
+func renderClusterValues(koorCluster *storagev1alpha1.KoorCluster) map[string]any {
+	templates, err := parseValueTemplates()
+	Expect(err).NotTo(HaveOccurred())
+	buffer := new(bytes.Buffer)
+	Expect(templates.ExecuteTemplate(buffer, "clusterValues.yaml", koorCluster)).To(Succeed())
+	clusterValues := map[string]any{}
+	Expect(yaml.Unmarshal(buffer.Bytes(), &clusterValues)).To(Succeed())
+	return clusterValues
+}
+
+var _ = Describe("Cluster values", func() {
+	var koorCluster *storagev1alpha1.KoorCluster
+
+	BeforeEach(func() {
+		koorCluster = &storagev1alpha1.KoorCluster{ObjectMeta: metav1.ObjectMeta{Name: "koor", Namespace: "rook-ceph"}}
+	})
+
+	It("Should run the toolbox with the ceph image of the cluster", func() {
+		toolbox := renderClusterValues(koorCluster)["toolbox"].(map[string]any)
+		Expect(toolbox).NotTo(HaveKey("image"))
+		Expect(toolbox).To(HaveKeyWithValue("tolerations", BeEmpty()))
+		Expect(toolbox).To(HaveKeyWithValue("affinity", BeEmpty()))
+
+		By("Not running a newer ceph than the cluster")
+		koorCluster.Status.LatestVersions = &storagev1alpha1.DetailedProductVersions{
+			Ceph: &storagev1alpha1.DetailedVersion{Version: "18.2.0", ImageUri: "quay.io/ceph/ceph:v18.2.0"},
+		}
+		toolbox = renderClusterValues(koorCluster)["toolbox"].(map[string]any)
+		Expect(toolbox).NotTo(HaveKey("image"))
+
+		koorCluster.Status.CephImage = "quay.io/ceph/ceph:v17.2.6"
+		koorCluster.Spec.Toolbox = &storagev1alpha1.ToolboxSpec{
+			Tolerations: []corev1.Toleration{{Key: "storage", Operator: corev1.TolerationOpExists}},
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+		}
+		toolbox = renderClusterValues(koorCluster)["toolbox"].(map[string]any)
+		Expect(toolbox).To(HaveKeyWithValue("image", "quay.io/ceph/ceph:v17.2.6"))
+		Expect(toolbox).To(HaveKeyWithValue("tolerations", []any{
+			map[string]any{"key": "storage", "operator": "Exists"},
+		}))
+		Expect(toolbox).To(HaveKeyWithValue("affinity", BeEmpty()))
+		Expect(toolbox).To(HaveKeyWithValue("resources", map[string]any{
+			"limits": map[string]any{"memory": "1Gi"},
+		}))
+	})
+
+	It("Should keep the chart defaults for pools, filesystems and object stores", func() {
+		clusterValues := renderClusterValues(koorCluster)
+		Expect(clusterValues).NotTo(HaveKey("cephBlockPools"))
+		Expect(clusterValues).NotTo(HaveKey("cephFileSystems"))
+		Expect(clusterValues).NotTo(HaveKey("cephObjectStores"))
+	})
+
+	It("Should render the pools, filesystems and object stores", func() {
+		maxSize := resource.MustParse("100Gi")
+		replicated := storagev1alpha1.PoolSpec{
+			FailureDomain: "host",
+			Replicated:    &storagev1alpha1.ReplicatedSpec{Size: 3},
+		}
+		erasureCoded := storagev1alpha1.PoolSpec{
+			FailureDomain: "host",
+			DeviceClass:   "hdd",
+			ErasureCoded:  &storagev1alpha1.ErasureCodedSpec{DataChunks: 2, CodingChunks: 1},
+			Quotas:        &storagev1alpha1.QuotaSpec{MaxSize: &maxSize},
+		}
+		koorCluster.Spec.BlockPools = []storagev1alpha1.BlockPool{
+			{Name: "replicapool", PoolSpec: replicated},
+			{Name: "ecpool", PoolSpec: erasureCoded},
+		}
+		koorCluster.Spec.Filesystems = []storagev1alpha1.Filesystem{{
+			Name:         "cephfs",
+			MetadataPool: replicated,
+			DataPools: []storagev1alpha1.NamedPoolSpec{
+				{Name: "default", PoolSpec: replicated},
+				{Name: "ec", PoolSpec: erasureCoded},
+			},
+		}}
+		koorCluster.Spec.ObjectStores = []storagev1alpha1.ObjectStore{{
+			Name:         "s3",
+			MetadataPool: replicated,
+			DataPool:     erasureCoded,
+			Instances:    2,
+		}}
+
+		clusterValues := renderClusterValues(koorCluster)
+
+		Expect(clusterValues["cephBlockPools"]).To(HaveLen(2))
+		blockPool := clusterValues["cephBlockPools"].([]any)[1].(map[string]any)
+		Expect(blockPool).To(HaveKeyWithValue("name", "ecpool"))
+		Expect(blockPool).To(HaveKeyWithValue("spec", map[string]any{
+			"failureDomain": "host",
+			"deviceClass":   "hdd",
+			"erasureCoded":  map[string]any{"dataChunks": float64(2), "codingChunks": float64(1)},
+			"quotas":        map[string]any{"maxSize": "100Gi"},
+		}))
+		Expect(blockPool["storageClass"]).To(HaveKeyWithValue("enabled", false))
+		replicaPool := clusterValues["cephBlockPools"].([]any)[0].(map[string]any)
+		Expect(replicaPool["storageClass"]).To(HaveKeyWithValue("enabled", true))
+		Expect(replicaPool["storageClass"]).To(HaveKeyWithValue("parameters",
+			HaveKeyWithValue("csi.storage.k8s.io/provisioner-secret-namespace", "rook-ceph")))
+
+		filesystem := clusterValues["cephFileSystems"].([]any)[0].(map[string]any)
+		Expect(filesystem["spec"]).To(HaveKeyWithValue("dataPools", HaveLen(2)))
+		Expect(filesystem["spec"]).To(HaveKeyWithValue("metadataServer",
+			map[string]any{"activeCount": float64(1), "activeStandby": true}))
+		Expect(filesystem["storageClass"]).To(HaveKeyWithValue("pool", "ec"))
+
+		objectStore := clusterValues["cephObjectStores"].([]any)[0].(map[string]any)
+		Expect(objectStore["spec"]).To(HaveKeyWithValue("metadataPool", map[string]any{
+			"failureDomain": "host",
+			"replicated":    map[string]any{"size": float64(3)},
+		}))
+		Expect(objectStore["spec"]).To(HaveKeyWithValue("gateway", map[string]any{
+			"port": float64(objectStorePort), "instances": float64(2),
+		}))
+	})
+
+	It("Should leave the StorageClasses to the operator once they are in the spec", func() {
+		koorCluster.Spec.BlockPools = []storagev1alpha1.BlockPool{{
+			Name:     "replicapool",
+			PoolSpec: storagev1alpha1.PoolSpec{Replicated: &storagev1alpha1.ReplicatedSpec{Size: 3}},
+		}}
+		koorCluster.Spec.StorageClasses = []storagev1alpha1.StorageClassSpec{{Name: "fast", BlockPool: "replicapool"}}
+		blockPool := renderClusterValues(koorCluster)["cephBlockPools"].([]any)[0].(map[string]any)
+		Expect(blockPool["storageClass"]).To(HaveKeyWithValue("enabled", false))
+	})
+})
@@ -0,0 +1,425 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+const (
+	// The Service that rook creates for the dashboard of the active manager
+	dashboardServiceName = "rook-ceph-mgr-dashboard"
+	dashboardPort        = 8443
+	// The LoadBalancer Service of the operator
+	dashboardExternalServiceName = "rook-ceph-mgr-dashboard-external"
+	dashboardIngressName         = "rook-ceph-mgr-dashboard"
+	// The certificate of the issuer is stored in this Secret
+	dashboardTLSSecretName = "rook-ceph-dashboard-tls"
+	// The Secret with the password of the dashboard user that rook creates
+	dashboardPasswordSecretName = "rook-ceph-dashboard-password"
+	dashboardPasswordKey        = "password"
+	dashboardUsername           = "admin"
+	// The annotation of the password Secret with the time the operator rotated the password
+	passwordRotatedAtAnnotation = "storage.koor.tech/password-rotated-at"
+)
+
+// The certificates of cert-manager, which is optional
+var certificateGVK = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1",
+	Kind:    "Certificate",
+}
+
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+
+// reconcileDashboard exposes the dashboard through an Ingress or a LoadBalancer Service
+// and publishes its URL and the Secret with the password
+func (r *KoorClusterReconciler) reconcileDashboard(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+	spec := koorCluster.Spec.Dashboard
+	exposure := spec.Exposure()
+	if !isEnabled(koorCluster.Spec.DashboardEnabled) {
+		exposure = storagev1alpha1.DashboardExposureNone
+	}
+
+	tlsSecretName, err := r.reconcileDashboardCertificate(ctx, koorCluster, exposure)
+	if err != nil {
+		log.Error(err, "Cannot reconcile the dashboard certificate")
+		return err
+	}
+
+	previous := koorCluster.Status.Dashboard
+	if previous == nil {
+		previous = &storagev1alpha1.DashboardStatus{}
+	}
+	status := &storagev1alpha1.DashboardStatus{}
+
+	ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: dashboardIngressName, Namespace: koorCluster.Namespace}}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: dashboardExternalServiceName, Namespace: koorCluster.Namespace}}
+	switch exposure {
+	case storagev1alpha1.DashboardExposureIngress:
+		if err := r.applyDashboardIngress(ctx, koorCluster, ingress, tlsSecretName); err != nil {
+			log.Error(err, "Cannot apply the dashboard Ingress")
+			return err
+		}
+		if err := r.deleteOwned(ctx, koorCluster, service); err != nil {
+			return err
+		}
+		scheme := "http"
+		if tlsSecretName != "" {
+			scheme = "https"
+		}
+		status.URL = fmt.Sprintf("%s://%s/", scheme, spec.Host)
+
+	case storagev1alpha1.DashboardExposureLoadBalancer:
+		if err := r.applyDashboardService(ctx, koorCluster, service); err != nil {
+			log.Error(err, "Cannot apply the dashboard Service")
+			return err
+		}
+		if err := r.deleteOwned(ctx, koorCluster, ingress); err != nil {
+			return err
+		}
+		status.InstalledCertificate = previous.InstalledCertificate
+		if tlsSecretName != "" {
+			installed, err := r.installDashboardCertificate(ctx, koorCluster, tlsSecretName, previous.InstalledCertificate)
+			if err != nil {
+				// The toolbox may not be running, the certificate is installed in the next reconcile
+				log.Error(err, "Cannot install the dashboard certificate")
+				r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "DashboardCertificateFailed", err.Error())
+			} else {
+				status.InstalledCertificate = installed
+			}
+		}
+		if address := loadBalancerAddress(service, spec.Host); address != "" {
+			status.URL = fmt.Sprintf("https://%s/", net.JoinHostPort(address, strconv.Itoa(dashboardPort)))
+		}
+
+	default:
+		if err := r.deleteOwned(ctx, koorCluster, ingress); err != nil {
+			return err
+		}
+		if err := r.deleteOwned(ctx, koorCluster, service); err != nil {
+			return err
+		}
+		status.URL = fmt.Sprintf("https://%s.%s.svc:%d/", dashboardServiceName, koorCluster.Namespace, dashboardPort)
+	}
+
+	if !isEnabled(koorCluster.Spec.DashboardEnabled) {
+		koorCluster.Status.Dashboard = nil
+		return nil
+	}
+	if spec.ExternalURL != "" {
+		status.URL = spec.ExternalURL
+	}
+
+	secret := &corev1.Secret{}
+	err = r.Get(ctx, client.ObjectKey{Name: dashboardPasswordSecretName, Namespace: koorCluster.Namespace}, secret)
+	switch {
+	case k8serrors.IsNotFound(err):
+		// Rook creates the Secret with the dashboard
+	case err != nil:
+		log.Error(err, "Cannot get the dashboard password Secret")
+		return err
+	default:
+		status.Username = dashboardUsername
+		status.PasswordSecret = &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: dashboardPasswordSecretName},
+			Key:                  dashboardPasswordKey,
+		}
+		if rotatedAt, err := time.Parse(time.RFC3339, secret.Annotations[passwordRotatedAtAnnotation]); err == nil {
+			status.PasswordRotatedAt = &metav1.Time{Time: rotatedAt}
+		}
+	}
+	koorCluster.Status.Dashboard = status
+	return nil
+}
+
+// reconcileDashboardCertificate returns the TLS Secret of the dashboard. With an issuer, it creates
+// a cert-manager Certificate that stores the certificate in the Secret.
+func (r *KoorClusterReconciler) reconcileDashboardCertificate(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	exposure storagev1alpha1.DashboardExposure,
+) (string, error) {
+	spec := koorCluster.Spec.Dashboard
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetName(dashboardTLSSecretName)
+	certificate.SetNamespace(koorCluster.Namespace)
+
+	if exposure == storagev1alpha1.DashboardExposureNone || spec.Issuer == nil {
+		if err := r.deleteOwned(ctx, koorCluster, certificate); err != nil && !meta.IsNoMatchError(err) {
+			return "", err
+		}
+		if exposure == storagev1alpha1.DashboardExposureNone {
+			return "", nil
+		}
+		return spec.TLSSecretName, nil
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, certificate, func() error {
+		kind := spec.Issuer.Kind
+		if kind == "" {
+			kind = "Issuer"
+		}
+		certificate.Object["spec"] = map[string]any{
+			"secretName": dashboardTLSSecretName,
+			"dnsNames":   []any{spec.Host},
+			"issuerRef": map[string]any{
+				"name":  spec.Issuer.Name,
+				"kind":  kind,
+				"group": certificateGVK.Group,
+			},
+		}
+		return controllerutil.SetControllerReference(koorCluster, certificate, r.Scheme)
+	})
+	if meta.IsNoMatchError(err) {
+		r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "DashboardCertificateFailed",
+			"Cannot create the dashboard certificate, cert-manager is not installed")
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return dashboardTLSSecretName, nil
+}
+
+func (r *KoorClusterReconciler) applyDashboardIngress(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	ingress *networkingv1.Ingress,
+	tlsSecretName string,
+) error {
+	spec := koorCluster.Spec.Dashboard
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, ingress, func() error {
+		// The dashboard serves HTTPS with a self-signed certificate
+		ingress.Annotations = map[string]string{"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS"}
+		for key, value := range spec.Annotations {
+			ingress.Annotations[key] = value
+		}
+		pathType := networkingv1.PathTypePrefix
+		ingress.Spec = networkingv1.IngressSpec{
+			IngressClassName: spec.IngressClassName,
+			Rules: []networkingv1.IngressRule{{
+				Host: spec.Host,
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: dashboardServiceName,
+							Port: networkingv1.ServiceBackendPort{Number: dashboardPort},
+						}},
+					}},
+				}},
+			}},
+		}
+		if tlsSecretName != "" {
+			ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{spec.Host}, SecretName: tlsSecretName}}
+		}
+		return controllerutil.SetControllerReference(koorCluster, ingress, r.Scheme)
+	})
+	return err
+}
+
+func (r *KoorClusterReconciler) applyDashboardService(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	service *corev1.Service,
+) error {
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		service.Annotations = koorCluster.Spec.Dashboard.Annotations
+		service.Spec.Type = corev1.ServiceTypeLoadBalancer
+		// The labels of the active manager, like the Service of rook
+		service.Spec.Selector = map[string]string{
+			"app":          "rook-ceph-mgr",
+			"rook_cluster": koorCluster.Namespace,
+			"mgr_role":     "active",
+		}
+		service.Spec.Ports = []corev1.ServicePort{{
+			Name:       "https-dashboard",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       dashboardPort,
+			TargetPort: intstr.FromInt(dashboardPort),
+		}}
+		return controllerutil.SetControllerReference(koorCluster, service, r.Scheme)
+	})
+	return err
+}
+
+// loadBalancerAddress returns the host if set, otherwise the address the load balancer reports
+func loadBalancerAddress(service *corev1.Service, host string) string {
+	if host != "" {
+		return host
+	}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.Hostname != "" {
+			return ingress.Hostname
+		}
+		if ingress.IP != "" {
+			return ingress.IP
+		}
+	}
+	return ""
+}
+
+// installDashboardCertificate sets the certificate of the Secret in the dashboard module, which serves
+// the LoadBalancer Service directly. It returns the installed version of the Secret.
+func (r *KoorClusterReconciler) installDashboardCertificate(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	secretName, installed string,
+) (string, error) {
+	namespace := koorCluster.Namespace
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Name: secretName, Namespace: namespace}, secret); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return installed, fmt.Errorf("the dashboard TLS Secret %s does not exist yet", secretName)
+		}
+		return installed, err
+	}
+	version := fmt.Sprintf("%s/%s", secretName, secret.ResourceVersion)
+	if version == installed {
+		return installed, nil
+	}
+
+	// The private key is passed on stdin, so that it does not show up in the arguments of the ceph command
+	for _, configKey := range []struct {
+		name string
+		data []byte
+	}{
+		{"mgr/dashboard/crt", secret.Data[corev1.TLSCertKey]},
+		{"mgr/dashboard/key", secret.Data[corev1.TLSPrivateKeyKey]},
+	} {
+		if _, err := r.toolbox.Exec(ctx, namespace, bytes.NewReader(configKey.data),
+			"ceph", "config-key", "set", configKey.name, "-i", "-"); err != nil {
+			return installed, err
+		}
+	}
+	// The dashboard loads the certificate when the module starts
+	for _, command := range [][]string{
+		{"ceph", "mgr", "module", "disable", "dashboard"},
+		{"ceph", "mgr", "module", "enable", "dashboard"},
+	} {
+		if _, err := r.toolbox.Exec(ctx, namespace, nil, command...); err != nil {
+			return installed, err
+		}
+	}
+	r.Recorder.Eventf(koorCluster, corev1.EventTypeNormal, "DashboardCertificateInstalled",
+		"Installed the certificate of Secret %s in the dashboard", secretName)
+	return version, nil
+}
+
+// deleteOwned deletes the object if the KoorCluster controls it
+func (r *KoorClusterReconciler) deleteOwned(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster, obj client.Object) error {
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, koorCluster) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, obj))
+}
+
+// reconcileRotateDashboardPassword sets a new random password for the dashboard user when the
+// KoorCluster has the rotation annotation, stores it in the password Secret and removes the annotation
+func (r *KoorClusterReconciler) reconcileRotateDashboardPassword(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+	value, ok := koorCluster.Annotations[storagev1alpha1.RotateDashboardPasswordAnnotation]
+	if !ok {
+		return nil
+	}
+
+	if value == "true" {
+		if err := r.rotateDashboardPassword(ctx, koorCluster); err != nil {
+			// The annotation is kept, so the rotation is retried
+			log.Error(err, "Cannot rotate the dashboard password")
+			r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "DashboardPasswordRotationFailed", err.Error())
+			return nil
+		}
+		r.Recorder.Eventf(koorCluster, corev1.EventTypeNormal, "DashboardPasswordRotated",
+			"Rotated the password of the dashboard user %s, it is stored in Secret %s", dashboardUsername, dashboardPasswordSecretName)
+	}
+
+	delete(koorCluster.Annotations, storagev1alpha1.RotateDashboardPasswordAnnotation)
+	return r.Update(ctx, koorCluster)
+}
+
+func (r *KoorClusterReconciler) rotateDashboardPassword(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	namespace := koorCluster.Namespace
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Name: dashboardPasswordSecretName, Namespace: namespace}, secret); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return fmt.Errorf("the dashboard password Secret %s does not exist yet", dashboardPasswordSecretName)
+		}
+		return err
+	}
+
+	password, err := generatePassword()
+	if err != nil {
+		return err
+	}
+	// The password is passed on stdin, so that it does not show up in the arguments of the ceph command
+	if _, err := r.toolbox.Exec(ctx, namespace, strings.NewReader(password),
+		"ceph", "dashboard", "ac-user-set-password", dashboardUsername, "-i", "-", "--force-password"); err != nil {
+		return err
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[dashboardPasswordKey] = []byte(password)
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[passwordRotatedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	return r.Update(ctx, secret)
+}
+
+// generatePassword returns a random password of 24 characters
+func generatePassword() (string, error) {
+	buffer := make([]byte, 18)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+)
+
+var _ = Describe("Dashboard", func() {
+	const namespace = "rook-ceph"
+
+	var (
+		ctx         context.Context
+		k8sClient   client.Client
+		recorder    *record.FakeRecorder
+		mockToolbox *mocks.MockCephToolbox
+		reconciler  *KoorClusterReconciler
+		koorCluster *storagev1alpha1.KoorCluster
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		scheme.AddKnownTypeWithName(certificateGVK, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(certificateGVK.GroupVersion().WithKind("CertificateList"), &unstructured.UnstructuredList{})
+
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "koor", Namespace: namespace, UID: "koor-uid"},
+		}
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			koorCluster,
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: dashboardPasswordSecretName, Namespace: namespace},
+				Data:       map[string][]byte{dashboardPasswordKey: []byte("initial")},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "dashboard-tls", Namespace: namespace},
+				Data: map[string][]byte{
+					corev1.TLSCertKey:       []byte("certificate"),
+					corev1.TLSPrivateKeyKey: []byte("key"),
+				},
+			},
+		).Build()
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(koorCluster), koorCluster)).To(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		mockToolbox = mocks.NewMockCephToolbox(gomock.NewController(GinkgoT()))
+		reconciler = &KoorClusterReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder, toolbox: mockToolbox}
+	})
+
+	It("Should publish the internal URL and the password Secret", func() {
+		Expect(reconciler.reconcileDashboard(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.Dashboard).To(Equal(&storagev1alpha1.DashboardStatus{
+			URL:      "https://rook-ceph-mgr-dashboard.rook-ceph.svc:8443/",
+			Username: "admin",
+			PasswordSecret: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: dashboardPasswordSecretName},
+				Key:                  dashboardPasswordKey,
+			},
+		}))
+	})
+
+	It("Should expose the dashboard through an Ingress with a certificate of the issuer", func() {
+		koorCluster.Spec.Dashboard = storagev1alpha1.DashboardSpec{
+			Expose:      storagev1alpha1.DashboardExposureIngress,
+			Host:        "ceph.example.com",
+			Annotations: map[string]string{"example.com/team": "storage"},
+			Issuer:      &storagev1alpha1.IssuerReference{Name: "letsencrypt", Kind: "ClusterIssuer"},
+		}
+		Expect(reconciler.reconcileDashboard(ctx, koorCluster)).To(Succeed())
+
+		ingress := &networkingv1.Ingress{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: dashboardIngressName, Namespace: namespace}, ingress)).To(Succeed())
+		Expect(ingress.Annotations).To(HaveKeyWithValue("example.com/team", "storage"))
+		Expect(ingress.Annotations).To(HaveKeyWithValue("nginx.ingress.kubernetes.io/backend-protocol", "HTTPS"))
+		Expect(ingress.Spec.Rules[0].Host).To(Equal("ceph.example.com"))
+		Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal(dashboardServiceName))
+		Expect(ingress.Spec.TLS).To(Equal([]networkingv1.IngressTLS{
+			{Hosts: []string{"ceph.example.com"}, SecretName: dashboardTLSSecretName},
+		}))
+
+		certificate := &unstructured.Unstructured{}
+		certificate.SetGroupVersionKind(certificateGVK)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: dashboardTLSSecretName, Namespace: namespace}, certificate)).To(Succeed())
+		Expect(certificate.Object["spec"]).To(HaveKeyWithValue("issuerRef", map[string]any{
+			"name": "letsencrypt", "kind": "ClusterIssuer", "group": "cert-manager.io",
+		}))
+		Expect(koorCluster.Status.Dashboard.URL).To(Equal("https://ceph.example.com/"))
+
+		koorCluster.Spec.Dashboard = storagev1alpha1.DashboardSpec{}
+		Expect(reconciler.reconcileDashboard(ctx, koorCluster)).To(Succeed())
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: dashboardIngressName, Namespace: namespace}, ingress)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: dashboardTLSSecretName, Namespace: namespace}, certificate)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should expose the dashboard through a LoadBalancer with the certificate of the Secret", func() {
+		koorCluster.Spec.Dashboard = storagev1alpha1.DashboardSpec{
+			Expose:        storagev1alpha1.DashboardExposureLoadBalancer,
+			TLSSecretName: "dashboard-tls",
+		}
+		configKeys := map[string]string{}
+		setConfigKey := func(_ context.Context, _ string, stdin io.Reader, command ...string) (string, error) {
+			data, err := io.ReadAll(stdin)
+			configKeys[command[3]] = string(data)
+			return "", err
+		}
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, gomock.Any(),
+				"ceph", "config-key", "set", "mgr/dashboard/crt", "-i", "-").DoAndReturn(setConfigKey),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, gomock.Any(),
+				"ceph", "config-key", "set", "mgr/dashboard/key", "-i", "-").DoAndReturn(setConfigKey),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "mgr", "module", "disable", "dashboard"),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "mgr", "module", "enable", "dashboard"),
+		)
+		Expect(reconciler.reconcileDashboard(ctx, koorCluster)).To(Succeed())
+		// The private key is not an argument of the ceph command
+		Expect(configKeys).To(Equal(map[string]string{"mgr/dashboard/crt": "certificate", "mgr/dashboard/key": "key"}))
+		Expect(koorCluster.Status.Dashboard.URL).To(BeEmpty())
+		Expect(koorCluster.Status.Dashboard.InstalledCertificate).To(HavePrefix("dashboard-tls/"))
+
+		service := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: dashboardExternalServiceName, Namespace: namespace}, service)).To(Succeed())
+		Expect(service.Spec.Type).To(Equal(corev1.ServiceTypeLoadBalancer))
+		Expect(service.Spec.Selector).To(HaveKeyWithValue("mgr_role", "active"))
+		service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "192.0.2.10"}}
+		Expect(k8sClient.Status().Update(ctx, service)).To(Succeed())
+
+		// The certificate is only installed again when the Secret changes
+		Expect(reconciler.reconcileDashboard(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.Dashboard.URL).To(Equal("https://192.0.2.10:8443/"))
+	})
+
+	It("Should rotate the password", func() {
+		koorCluster.Annotations = map[string]string{storagev1alpha1.RotateDashboardPasswordAnnotation: "true"}
+		Expect(k8sClient.Update(ctx, koorCluster)).To(Succeed())
+
+		var password string
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, gomock.Any(),
+			"ceph", "dashboard", "ac-user-set-password", "admin", "-i", "-", "--force-password").
+			DoAndReturn(func(_ context.Context, _ string, stdin io.Reader, _ ...string) (string, error) {
+				data, err := io.ReadAll(stdin)
+				password = string(data)
+				return "", err
+			})
+		Expect(reconciler.reconcileRotateDashboardPassword(ctx, koorCluster)).To(Succeed())
+		Expect(password).To(HaveLen(24))
+		Expect(koorCluster.Annotations).NotTo(HaveKey(storagev1alpha1.RotateDashboardPasswordAnnotation))
+		Expect(recorder.Events).To(Receive(ContainSubstring("DashboardPasswordRotated")))
+
+		secret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: dashboardPasswordSecretName, Namespace: namespace}, secret)).To(Succeed())
+		Expect(string(secret.Data[dashboardPasswordKey])).To(Equal(password))
+
+		Expect(reconciler.reconcileDashboard(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.Dashboard.PasswordRotatedAt).NotTo(BeNil())
+	})
+})
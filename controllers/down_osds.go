@@ -0,0 +1,141 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// The ceph health check that reports down OSDs
+const osdDownHealthCheck = "OSD_DOWN"
+
+// reconcileDownOsds finds the node and disk of the OSDs that ceph reports down
+// and raises an event for each OSD that went down
+func (r *KoorClusterReconciler) reconcileDownOsds(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+	cephStatus := koorCluster.Status.CephCluster
+	if cephStatus == nil {
+		koorCluster.Status.DownOsds = nil
+		meta.RemoveStatusCondition(&koorCluster.Status.Conditions, storagev1alpha1.ConditionOsdsDown)
+		return nil
+	}
+
+	if !slices.ContainsFunc(cephStatus.HealthChecks, func(check storagev1alpha1.CephHealthCheck) bool {
+		return check.Name == osdDownHealthCheck
+	}) {
+		koorCluster.Status.DownOsds = nil
+		meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionOsdsDown,
+			Status:  metav1.ConditionFalse,
+			Reason:  "AllOsdsUp",
+			Message: "Ceph reports no OSDs down",
+		})
+		return nil
+	}
+
+	namespace := koorCluster.Namespace
+	tree, err := getOsdTree(ctx, r.toolbox, namespace)
+	if err != nil {
+		// The toolbox may not be running, the rest of the status is still valid
+		log.Error(err, "unable to find the down OSDs")
+		return nil
+	}
+
+	previous := koorCluster.Status.DownOsds
+	var downOsds []storagev1alpha1.DownOsd
+	var descriptions []string
+	for _, id := range tree.osdsWithStatus(osdStatusDown) {
+		index := slices.IndexFunc(previous, func(osd storagev1alpha1.DownOsd) bool { return osd.ID == id })
+		var osd storagev1alpha1.DownOsd
+		if index >= 0 && previous[index].Device != "" {
+			osd = previous[index]
+		} else if osd, err = getOsdLocation(ctx, r.toolbox, namespace, id); err != nil {
+			log.Error(err, "unable to find the disk of the OSD", "osd", id)
+		}
+		if index < 0 {
+			r.Recorder.Eventf(koorCluster, corev1.EventTypeWarning, "OsdDown", "Ceph reports %s down", osd)
+		}
+		downOsds = append(downOsds, osd)
+		descriptions = append(descriptions, osd.String())
+	}
+
+	koorCluster.Status.DownOsds = downOsds
+	meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionOsdsDown,
+		Status:  metav1.ConditionTrue,
+		Reason:  "OsdDown",
+		Message: fmt.Sprintf("Ceph reports %s down", strings.Join(descriptions, ", ")),
+	})
+	return nil
+}
+
+// reconcileReplaceOsdsAnnotation creates a KoorOsdReplacement for each OSD in the annotation
+// and removes the annotation
+func (r *KoorClusterReconciler) reconcileReplaceOsdsAnnotation(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+	value, ok := koorCluster.Annotations[storagev1alpha1.ReplaceOsdsAnnotation]
+	if !ok {
+		return nil
+	}
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		id, err := strconv.Atoi(field)
+		if err != nil || id < 0 {
+			r.Recorder.Eventf(koorCluster, corev1.EventTypeWarning, "InvalidOsdReplacement",
+				"Cannot replace OSD %q, the annotation %s expects OSD ids", field, storagev1alpha1.ReplaceOsdsAnnotation)
+			continue
+		}
+
+		replacement := &storagev1alpha1.KoorOsdReplacement{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("osd-%d", id),
+				Namespace: koorCluster.Namespace,
+			},
+			Spec: storagev1alpha1.KoorOsdReplacementSpec{OsdID: id},
+		}
+		if err := controllerutil.SetControllerReference(koorCluster, replacement, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, replacement); err != nil {
+			if k8serrors.IsAlreadyExists(err) {
+				log.Info("KoorOsdReplacement already exists", "name", replacement.Name)
+				continue
+			}
+			log.Error(err, "Cannot create KoorOsdReplacement", "osd", id)
+			return err
+		}
+		r.Recorder.Eventf(koorCluster, corev1.EventTypeNormal, "OsdReplacementCreated",
+			"Created KoorOsdReplacement %s", replacement.Name)
+	}
+
+	delete(koorCluster.Annotations, storagev1alpha1.ReplaceOsdsAnnotation)
+	return r.Update(ctx, koorCluster)
+}
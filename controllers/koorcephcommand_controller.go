@@ -0,0 +1,157 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+// The largest output stored in the status, well below the size limit of objects in etcd
+const maxCephCommandOutput = 512 * 1024
+
+// KoorCephCommandReconciler reconciles a KoorCephCommand object
+type KoorCephCommandReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	toolbox  utils.CephToolbox
+}
+
+func NewKoorCephCommandReconciler(mgr ctrl.Manager) *KoorCephCommandReconciler {
+	return &KoorCephCommandReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("koorcephcommand-controller"),
+		toolbox:  utils.NewCephToolbox(mgr.GetConfig()),
+	}
+}
+
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koorcephcommands,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koorcephcommands/status,verbs=get;update;patch
+
+// Reconcile runs the command in the toolbox and stores its output in the status.
+// Failed commands are retried after the poll interval, successful ones run again after the refresh interval.
+func (r *KoorCephCommandReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	command := &storagev1alpha1.KoorCephCommand{}
+	if err := r.Get(ctx, req.NamespacedName, command); err != nil {
+		log.Error(err, "unable to fetch KoorCephCommand")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	status := &command.Status
+	if wait, again := nextCephCommandRun(command); !again {
+		return ctrl.Result{}, nil
+	} else if wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	output, err := r.runCephCommand(ctx, command)
+	now := metav1.Now()
+	status.LastRunTime = &now
+	if err != nil {
+		log.Error(err, "Ceph command failed", "command", command.Spec.Command)
+		status.Phase = storagev1alpha1.CephCommandPhaseFailed
+		status.Message = err.Error()
+		r.Recorder.Event(command, corev1.EventTypeWarning, "CommandFailed", status.Message)
+	} else {
+		status.Phase = storagev1alpha1.CephCommandPhaseSucceeded
+		status.Output = output
+		status.Message = ""
+	}
+
+	if err := r.Status().Update(ctx, command); err != nil {
+		log.Error(err, "Unable to update KoorCephCommand status")
+		return ctrl.Result{}, err
+	}
+	if wait, again := nextCephCommandRun(command); again {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// nextCephCommandRun returns how long to wait until the command runs again after its last run,
+// and false if it does not run again.
+func nextCephCommandRun(command *storagev1alpha1.KoorCephCommand) (time.Duration, bool) {
+	status := &command.Status
+	var interval time.Duration
+	switch {
+	case status.LastRunTime == nil:
+		return 0, true
+	case status.Phase == storagev1alpha1.CephCommandPhaseFailed:
+		interval = maintenancePollInterval
+	case command.Spec.RefreshInterval != nil:
+		interval = command.Spec.RefreshInterval.Duration
+	default:
+		return 0, false
+	}
+	return time.Until(status.LastRunTime.Add(interval)), true
+}
+
+// runCephCommand runs an allowed command in the toolbox and returns its JSON output
+func (r *KoorCephCommandReconciler) runCephCommand(
+	ctx context.Context,
+	command *storagev1alpha1.KoorCephCommand,
+) (*apiextensionsv1.JSON, error) {
+	// The CRD validates the command too, this guards against objects created before a command was removed
+	if !slices.Contains(storagev1alpha1.ReadOnlyCephCommands, command.Spec.Command) {
+		return nil, fmt.Errorf("ceph command %q is not allowed", command.Spec.Command)
+	}
+
+	args := append([]string{"ceph"}, strings.Fields(string(command.Spec.Command))...)
+	output, err := r.toolbox.Exec(ctx, command.Namespace, nil, append(args, "--format", "json")...)
+	if err != nil {
+		return nil, err
+	}
+	output = strings.TrimSpace(output)
+	if len(output) > maxCephCommandOutput {
+		return nil, fmt.Errorf("the output of ceph %s has %d bytes, more than the %d bytes that fit in the status",
+			command.Spec.Command, len(output), maxCephCommandOutput)
+	}
+	if !json.Valid([]byte(output)) {
+		return nil, fmt.Errorf("ceph %s did not return JSON", command.Spec.Command)
+	}
+	return &apiextensionsv1.JSON{Raw: []byte(output)}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KoorCephCommandReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// The status updates of a run do not run the command again
+		For(&storagev1alpha1.KoorCephCommand{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
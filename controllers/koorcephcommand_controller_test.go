@@ -0,0 +1,155 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+)
+
+var _ = Describe("KoorCephCommand controller", func() {
+	const namespace = "rook-ceph"
+
+	var (
+		ctx         context.Context
+		k8sClient   client.Client
+		recorder    *record.FakeRecorder
+		mockToolbox *mocks.MockCephToolbox
+		reconciler  *KoorCephCommandReconciler
+		request     ctrl.Request
+	)
+
+	createCommand := func(spec storagev1alpha1.KoorCephCommandSpec) {
+		command := &storagev1alpha1.KoorCephCommand{
+			ObjectMeta: metav1.ObjectMeta{Name: "command", Namespace: namespace},
+			Spec:       spec,
+		}
+		Expect(k8sClient.Create(ctx, command)).To(Succeed())
+	}
+
+	getCommand := func() *storagev1alpha1.KoorCephCommand {
+		command := &storagev1alpha1.KoorCephCommand{}
+		Expect(k8sClient.Get(ctx, request.NamespacedName, command)).To(Succeed())
+		return command
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockToolbox = mocks.NewMockCephToolbox(gomock.NewController(GinkgoT()))
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		k8sClient = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithStatusSubresource(&storagev1alpha1.KoorCephCommand{}).
+			Build()
+		recorder = record.NewFakeRecorder(10)
+		reconciler = &KoorCephCommandReconciler{
+			Client:   k8sClient,
+			Scheme:   scheme,
+			Recorder: recorder,
+			toolbox:  mockToolbox,
+		}
+		request = ctrl.Request{NamespacedName: types.NamespacedName{Name: "command", Namespace: namespace}}
+	})
+
+	It("Should store the output of the command once", func() {
+		createCommand(storagev1alpha1.KoorCephCommandSpec{Command: "osd tree"})
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "tree", "--format", "json").
+			Return(`{"nodes":[{"id":-1,"name":"default"}]}`+"\n", nil)
+
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+		command := getCommand()
+		Expect(command.Status.Phase).To(Equal(storagev1alpha1.CephCommandPhaseSucceeded))
+		Expect(command.Status.Output.Raw).To(MatchJSON(`{"nodes":[{"id":-1,"name":"default"}]}`))
+		Expect(command.Status.LastRunTime).NotTo(BeNil())
+
+		// The command does not run again without a refresh interval
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should run the command again after the refresh interval", func() {
+		createCommand(storagev1alpha1.KoorCephCommandSpec{
+			Command:         "status",
+			RefreshInterval: &metav1.Duration{Duration: time.Minute},
+		})
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "status", "--format", "json").Return(`{}`, nil)
+
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically("~", time.Minute, time.Second))
+
+		result, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically("~", time.Minute, time.Second))
+	})
+
+	It("Should report failures and retry", func() {
+		createCommand(storagev1alpha1.KoorCephCommandSpec{Command: "df"})
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "df", "--format", "json").
+			Return("", errors.New("no ceph toolbox is running in namespace rook-ceph"))
+
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically("~", maintenancePollInterval, time.Second))
+		command := getCommand()
+		Expect(command.Status.Phase).To(Equal(storagev1alpha1.CephCommandPhaseFailed))
+		Expect(command.Status.Message).To(ContainSubstring("no ceph toolbox is running"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("CommandFailed")))
+
+		By("Waiting for the poll interval before the retry")
+		result, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically("~", maintenancePollInterval, time.Second))
+
+		command.Status.LastRunTime = &metav1.Time{Time: time.Now().Add(-maintenancePollInterval)}
+		Expect(k8sClient.Status().Update(ctx, command)).To(Succeed())
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "df", "--format", "json").Return(`{}`, nil)
+		result, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+		Expect(getCommand().Status.Phase).To(Equal(storagev1alpha1.CephCommandPhaseSucceeded))
+	})
+
+	It("Should not run commands that are not allowed", func() {
+		createCommand(storagev1alpha1.KoorCephCommandSpec{Command: "osd purge 1"})
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getCommand().Status.Message).To(Equal(`ceph command "osd purge 1" is not allowed`))
+	})
+})
@@ -19,19 +19,24 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
-	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -40,10 +45,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/Masterminds/sprig/v3"
-	"github.com/itchyny/gojq"
 	hc "github.com/mittwald/go-helm-client"
-	"github.com/pkg/errors"
-	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/repo"
 
 	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
@@ -54,17 +56,33 @@ import (
 // KoorClusterReconciler reconciles a KoorCluster object
 type KoorClusterReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	crons  utils.CronRegistry
-	vs     utils.VersionService
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	crons    utils.CronRegistry
+	vs       utils.VersionService
+	metrics  utils.CephMetrics
+	toolbox  utils.CephToolbox
+	s3       utils.S3Client
+	notifier utils.Notifier
+
+	// Used to add watches for kinds installed by the helm charts
+	controller           controller.Controller
+	cache                cache.Cache
+	watchMutex           sync.Mutex
+	watchingCephClusters bool
 }
 
 func NewKoorClusterReconciler(mgr ctrl.Manager) *KoorClusterReconciler {
 	return &KoorClusterReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		crons:  utils.NewCronRegistry(),
-		vs:     utils.NewVersionServiceClient(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("koorcluster-controller"),
+		crons:    utils.NewCronRegistry(),
+		vs:       utils.NewVersionServiceClient(),
+		metrics:  utils.NewCephMetricsClient(),
+		toolbox:  utils.NewCephToolbox(mgr.GetConfig()),
+		s3:       utils.NewS3Client(),
+		notifier: utils.NewNotifier(),
 	}
 }
 
@@ -73,6 +91,8 @@ func NewKoorClusterReconciler(mgr ctrl.Manager) *KoorClusterReconciler {
 //+kubebuilder:rbac:groups=storage.koor.tech,resources=koorclusters/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=nodes/status,verbs=get
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koornodedecommissions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koorosdreplacements,verbs=get;create
 // Needed for helm to work in olm
 //+kubebuilder:rbac:groups=*,resources=*,verbs=*
 
@@ -130,14 +150,38 @@ func (r *KoorClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	}
 
-	err = r.reconcileNormal(ctx, koorCluster, helmClient)
-	return ctrl.Result{}, err
+	if err := r.reconcileNormal(ctx, koorCluster, helmClient); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if koorCluster.IsPaused() {
+		// Watch events resume the reconciliation once the pause is lifted
+		return ctrl.Result{}, nil
+	}
+	if koorCluster.Status.Upgrade.InProgress() {
+		return ctrl.Result{RequeueAfter: upgradePollInterval}, nil
+	}
+	// Reconcile again when the next maintenance window opens to apply deferred upgrades
+	var next *time.Time
+	if window := koorCluster.Status.NextMaintenanceWindow; window != nil {
+		next = &window.Time
+	}
+	// or to retry failed notifications
+	if retry := nextNotificationRetry(&koorCluster.Status); retry != nil && (next == nil || retry.Before(*next)) {
+		next = retry
+	}
+	if next != nil {
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Until(*next)}, nil
+	}
+	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *KoorClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&storagev1alpha1.KoorCluster{}).
+		// The dashboard URL waits for the address of the LoadBalancer
+		Owns(&corev1.Service{}).
 		Watches(
 			&corev1.Node{},
 			handler.EnqueueRequestsFromMapFunc(r.findKoorClusters),
@@ -165,7 +209,18 @@ func (r *KoorClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				},
 			}),
 		).
-		Complete(r)
+		Watches(
+			&storagev1alpha1.KoorNodeDecommission{},
+			handler.EnqueueRequestsFromMapFunc(r.findKoorClustersInNamespace),
+		).
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	r.controller = c
+	r.cache = mgr.GetCache()
+	return nil
 }
 
 func (r *KoorClusterReconciler) findKoorClusters(ctx context.Context, _ client.Object) []reconcile.Request {
@@ -193,11 +248,55 @@ func (r *KoorClusterReconciler) reconcileNormal(
 	helmClient hc.Client,
 ) error {
 	log := log.FromContext(ctx)
+	// Run first because they update the KoorCluster, which resets the status.
+	// The annotations of a paused KoorCluster are kept until it is resumed.
+	if !koorCluster.IsPaused() {
+		if err := r.reconcileReplaceOsdsAnnotation(ctx, koorCluster); err != nil {
+			return err
+		}
+
+		if err := r.reconcileRotateDashboardPassword(ctx, koorCluster); err != nil {
+			return err
+		}
+	}
+
 	if err := r.reconcileResources(ctx, koorCluster); err != nil {
 		return err
 	}
 
-	if err := r.reconcileHelm(ctx, koorCluster, helmClient); err != nil {
+	if err := r.reconcileVersions(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	if err := r.reconcileCephCluster(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	if err := r.reconcileCapacity(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	if err := r.reconcileDownOsds(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	if err := r.reconcileUpgradePlan(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	if err := r.reconcileSecurityUpdate(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	if err := r.reconcileStorageNodes(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	// The changes rely on the ceph status for the preflight checks
+	// and on the upgrade plan in approval mode
+	if r.checkPaused(ctx, koorCluster) {
+		log.Info("KoorCluster is paused, skipping all changes to the cluster")
+	} else if err := r.reconcileChanges(ctx, koorCluster, helmClient); err != nil {
 		return err
 	}
 
@@ -205,6 +304,10 @@ func (r *KoorClusterReconciler) reconcileNormal(
 		return err
 	}
 
+	if err := r.reconcileNotificationSinks(ctx, koorCluster); err != nil {
+		return err
+	}
+
 	if err := r.Status().Update(ctx, koorCluster); err != nil {
 		log.Error(err, "Unable to update KoorCluster status")
 		return err
@@ -213,6 +316,31 @@ func (r *KoorClusterReconciler) reconcileNormal(
 	return nil
 }
 
+// reconcileChanges applies the spec to ceph, the resources of the cluster and the helm releases
+func (r *KoorClusterReconciler) reconcileChanges(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	helmClient hc.Client,
+) error {
+	if err := r.reconcileCephConfig(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	if err := r.reconcileStorageClasses(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	if err := r.reconcileDashboard(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	if err := r.reconcileMonitoring(ctx, koorCluster); err != nil {
+		return err
+	}
+
+	return r.reconcileHelm(ctx, koorCluster, helmClient)
+}
+
 func (r *KoorClusterReconciler) reconcileResources(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
 	log := log.FromContext(ctx)
 
@@ -242,7 +370,7 @@ func (r *KoorClusterReconciler) reconcileResources(ctx context.Context, koorClus
 	koorCluster.Status.MeetsMinimumResources = resources.MeetsMinimum()
 	if !koorCluster.Status.MeetsMinimumResources {
 		log.Info("The cluster does not meet the minimum resource requirements")
-		// TODO add event for minimum resources
+		// The notification sinks are notified in reconcileNotificationSinks
 	}
 
 	koorCluster.Status.CurrentVersions.Kube = kubeVersion
@@ -251,6 +379,14 @@ func (r *KoorClusterReconciler) reconcileResources(ctx context.Context, koorClus
 	return nil
 }
 
+// parseValueTemplates parses the templates of the values directory, which are rendered with the KoorCluster
+func parseValueTemplates() (*template.Template, error) {
+	return template.New("").Funcs(sprig.TxtFuncMap()).Funcs(template.FuncMap{
+		"promDuration": promDuration,
+		"cephImage":    targetCephImage,
+	}).ParseFS(&values.Templates, "*")
+}
+
 func (r *KoorClusterReconciler) reconcileHelm(
 	ctx context.Context,
 	koorCluster *storagev1alpha1.KoorCluster,
@@ -274,7 +410,13 @@ func (r *KoorClusterReconciler) reconcileHelm(
 		return err
 	}
 
-	templates, err := template.New("").Funcs(sprig.TxtFuncMap()).ParseFS(&values.Templates, "*")
+	// Images named by the version service are only deployed with its digest
+	if err := verifyCephImage(koorCluster); err != nil {
+		r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "ImageDigestMismatch", err.Error())
+		return err
+	}
+
+	templates, err := parseValueTemplates()
 	if err != nil {
 		log.Error(err, "Cannot parse templates")
 		return err
@@ -292,26 +434,13 @@ func (r *KoorClusterReconciler) reconcileHelm(
 	operatorChartSpec := hc.ChartSpec{
 		ReleaseName:     koorCluster.Spec.KsdReleaseName,
 		ChartName:       "koor-release/rook-ceph",
+		Version:         koorCluster.Spec.Versions.ChartVersion(),
 		Namespace:       koorCluster.Namespace,
 		CreateNamespace: true,
 		UpgradeCRDs:     true,
 		ValuesYaml:      operatorBuffer.String(),
 	}
 
-	operatorRelease, err := helmClient.InstallOrUpgradeChart(ctx, &operatorChartSpec, nil)
-	if err != nil {
-		log.Error(err, "Cannot install or upgrade operator chart")
-		return err
-	}
-
-	ksdVersion, err := getKSDVersion(operatorRelease)
-	if err != nil {
-		log.Error(err, "Could not find KSD version")
-	} else {
-		log.Info("Found KSD version", "ksdVersion", ksdVersion)
-		koorCluster.Status.CurrentVersions.Ksd = ksdVersion
-	}
-
 	// Install rook cluster
 	// helm install --create-namespace --namespace <namespace> <namespace>-rook-ceph-cluster \
 	//     --set operatorNamespace=<namespace> koor-release/rook-ceph-cluster -f utils/clusterValues.yaml
@@ -325,72 +454,14 @@ func (r *KoorClusterReconciler) reconcileHelm(
 	clusterChartSpec := hc.ChartSpec{
 		ReleaseName:     koorCluster.Spec.KsdClusterReleaseName,
 		ChartName:       "koor-release/rook-ceph-cluster",
+		Version:         koorCluster.Spec.Versions.ChartVersion(),
 		Namespace:       koorCluster.Namespace,
 		CreateNamespace: true,
 		UpgradeCRDs:     true,
 		ValuesYaml:      clusterBuffer.String(),
 	}
 
-	clusterRelease, err := helmClient.InstallOrUpgradeChart(ctx, &clusterChartSpec, nil)
-	if err != nil {
-		log.Error(err, "Cannot install or upgrade cluster chart")
-		return err
-	}
-	cephVersion, err := getCephVersion(clusterRelease)
-	if err != nil {
-		log.Error(err, "Could not find ceph version")
-	} else {
-		log.Info("Found ceph version", "cephVersion", cephVersion)
-		koorCluster.Status.CurrentVersions.Ceph = cephVersion
-	}
-
-	return nil
-}
-
-func getKSDVersion(rel *release.Release) (string, error) {
-	result := ""
-	query, err := gojq.Parse(".image.tag")
-	if err != nil {
-		// This should not happen
-		return result, errors.Wrap(err, "Failed to compile rook query")
-	}
-	iter := query.Run(rel.Chart.Values)
-	ksdVersion, ok := iter.Next()
-	if !ok {
-		return result, fmt.Errorf("Could not find rook version via query")
-	}
-
-	result, ok = ksdVersion.(string)
-	if !ok {
-		return result, fmt.Errorf("Found field is not a string")
-	}
-	return result, nil
-}
-
-func getCephVersion(rel *release.Release) (string, error) {
-	result := ""
-	query, err := gojq.Parse(".cephClusterSpec.cephVersion.image")
-	if err != nil {
-		// This should not happen
-		return result, errors.Wrap(err, "Failed to compile ceph query")
-	}
-
-	iter := query.Run(rel.Chart.Values)
-	cephImage, ok := iter.Next()
-	if !ok {
-		return result, fmt.Errorf("Could not find ceph image via query")
-	}
-
-	cephImageStr, ok := cephImage.(string)
-	if !ok {
-		return result, fmt.Errorf("Ceph image is not a string")
-	}
-
-	cephImageParts := strings.Split(cephImageStr, ":")
-	if len(cephImageParts) != 2 {
-		return result, fmt.Errorf("Ceph image is malformatted")
-	}
-	return cephImageParts[1], nil
+	return r.reconcileUpgrade(ctx, koorCluster, helmClient, &operatorChartSpec, &clusterChartSpec)
 }
 
 func notificationJobName(koorCluster *storagev1alpha1.KoorCluster) string {
@@ -440,18 +511,17 @@ func (r *KoorClusterReconciler) reconcileNotification(ctx context.Context, koorC
 			return
 		}
 
-		latestVersions, err := r.vs.LatestVersions(
-			context.Background(),
-			currentKoorCluster.Spec.UpgradeOptions.Endpoint,
-			&currentKoorCluster.Status.CurrentVersions,
-		)
-		if err != nil {
+		latestVersions, err := r.fetchLatestVersions(context.Background(), currentKoorCluster)
+		if errors.Is(err, utils.ErrUnverified) {
+			log.Error(err, "Rejected the latest versions")
+			r.Recorder.Event(currentKoorCluster, corev1.EventTypeWarning, "UnverifiedVersions", err.Error())
+		} else if err != nil {
 			log.Error(err, "unable to find latest versions")
 		} else {
 			currentKoorCluster.Status.LatestVersions = latestVersions
 		}
 
-		// TODO add event if current versions are not latest
+		// The status update triggers a reconcile, which notifies the sinks about new versions
 
 		if err := r.Status().Update(ctx, currentKoorCluster); err != nil {
 			log.Error(err, "Unable to update KoorCluster status in cronjob")
@@ -483,6 +553,10 @@ func (r *KoorClusterReconciler) handleFinalizer(
 		log.Error(err, "Failed to uninstall release", "releaseName", releaseName)
 	}
 
+	if err := r.deleteStorageClasses(ctx, koorCluster); err != nil {
+		log.Error(err, "Failed to delete the StorageClasses")
+	}
+
 	// remove our finalizer from the list and update it.
 	controllerutil.RemoveFinalizer(koorCluster, storagev1alpha1.KoorClusterFinalizerName)
 	return r.Update(ctx, koorCluster)
@@ -23,12 +23,16 @@ import (
 
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	appsv1 "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
-	hc "github.com/mittwald/go-helm-client"
 	hcmock "github.com/mittwald/go-helm-client/mock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -57,6 +61,7 @@ var _ = Describe("KoorCluster controller", func() {
 		kubeVersion        = "1.27.3"
 		defaultSchedule    = "0 0 * * *"
 		newSchedule        = "1 0 * * *"
+		chartVersion       = "v1.11.0"
 	)
 
 	var (
@@ -65,28 +70,40 @@ var _ = Describe("KoorCluster controller", func() {
 		reconciler        *KoorClusterReconciler
 		mockVS            *mocks.MockVersionService
 		mockCronsRegistry *mocks.MockCronRegistry
+		mockMetrics       *mocks.MockCephMetrics
+		mockToolbox       *mocks.MockCephToolbox
+		recorder          *record.FakeRecorder
 	)
 
-	rookRelease := &release.Release{
-		Chart: &chart.Chart{
-			Values: map[string]any{
-				"image": map[string]any{
-					"tag": ksdCurrentVersion,
-				},
-			},
-		},
-	}
+	// expectHelmCalls expects the charts to be installed or upgraded without a version change.
+	// An empty deployedVersion means the releases are not installed yet.
+	expectHelmCalls := func(deployedVersion string) {
+		calls := []any{
+			mockHelmClient.EXPECT().AddOrUpdateChartRepo(gomock.Any()).Return(nil),
+			mockHelmClient.EXPECT().UpdateChartRepos().Return(nil),
+		}
+		deployedRelease := func(releaseName string) *gomock.Call {
+			if deployedVersion == "" {
+				return mockHelmClient.EXPECT().GetRelease(releaseName).Return(nil, driver.ErrReleaseNotFound)
+			}
+			return mockHelmClient.EXPECT().GetRelease(releaseName).Return(&release.Release{
+				Name:  releaseName,
+				Chart: &chart.Chart{Metadata: &chart.Metadata{Version: deployedVersion}},
+			}, nil)
+		}
 
-	clusterRelease := &release.Release{
-		Chart: &chart.Chart{
-			Values: map[string]any{
-				"cephClusterSpec": map[string]any{
-					"cephVersion": map[string]any{
-						"image": "quai.io/ceph/ceph:" + cephCurrentVersion,
-					},
-				},
-			},
-		},
+		calls = append(calls, deployedRelease(KsdReleaseName))
+		if deployedVersion != "" {
+			calls = append(calls, mockHelmClient.EXPECT().GetChart(gomock.Any(), gomock.Any()).Return(
+				&chart.Chart{Metadata: &chart.Metadata{Version: deployedVersion}}, "", nil),
+				deployedRelease(KsdClusterReleaseName))
+		}
+		calls = append(calls, expectInstall(mockHelmClient, KsdReleaseName, deployedVersion))
+		if deployedVersion != "" {
+			calls = append(calls, deployedRelease(KsdClusterReleaseName))
+		}
+		calls = append(calls, expectInstall(mockHelmClient, KsdClusterReleaseName, deployedVersion))
+		gomock.InOrder(calls...)
 	}
 
 	BeforeEach(func() {
@@ -94,30 +111,23 @@ var _ = Describe("KoorCluster controller", func() {
 		mockHelmClient = hcmock.NewMockClient(mockCtrl)
 		mockVS = mocks.NewMockVersionService(mockCtrl)
 		mockCronsRegistry = mocks.NewMockCronRegistry(mockCtrl)
+		mockMetrics = mocks.NewMockCephMetrics(mockCtrl)
+		mockToolbox = mocks.NewMockCephToolbox(mockCtrl)
+		recorder = record.NewFakeRecorder(100)
 		reconciler = &KoorClusterReconciler{
-			Client: k8sClient,
-			Scheme: k8sClient.Scheme(),
-			crons:  mockCronsRegistry,
-			vs:     mockVS,
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: recorder,
+			crons:    mockCronsRegistry,
+			vs:       mockVS,
+			metrics:  mockMetrics,
+			toolbox:  mockToolbox,
 		}
 	})
 
 	Context("When creating a KoorCluster", func() {
 		It("Should update status and install the operator and the cluster helm charts", func() {
-			gomock.InOrder(
-				mockHelmClient.EXPECT().AddOrUpdateChartRepo(gomock.Any()).Return(nil),
-				mockHelmClient.EXPECT().UpdateChartRepos().Return(nil),
-				mockHelmClient.EXPECT().InstallOrUpgradeChart(gomock.Any(), gomock.Any(), gomock.Any()).
-					DoAndReturn(func(ctx interface{}, chartSpec *hc.ChartSpec, opts interface{}) (interface{}, error) {
-						Expect(chartSpec.ReleaseName).To(Equal(KsdReleaseName))
-						return rookRelease, nil
-					}),
-				mockHelmClient.EXPECT().InstallOrUpgradeChart(gomock.Any(), gomock.Any(), gomock.Any()).
-					DoAndReturn(func(ctx interface{}, chartSpec *hc.ChartSpec, opts interface{}) (interface{}, error) {
-						Expect(chartSpec.ReleaseName).To(Equal(KsdClusterReleaseName))
-						return clusterRelease, nil
-					}),
-			)
+			expectHelmCalls("")
 
 			internalFunc := func() {
 				panic("This should not be called!")
@@ -147,6 +157,22 @@ var _ = Describe("KoorCluster controller", func() {
 					},
 				}, nil)
 
+			mockMetrics.EXPECT().Usage(gomock.Any(), cephMgrMetricsEndpoint(KoorClusterNamespace)).Return(
+				&utils.CephUsage{
+					Pools: []storagev1alpha1.CephPoolUsage{{
+						Name:   "replicapool",
+						Stored: resource.NewQuantity(10<<30, resource.BinarySI),
+					}},
+					Osds: &storagev1alpha1.CephOsdCounts{Total: 3, Up: 3, In: 3},
+				}, nil).AnyTimes()
+
+			mockToolbox.EXPECT().Exec(gomock.Any(), KoorClusterNamespace, nil, "ceph", "osd", "tree", "--format", "json").
+				Return(`{"nodes":[{"id":-3,"name":"node-a","type":"host","children":[0,2]},`+
+					`{"id":0,"name":"osd.0","type":"osd","status":"up"},`+
+					`{"id":2,"name":"osd.2","type":"osd","status":"down"}]}`, nil).AnyTimes()
+			mockToolbox.EXPECT().Exec(gomock.Any(), KoorClusterNamespace, nil, "ceph", "osd", "metadata", "2", "--format", "json").
+				Return(`{"id":2,"hostname":"node-a","devices":"sdb"}`, nil)
+
 			ctx := context.Background()
 
 			By("Creating Nodes on the cluster")
@@ -197,6 +223,64 @@ var _ = Describe("KoorCluster controller", func() {
 				Expect(k8sClient.Create(ctx, node)).To(Succeed())
 			}
 
+			By("Creating the rook workloads")
+			replicas := int32(1)
+			labels := map[string]string{"app": rookOperatorName}
+			rookDeployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      rookOperatorName,
+					Namespace: KoorClusterNamespace,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: core.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: core.PodSpec{
+							Containers: []core.Container{{
+								Name:  rookOperatorName,
+								Image: "registry.local:5000/koorinc/ceph:" + ksdCurrentVersion,
+							}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, rookDeployment)).To(Succeed())
+
+			cephCluster := &unstructured.Unstructured{}
+			cephCluster.SetGroupVersionKind(cephClusterGVK)
+			cephCluster.SetName("ceph-cluster")
+			cephCluster.SetNamespace(KoorClusterNamespace)
+			Expect(k8sClient.Create(ctx, cephCluster)).To(Succeed())
+			Expect(unstructured.SetNestedField(cephCluster.Object, map[string]any{
+				"phase": "Ready",
+				"version": map[string]any{
+					"image":   "quay.io/ceph/ceph:" + cephCurrentVersion,
+					"version": "17.2.5-0",
+				},
+				"ceph": map[string]any{
+					"health": "HEALTH_WARN",
+					"details": map[string]any{
+						"OSD_DOWN": map[string]any{
+							"message":  "1 osds down",
+							"severity": "HEALTH_WARN",
+						},
+					},
+					"capacity": map[string]any{
+						"bytesTotal":     int64(100 << 30),
+						"bytesUsed":      int64(80 << 30),
+						"bytesAvailable": int64(20 << 30),
+					},
+					"versions": map[string]any{
+						"overall": map[string]any{
+							"ceph version 17.2.5 quincy (stable)": int64(3),
+							"ceph version 17.2.6 quincy (stable)": int64(1),
+						},
+					},
+				},
+			}, "status")).To(Succeed())
+			Expect(k8sClient.Status().Update(ctx, cephCluster)).To(Succeed())
+
 			By("By creating a new KoorCluster")
 			koorCluster := &storagev1alpha1.KoorCluster{
 				TypeMeta: metav1.TypeMeta{
@@ -210,6 +294,10 @@ var _ = Describe("KoorCluster controller", func() {
 				Spec: storagev1alpha1.KoorClusterSpec{
 					KsdReleaseName:        KsdReleaseName,
 					KsdClusterReleaseName: KsdClusterReleaseName,
+					// The mocked version service does not sign its responses
+					UpgradeOptions: storagev1alpha1.UpgradeOptions{
+						VersionService: storagev1alpha1.VersionServiceOptions{AllowUnsigned: true},
+					},
 				},
 			}
 			Expect(k8sClient.Create(ctx, koorCluster)).To(Succeed())
@@ -232,6 +320,30 @@ var _ = Describe("KoorCluster controller", func() {
 			Expect(createdKoorCluster.Status.CurrentVersions.KoorOperator).To(Equal(utils.OperatorVersion))
 			Expect(createdKoorCluster.Status.CurrentVersions.Ksd).To(Equal(ksdCurrentVersion))
 			Expect(createdKoorCluster.Status.CurrentVersions.Ceph).To(Equal(cephCurrentVersion))
+			Expect(createdKoorCluster.Status.MixedVersions).To(BeTrue())
+			Expect(createdKoorCluster.Status.CephCluster).NotTo(BeNil())
+			Expect(createdKoorCluster.Status.CephCluster.Phase).To(Equal("Ready"))
+			Expect(createdKoorCluster.Status.CephCluster.Health).To(Equal(storagev1alpha1.CephHealthWarn))
+			Expect(createdKoorCluster.Status.CephCluster.HealthChecks).To(ConsistOf(storagev1alpha1.CephHealthCheck{
+				Name:     "OSD_DOWN",
+				Severity: storagev1alpha1.CephHealthWarn,
+				Message:  "1 osds down",
+			}))
+			Expect(createdKoorCluster.Status.CephCluster.Capacity.Total.Equal(resource.MustParse("100Gi"))).To(BeTrue())
+			Expect(createdKoorCluster.Status.CephCluster.Capacity.Used.Equal(resource.MustParse("80Gi"))).To(BeTrue())
+			Expect(createdKoorCluster.Status.CephCluster.Capacity.Available.Equal(resource.MustParse("20Gi"))).To(BeTrue())
+			Expect(createdKoorCluster.Status.CephCluster.Pools).To(HaveLen(1))
+			Expect(createdKoorCluster.Status.CephCluster.Pools[0].Name).To(Equal("replicapool"))
+			Expect(*createdKoorCluster.Status.CephCluster.Osds).To(Equal(storagev1alpha1.CephOsdCounts{Total: 3, Up: 3, In: 3}))
+			Expect(recorder.Events).To(Receive(ContainSubstring("NearFull")))
+			Expect(createdKoorCluster.Status.DownOsds).To(Equal([]storagev1alpha1.DownOsd{
+				{ID: 2, Node: "node-a", Device: "/dev/sdb"},
+			}))
+			osdsDown := meta.FindStatusCondition(createdKoorCluster.Status.Conditions, storagev1alpha1.ConditionOsdsDown)
+			Expect(osdsDown).NotTo(BeNil())
+			Expect(osdsDown.Status).To(Equal(metav1.ConditionTrue))
+			Expect(osdsDown.Message).To(Equal("Ceph reports osd.2 on node node-a device /dev/sdb down"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("OsdDown")))
 
 			By("Checking status after running internal function")
 			internalFunc()
@@ -255,20 +367,7 @@ var _ = Describe("KoorCluster controller", func() {
 				},
 			}
 			Expect(k8sClient.Create(ctx, newNode)).To(Succeed())
-			gomock.InOrder(
-				mockHelmClient.EXPECT().AddOrUpdateChartRepo(gomock.Any()).Return(nil),
-				mockHelmClient.EXPECT().UpdateChartRepos().Return(nil),
-				mockHelmClient.EXPECT().InstallOrUpgradeChart(gomock.Any(), gomock.Any(), gomock.Any()).
-					DoAndReturn(func(ctx interface{}, chartSpec *hc.ChartSpec, opts interface{}) (interface{}, error) {
-						Expect(chartSpec.ReleaseName).To(Equal(KsdReleaseName))
-						return rookRelease, nil
-					}),
-				mockHelmClient.EXPECT().InstallOrUpgradeChart(gomock.Any(), gomock.Any(), gomock.Any()).
-					DoAndReturn(func(ctx interface{}, chartSpec *hc.ChartSpec, opts interface{}) (interface{}, error) {
-						Expect(chartSpec.ReleaseName).To(Equal(KsdClusterReleaseName))
-						return clusterRelease, nil
-					}),
-			)
+			expectHelmCalls(chartVersion)
 
 			By("Checking status after adding nodes")
 			Expect(reconciler.reconcileNormal(ctx, createdKoorCluster, mockHelmClient)).To(Succeed())
@@ -290,20 +389,7 @@ var _ = Describe("KoorCluster controller", func() {
 			afterNodeKoorCluster.Spec.UpgradeOptions.Schedule = newSchedule
 			Expect(k8sClient.Update(ctx, afterNodeKoorCluster)).To(Succeed())
 
-			gomock.InOrder(
-				mockHelmClient.EXPECT().AddOrUpdateChartRepo(gomock.Any()).Return(nil),
-				mockHelmClient.EXPECT().UpdateChartRepos().Return(nil),
-				mockHelmClient.EXPECT().InstallOrUpgradeChart(gomock.Any(), gomock.Any(), gomock.Any()).
-					DoAndReturn(func(ctx interface{}, chartSpec *hc.ChartSpec, opts interface{}) (interface{}, error) {
-						Expect(chartSpec.ReleaseName).To(Equal(KsdReleaseName))
-						return rookRelease, nil
-					}),
-				mockHelmClient.EXPECT().InstallOrUpgradeChart(gomock.Any(), gomock.Any(), gomock.Any()).
-					DoAndReturn(func(ctx interface{}, chartSpec *hc.ChartSpec, opts interface{}) (interface{}, error) {
-						Expect(chartSpec.ReleaseName).To(Equal(KsdClusterReleaseName))
-						return clusterRelease, nil
-					}),
-			)
+			expectHelmCalls(chartVersion)
 
 			gomock.InOrder(
 				mockCronsRegistry.EXPECT().Remove(jobName).Return(nil),
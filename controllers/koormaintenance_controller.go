@@ -0,0 +1,447 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+const (
+	// How often draining nodes and recovering placement groups are checked
+	maintenancePollInterval = 15 * time.Second
+	// The field index of the pods by node
+	podNodeNameField = "spec.nodeName"
+	// The label rook uses as the CRUSH host name of a node
+	hostnameLabel = "kubernetes.io/hostname"
+)
+
+// KoorMaintenanceReconciler reconciles a KoorMaintenance object
+type KoorMaintenanceReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	toolbox  utils.CephToolbox
+}
+
+func NewKoorMaintenanceReconciler(mgr ctrl.Manager) *KoorMaintenanceReconciler {
+	return &KoorMaintenanceReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("koormaintenance-controller"),
+		toolbox:  utils.NewCephToolbox(mgr.GetConfig()),
+	}
+}
+
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koormaintenances,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koormaintenances/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koormaintenances/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+//+kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// Reconcile sets the ceph flags for the nodes in maintenance, drains them and restores
+// the cluster once the maintenance is done
+func (r *KoorMaintenanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	maintenance := &storagev1alpha1.KoorMaintenance{}
+	if err := r.Get(ctx, req.NamespacedName, maintenance); err != nil {
+		log.Error(err, "unable to fetch KoorMaintenance")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	const finalizerName = storagev1alpha1.KoorMaintenanceFinalizerName
+
+	if maintenance.IsBeingDeleted() {
+		if !controllerutil.ContainsFinalizer(maintenance, finalizerName) {
+			return ctrl.Result{}, nil
+		}
+		// Never leave nodes cordoned or flags set behind
+		if maintenance.Status.IsActive() {
+			if err := r.restore(ctx, maintenance); err != nil {
+				log.Error(err, "Cannot end the maintenance")
+				return ctrl.Result{}, err
+			}
+		}
+		controllerutil.RemoveFinalizer(maintenance, finalizerName)
+		return ctrl.Result{}, r.Update(ctx, maintenance)
+	}
+
+	if !controllerutil.ContainsFinalizer(maintenance, finalizerName) {
+		controllerutil.AddFinalizer(maintenance, finalizerName)
+		if err := r.Update(ctx, maintenance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	result, reconcileErr := r.reconcileMaintenance(ctx, maintenance)
+	if err := r.Status().Update(ctx, maintenance); err != nil {
+		log.Error(err, "Unable to update KoorMaintenance status")
+		return ctrl.Result{}, err
+	}
+	return result, reconcileErr
+}
+
+// reconcileMaintenance advances the maintenance through its phases
+func (r *KoorMaintenanceReconciler) reconcileMaintenance(
+	ctx context.Context,
+	maintenance *storagev1alpha1.KoorMaintenance,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	status := &maintenance.Status
+
+	for {
+		switch status.Phase {
+		case "":
+			if err := r.start(ctx, maintenance); err != nil {
+				log.Error(err, "Cannot start the maintenance")
+				status.Message = err.Error()
+				return ctrl.Result{}, err
+			}
+
+		case storagev1alpha1.MaintenancePhaseDraining:
+			if maintenance.Spec.Done {
+				status.Phase = storagev1alpha1.MaintenancePhaseReady
+				continue
+			}
+			if isEnabled(maintenance.Spec.Drain) {
+				drained, err := r.drainNodes(ctx, maintenance)
+				if err != nil {
+					log.Error(err, "Cannot drain the nodes")
+					return ctrl.Result{}, err
+				}
+				if !drained {
+					return ctrl.Result{RequeueAfter: maintenancePollInterval}, nil
+				}
+			}
+			status.Phase = storagev1alpha1.MaintenancePhaseReady
+			status.Message = "The nodes are ready for maintenance, set spec.done once the work is finished"
+			log.Info(status.Message)
+			r.Recorder.Event(maintenance, corev1.EventTypeNormal, "MaintenanceReady", status.Message)
+
+		case storagev1alpha1.MaintenancePhaseReady:
+			if !maintenance.Spec.Done {
+				return ctrl.Result{}, nil
+			}
+			if err := r.restore(ctx, maintenance); err != nil {
+				log.Error(err, "Cannot end the maintenance")
+				return ctrl.Result{}, err
+			}
+			status.Phase = storagev1alpha1.MaintenancePhaseRecovering
+
+		case storagev1alpha1.MaintenancePhaseRecovering:
+			clean, err := r.pgsClean(ctx, maintenance.Namespace)
+			if err != nil {
+				log.Error(err, "Cannot check the placement groups")
+				return ctrl.Result{}, err
+			}
+			if !clean {
+				status.Message = "Waiting for all placement groups to be active+clean"
+				return ctrl.Result{RequeueAfter: maintenancePollInterval}, nil
+			}
+			now := metav1.Now()
+			status.Phase = storagev1alpha1.MaintenancePhaseCompleted
+			status.CompletedAt = &now
+			status.Message = fmt.Sprintf("Maintenance of %s completed", strings.Join(status.Nodes, ", "))
+			log.Info(status.Message)
+			r.Recorder.Event(maintenance, corev1.EventTypeNormal, "MaintenanceCompleted", status.Message)
+
+		default:
+			return ctrl.Result{}, nil
+		}
+	}
+}
+
+// start resolves the nodes and sets the noout flag on their CRUSH buckets and the cluster wide norebalance flag
+func (r *KoorMaintenanceReconciler) start(ctx context.Context, maintenance *storagev1alpha1.KoorMaintenance) error {
+	status := &maintenance.Status
+	nodes, buckets, err := r.resolveTargets(ctx, maintenance)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes match the maintenance targets")
+	}
+
+	// norebalance is a cluster wide flag, noout can be limited to CRUSH buckets
+	if _, err := r.toolbox.Exec(ctx, maintenance.Namespace, nil, "ceph", "osd", "set", "norebalance"); err != nil {
+		return err
+	}
+	if _, err := r.toolbox.Exec(ctx, maintenance.Namespace, nil,
+		append([]string{"ceph", "osd", "set-group", "noout"}, buckets...)...); err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	status.Nodes = nodes
+	status.CrushBuckets = buckets
+	status.StartedAt = &now
+	status.Phase = storagev1alpha1.MaintenancePhaseDraining
+	status.Message = fmt.Sprintf("Set noout on %s and norebalance", strings.Join(buckets, ", "))
+
+	message := fmt.Sprintf("Maintenance of %s started", strings.Join(nodes, ", "))
+	log.FromContext(ctx).Info(message)
+	r.Recorder.Event(maintenance, corev1.EventTypeNormal, "MaintenanceStarted", message)
+	return nil
+}
+
+// resolveTargets returns the nodes in maintenance and the CRUSH buckets to set the noout flag on
+func (r *KoorMaintenanceReconciler) resolveTargets(
+	ctx context.Context,
+	maintenance *storagev1alpha1.KoorMaintenance,
+) ([]string, []string, error) {
+	var nodes, buckets []string
+	for _, name := range maintenance.Spec.Nodes {
+		node := &corev1.Node{}
+		if err := r.Get(ctx, client.ObjectKey{Name: name}, node); err != nil {
+			return nil, nil, fmt.Errorf("getting node %s failed: %w", name, err)
+		}
+		nodes = append(nodes, node.Name)
+		hostname := node.Labels[hostnameLabel]
+		if hostname == "" {
+			hostname = node.Name
+		}
+		buckets = append(buckets, crushName(hostname))
+	}
+
+	for _, domain := range maintenance.Spec.FailureDomains {
+		nodeList := &corev1.NodeList{}
+		if err := r.List(ctx, nodeList, client.MatchingLabels{domain.Label: domain.Value}); err != nil {
+			return nil, nil, err
+		}
+		if len(nodeList.Items) == 0 {
+			return nil, nil, fmt.Errorf("no nodes have the label %s=%s", domain.Label, domain.Value)
+		}
+		for _, node := range nodeList.Items {
+			nodes = append(nodes, node.Name)
+		}
+		buckets = append(buckets, crushName(domain.Value))
+	}
+
+	slices.Sort(nodes)
+	slices.Sort(buckets)
+	return slices.Compact(nodes), slices.Compact(buckets), nil
+}
+
+// drainNodes cordons and drains the nodes one after another, so that the PodDisruptionBudgets
+// of rook only see one node going down at a time. It returns true once all nodes are drained.
+func (r *KoorMaintenanceReconciler) drainNodes(ctx context.Context, maintenance *storagev1alpha1.KoorMaintenance) (bool, error) {
+	status := &maintenance.Status
+	for _, nodeName := range status.Nodes {
+		if slices.Contains(status.DrainedNodes, nodeName) {
+			continue
+		}
+
+		node := &corev1.Node{}
+		if err := r.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
+			return false, err
+		}
+		if !node.Spec.Unschedulable {
+			node.Spec.Unschedulable = true
+			if err := r.Update(ctx, node); err != nil {
+				return false, fmt.Errorf("cordoning node %s failed: %w", nodeName, err)
+			}
+			status.CordonedNodes = append(status.CordonedNodes, nodeName)
+		}
+
+		remaining, err := r.evictPods(ctx, nodeName)
+		if err != nil {
+			return false, err
+		}
+		if remaining > 0 {
+			status.Message = fmt.Sprintf("Draining node %s, %d pods left", nodeName, remaining)
+			return false, nil
+		}
+		status.DrainedNodes = append(status.DrainedNodes, nodeName)
+	}
+	return true, nil
+}
+
+// evictPods evicts the pods of the node and returns how many are left. Evictions that would
+// violate a PodDisruptionBudget are refused by the api server and retried later.
+func (r *KoorMaintenanceReconciler) evictPods(ctx context.Context, nodeName string) (int, error) {
+	log := log.FromContext(ctx)
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.MatchingFields{podNodeNameField: nodeName}); err != nil {
+		return 0, err
+	}
+
+	remaining := 0
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if !needsEviction(pod) {
+			continue
+		}
+		remaining++
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+		err := r.SubResource("eviction").Create(ctx, pod, eviction)
+		switch {
+		case err == nil:
+			log.Info("Evicted pod", "pod", client.ObjectKeyFromObject(pod), "node", nodeName)
+		case k8serrors.IsNotFound(err):
+			remaining--
+		case k8serrors.IsTooManyRequests(err):
+			log.Info("The eviction is blocked by a PodDisruptionBudget", "pod", client.ObjectKeyFromObject(pod))
+		default:
+			return 0, fmt.Errorf("evicting pod %s/%s failed: %w", pod.Namespace, pod.Name, err)
+		}
+	}
+	return remaining, nil
+}
+
+// needsEviction skips the pods a drain leaves on the node
+func needsEviction(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, mirror := pod.Annotations[corev1.MirrorPodAnnotationKey]; mirror {
+		return false
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
+
+// restore uncordons the nodes and unsets the ceph flags
+func (r *KoorMaintenanceReconciler) restore(ctx context.Context, maintenance *storagev1alpha1.KoorMaintenance) error {
+	status := &maintenance.Status
+	for len(status.CordonedNodes) > 0 {
+		nodeName := status.CordonedNodes[0]
+		node := &corev1.Node{}
+		err := r.Get(ctx, client.ObjectKey{Name: nodeName}, node)
+		switch {
+		case k8serrors.IsNotFound(err):
+		case err != nil:
+			return err
+		case node.Spec.Unschedulable:
+			node.Spec.Unschedulable = false
+			if err := r.Update(ctx, node); err != nil {
+				return fmt.Errorf("uncordoning node %s failed: %w", nodeName, err)
+			}
+		}
+		status.CordonedNodes = status.CordonedNodes[1:]
+	}
+
+	if len(status.CrushBuckets) > 0 {
+		if _, err := r.toolbox.Exec(ctx, maintenance.Namespace, nil,
+			append([]string{"ceph", "osd", "unset-group", "noout"}, status.CrushBuckets...)...); err != nil {
+			return err
+		}
+	}
+
+	// Other maintenances still need the cluster wide flag
+	maintenanceList := &storagev1alpha1.KoorMaintenanceList{}
+	if err := r.List(ctx, maintenanceList, client.InNamespace(maintenance.Namespace)); err != nil {
+		return err
+	}
+	for _, other := range maintenanceList.Items {
+		if other.Name != maintenance.Name && other.Status.IsActive() &&
+			other.Status.Phase != storagev1alpha1.MaintenancePhaseRecovering {
+			status.Message = fmt.Sprintf("Unset noout, norebalance stays set for %s", other.Name)
+			return nil
+		}
+	}
+	if _, err := r.toolbox.Exec(ctx, maintenance.Namespace, nil, "ceph", "osd", "unset", "norebalance"); err != nil {
+		return err
+	}
+	status.Message = "Unset noout and norebalance"
+	return nil
+}
+
+// pgsClean returns true if all placement groups are active+clean
+func (r *KoorMaintenanceReconciler) pgsClean(ctx context.Context, namespace string) (bool, error) {
+	output, err := r.toolbox.Exec(ctx, namespace, nil, "ceph", "pg", "stat", "--format", "json")
+	if err != nil {
+		return false, err
+	}
+	return parsePgsClean(output)
+}
+
+type pgSummary struct {
+	NumPgByState []struct {
+		Name string `json:"name"`
+		Num  int64  `json:"num"`
+	} `json:"num_pg_by_state"`
+	NumPgs int64 `json:"num_pgs"`
+}
+
+// parsePgsClean reads `ceph pg stat --format json`. Newer ceph versions nest the counts in pg_summary.
+func parsePgsClean(output string) (bool, error) {
+	var stat struct {
+		pgSummary
+		PgSummary *pgSummary `json:"pg_summary"`
+	}
+	if err := json.Unmarshal([]byte(output), &stat); err != nil {
+		return false, fmt.Errorf("parsing placement group states failed: %w", err)
+	}
+	summary := stat.pgSummary
+	if stat.PgSummary != nil {
+		summary = *stat.PgSummary
+	}
+	for _, state := range summary.NumPgByState {
+		if state.Name == "active+clean" {
+			return state.Num == summary.NumPgs, nil
+		}
+	}
+	return summary.NumPgs == 0, nil
+}
+
+// crushName converts a host name like rook does for the CRUSH map
+func crushName(name string) string {
+	return strings.ReplaceAll(name, ".", "-")
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KoorMaintenanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podNodeNameField,
+		func(obj client.Object) []string {
+			return []string{obj.(*corev1.Pod).Spec.NodeName}
+		}); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&storagev1alpha1.KoorMaintenance{}).
+		Complete(r)
+}
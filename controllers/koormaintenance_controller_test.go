@@ -0,0 +1,183 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+)
+
+var _ = Describe("KoorMaintenance controller", func() {
+	const (
+		namespace = "rook-ceph"
+		nodeName  = "storage-1"
+	)
+
+	var (
+		ctx         context.Context
+		k8sClient   client.Client
+		mockToolbox *mocks.MockCephToolbox
+		reconciler  *KoorMaintenanceReconciler
+		request     ctrl.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockToolbox = mocks.NewMockCephToolbox(gomock.NewController(GinkgoT()))
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		k8sClient = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithStatusSubresource(&storagev1alpha1.KoorMaintenance{}).
+			WithIndex(&corev1.Pod{}, podNodeNameField, func(obj client.Object) []string {
+				return []string{obj.(*corev1.Pod).Spec.NodeName}
+			}).
+			WithObjects(
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:   nodeName,
+					Labels: map[string]string{hostnameLabel: "storage-1.example.com"},
+				}},
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "rook-ceph-osd-0", Namespace: namespace},
+					Spec:       corev1.PodSpec{NodeName: nodeName},
+				},
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "csi-rbdplugin",
+						Namespace:       namespace,
+						OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "csi-rbdplugin"}},
+					},
+					Spec: corev1.PodSpec{NodeName: nodeName},
+				},
+			).
+			Build()
+
+		reconciler = &KoorMaintenanceReconciler{
+			Client:   k8sClient,
+			Scheme:   scheme,
+			Recorder: record.NewFakeRecorder(10),
+			toolbox:  mockToolbox,
+		}
+		request = ctrl.Request{NamespacedName: client.ObjectKey{Name: "patching", Namespace: namespace}}
+		Expect(k8sClient.Create(ctx, &storagev1alpha1.KoorMaintenance{
+			ObjectMeta: metav1.ObjectMeta{Name: request.Name, Namespace: namespace},
+			Spec:       storagev1alpha1.KoorMaintenanceSpec{Nodes: []string{nodeName}},
+		})).To(Succeed())
+	})
+
+	reconcile := func() (ctrl.Result, *storagev1alpha1.KoorMaintenance) {
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		maintenance := &storagev1alpha1.KoorMaintenance{}
+		if err := k8sClient.Get(ctx, request.NamespacedName, maintenance); err != nil {
+			return result, nil
+		}
+		return result, maintenance
+	}
+
+	expectFlags := func(set string) {
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", set, "norebalance").Return("", nil)
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", set+"-group", "noout",
+			"storage-1-example-com").Return("", nil)
+	}
+
+	isCordoned := func() bool {
+		node := &corev1.Node{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: nodeName}, node)).To(Succeed())
+		return node.Spec.Unschedulable
+	}
+
+	It("Should set the flags, drain the node and restore the cluster once done", func() {
+		By("Setting the flags and draining the node")
+		expectFlags("set")
+		result, maintenance := reconcile()
+		Expect(maintenance.Status.Phase).To(Equal(storagev1alpha1.MaintenancePhaseDraining))
+		Expect(maintenance.Status.CrushBuckets).To(Equal([]string{"storage-1-example-com"}))
+		Expect(maintenance.Status.CordonedNodes).To(Equal([]string{nodeName}))
+		Expect(result.RequeueAfter).To(Equal(maintenancePollInterval))
+		Expect(isCordoned()).To(BeTrue())
+
+		pods := &corev1.PodList{}
+		Expect(k8sClient.List(ctx, pods)).To(Succeed())
+		Expect(pods.Items).To(HaveLen(1))
+		Expect(pods.Items[0].Name).To(Equal("csi-rbdplugin"))
+
+		By("Waiting until the evicted pods are gone")
+		result, maintenance = reconcile()
+		Expect(maintenance.Status.Phase).To(Equal(storagev1alpha1.MaintenancePhaseReady))
+		Expect(maintenance.Status.DrainedNodes).To(Equal([]string{nodeName}))
+		Expect(result.RequeueAfter).To(BeZero())
+
+		By("Restoring the cluster once the work is done")
+		maintenance.Spec.Done = true
+		Expect(k8sClient.Update(ctx, maintenance)).To(Succeed())
+		expectFlags("unset")
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "pg", "stat", "--format", "json").Return(
+			`{"pg_summary":{"num_pg_by_state":[{"name":"active+clean","num":30},`+
+				`{"name":"active+undersized+degraded","num":3}],"num_pgs":33}}`, nil)
+		result, maintenance = reconcile()
+		Expect(maintenance.Status.Phase).To(Equal(storagev1alpha1.MaintenancePhaseRecovering))
+		Expect(result.RequeueAfter).To(Equal(maintenancePollInterval))
+		Expect(isCordoned()).To(BeFalse())
+
+		By("Completing once all placement groups are active+clean")
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "pg", "stat", "--format", "json").Return(
+			`{"pg_summary":{"num_pg_by_state":[{"name":"active+clean","num":33}],"num_pgs":33}}`, nil)
+		_, maintenance = reconcile()
+		Expect(maintenance.Status.Phase).To(Equal(storagev1alpha1.MaintenancePhaseCompleted))
+		Expect(maintenance.Status.CompletedAt).NotTo(BeNil())
+	})
+
+	It("Should restore the cluster when the maintenance is deleted", func() {
+		expectFlags("set")
+		_, maintenance := reconcile()
+		Expect(k8sClient.Delete(ctx, maintenance)).To(Succeed())
+
+		expectFlags("unset")
+		_, maintenance = reconcile()
+		Expect(maintenance).To(BeNil())
+		Expect(isCordoned()).To(BeFalse())
+	})
+
+	DescribeTable("Should parse the placement group states",
+		func(output string, expected bool) {
+			clean, err := parsePgsClean(output)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(clean).To(Equal(expected))
+		},
+		Entry("when all are active+clean", `{"pg_summary":{"num_pg_by_state":[{"name":"active+clean","num":8}],"num_pgs":8}}`, true),
+		Entry("when some are peering", `{"pg_summary":{"num_pg_by_state":[{"name":"active+clean","num":7},{"name":"peering","num":1}],"num_pgs":8}}`, false),
+		Entry("when none are clean", `{"pg_summary":{"num_pg_by_state":[{"name":"peering","num":8}],"num_pgs":8}}`, false),
+		Entry("in the format of older ceph versions", `{"num_pg_by_state":[{"name":"active+clean","num":8}],"num_pgs":8}`, true),
+	)
+})
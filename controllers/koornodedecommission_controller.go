@@ -0,0 +1,369 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+// KoorNodeDecommissionReconciler reconciles a KoorNodeDecommission object
+type KoorNodeDecommissionReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	toolbox  utils.CephToolbox
+}
+
+func NewKoorNodeDecommissionReconciler(mgr ctrl.Manager) *KoorNodeDecommissionReconciler {
+	return &KoorNodeDecommissionReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("koornodedecommission-controller"),
+		toolbox:  utils.NewCephToolbox(mgr.GetConfig()),
+	}
+}
+
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koornodedecommissions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koornodedecommissions/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update;patch;delete
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koorclusters,verbs=get;list;watch
+
+// Reconcile moves the data off the OSDs of the node, removes the node from the CephCluster
+// and purges its OSDs
+func (r *KoorNodeDecommissionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	decommission := &storagev1alpha1.KoorNodeDecommission{}
+	if err := r.Get(ctx, req.NamespacedName, decommission); err != nil {
+		log.Error(err, "unable to fetch KoorNodeDecommission")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if decommission.Status.Phase == storagev1alpha1.DecommissionPhaseCompleted {
+		return ctrl.Result{}, nil
+	}
+
+	result, reconcileErr := r.reconcileDecommission(ctx, decommission)
+	if err := r.Status().Update(ctx, decommission); err != nil {
+		log.Error(err, "Unable to update KoorNodeDecommission status")
+		return ctrl.Result{}, err
+	}
+	return result, reconcileErr
+}
+
+// reconcileDecommission advances the decommission through its phases
+func (r *KoorNodeDecommissionReconciler) reconcileDecommission(
+	ctx context.Context,
+	decommission *storagev1alpha1.KoorNodeDecommission,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	status := &decommission.Status
+	namespace := decommission.Namespace
+
+	for {
+		switch status.Phase {
+		case "":
+			if err := r.start(ctx, decommission); err != nil {
+				log.Error(err, "Cannot start the decommission")
+				status.Message = err.Error()
+				return ctrl.Result{}, err
+			}
+
+		case storagev1alpha1.DecommissionPhaseMigrating:
+			migrated, err := r.dataMigrated(ctx, decommission)
+			if err != nil {
+				log.Error(err, "Cannot check the data migration")
+				return ctrl.Result{}, err
+			}
+			if !migrated {
+				return ctrl.Result{RequeueAfter: maintenancePollInterval}, nil
+			}
+			status.Phase = storagev1alpha1.DecommissionPhaseRemovingNode
+			status.Message = fmt.Sprintf("Removing %s from the CephCluster storage nodes", status.StorageNodeName)
+
+		case storagev1alpha1.DecommissionPhaseRemovingNode:
+			cephCluster, err := findCephCluster(ctx, r.Client, namespace)
+			if err != nil {
+				log.Error(err, "unable to fetch CephCluster")
+				return ctrl.Result{}, err
+			}
+			// The KoorCluster controller renders the storage nodes without the node
+			if cephCluster != nil && storageUsesNode(cephCluster, status.StorageNodeName) {
+				reason, message, err := r.storageNodesWait(ctx, decommission)
+				if err != nil {
+					log.Error(err, "unable to fetch the KoorCluster")
+					return ctrl.Result{}, err
+				}
+				meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+					Type:    storagev1alpha1.ConditionStorageNodesApplied,
+					Status:  metav1.ConditionFalse,
+					Reason:  reason,
+					Message: message,
+				})
+				status.Message = message
+				return ctrl.Result{RequeueAfter: maintenancePollInterval}, nil
+			}
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    storagev1alpha1.ConditionStorageNodesApplied,
+				Status:  metav1.ConditionTrue,
+				Reason:  "NodeRemoved",
+				Message: fmt.Sprintf("%s is removed from the CephCluster storage nodes", status.StorageNodeName),
+			})
+			status.Phase = storagev1alpha1.DecommissionPhasePurging
+
+		case storagev1alpha1.DecommissionPhasePurging:
+			if err := r.purgeOsds(ctx, decommission); err != nil {
+				log.Error(err, "Cannot purge the OSDs")
+				status.Message = err.Error()
+				return ctrl.Result{}, err
+			}
+			now := metav1.Now()
+			status.Phase = storagev1alpha1.DecommissionPhaseCompleted
+			status.CompletedAt = &now
+			status.Message = fmt.Sprintf("Node %s is decommissioned, %d OSDs were purged",
+				decommission.Spec.NodeName, len(status.PurgedOsds))
+			log.Info(status.Message)
+			r.Recorder.Event(decommission, corev1.EventTypeNormal, "DecommissionCompleted", status.Message)
+
+		default:
+			return ctrl.Result{}, nil
+		}
+	}
+}
+
+// start finds the OSDs of the node and marks them out, so that ceph migrates their data
+func (r *KoorNodeDecommissionReconciler) start(ctx context.Context, decommission *storagev1alpha1.KoorNodeDecommission) error {
+	status := &decommission.Status
+	namespace := decommission.Namespace
+
+	// The node may already be gone, its OSDs are still in the CRUSH map
+	hostname := decommission.Spec.NodeName
+	node := &corev1.Node{}
+	err := r.Get(ctx, client.ObjectKey{Name: decommission.Spec.NodeName}, node)
+	switch {
+	case err == nil && node.Labels[hostnameLabel] != "":
+		hostname = node.Labels[hostnameLabel]
+	case err != nil && !k8serrors.IsNotFound(err):
+		return err
+	}
+
+	output, err := r.toolbox.Exec(ctx, namespace, nil, "ceph", "osd", "ls-tree", crushName(hostname), "--format", "json")
+	if err != nil {
+		return err
+	}
+	var osds []int
+	if err := json.Unmarshal([]byte(output), &osds); err != nil {
+		return fmt.Errorf("parsing the OSDs of %s failed: %w", hostname, err)
+	}
+	slices.Sort(osds)
+
+	if len(osds) > 0 {
+		if _, err := r.toolbox.Exec(ctx, namespace, nil, append([]string{"ceph", "osd", "out"}, osdIDs(osds)...)...); err != nil {
+			return err
+		}
+	}
+
+	now := metav1.Now()
+	status.StorageNodeName = hostname
+	status.Osds = osds
+	status.StartedAt = &now
+	status.Phase = storagev1alpha1.DecommissionPhaseMigrating
+	status.Message = fmt.Sprintf("Marked %d OSDs out", len(osds))
+
+	message := fmt.Sprintf("Decommissioning node %s with OSDs %v", decommission.Spec.NodeName, osds)
+	log.FromContext(ctx).Info(message)
+	r.Recorder.Event(decommission, corev1.EventTypeNormal, "DecommissionStarted", message)
+	return nil
+}
+
+// dataMigrated returns true once the OSDs of the node hold no placement groups, all placement
+// groups are active+clean and ceph agrees that the OSDs are safe to destroy
+func (r *KoorNodeDecommissionReconciler) dataMigrated(
+	ctx context.Context,
+	decommission *storagev1alpha1.KoorNodeDecommission,
+) (bool, error) {
+	status := &decommission.Status
+	namespace := decommission.Namespace
+	if len(status.Osds) == 0 {
+		return true, nil
+	}
+
+	output, err := r.toolbox.Exec(ctx, namespace, nil, "ceph", "osd", "df", "--format", "json")
+	if err != nil {
+		return false, err
+	}
+	remaining, err := parseOsdPGs(output, status.Osds)
+	if err != nil {
+		return false, err
+	}
+	status.RemainingPGs = remaining
+	if remaining > 0 {
+		status.Message = fmt.Sprintf("Migrating %d placement groups off the OSDs", remaining)
+		return false, nil
+	}
+
+	output, err = r.toolbox.Exec(ctx, namespace, nil, "ceph", "pg", "stat", "--format", "json")
+	if err != nil {
+		return false, err
+	}
+	clean, err := parsePgsClean(output)
+	if err != nil {
+		return false, err
+	}
+	if !clean {
+		status.Message = "Waiting for all placement groups to be active+clean"
+		return false, nil
+	}
+
+	if _, err := r.toolbox.Exec(ctx, namespace, nil,
+		append([]string{"ceph", "osd", "safe-to-destroy"}, osdIDs(status.Osds)...)...); err != nil {
+		status.Message = fmt.Sprintf("The OSDs are not safe to destroy yet: %s", err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// purgeOsds stops the OSD deployments and removes the OSDs from the ceph cluster
+func (r *KoorNodeDecommissionReconciler) purgeOsds(ctx context.Context, decommission *storagev1alpha1.KoorNodeDecommission) error {
+	status := &decommission.Status
+	namespace := decommission.Namespace
+
+	for _, id := range status.Osds {
+		if slices.Contains(status.PurgedOsds, id) {
+			continue
+		}
+
+		if err := purgeOsd(ctx, r.Client, r.toolbox, namespace, id); err != nil {
+			return err
+		}
+		status.PurgedOsds = append(status.PurgedOsds, id)
+	}
+	return nil
+}
+
+// storageNodesWait explains why the CephCluster still uses the node. The KoorCluster controller applies
+// the storage nodes with the cluster chart, which it does not change while the cluster is paused,
+// an upgrade runs or a ceph upgrade waits for the maintenance window.
+func (r *KoorNodeDecommissionReconciler) storageNodesWait(
+	ctx context.Context,
+	decommission *storagev1alpha1.KoorNodeDecommission,
+) (reason string, message string, err error) {
+	node := decommission.Status.StorageNodeName
+	koorClusterList := &storagev1alpha1.KoorClusterList{}
+	if err := r.List(ctx, koorClusterList, client.InNamespace(decommission.Namespace)); err != nil {
+		return "", "", err
+	}
+	if len(koorClusterList.Items) == 0 {
+		return "KoorClusterMissing", fmt.Sprintf("No KoorCluster in namespace %s removes %s from the storage nodes",
+			decommission.Namespace, node), nil
+	}
+
+	koorCluster := &koorClusterList.Items[0]
+	status := &koorCluster.Status
+	switch {
+	case koorCluster.IsPaused():
+		return "ClusterPaused", fmt.Sprintf("KoorCluster %s is paused, %s is removed from the storage nodes once it is resumed",
+			koorCluster.Name, node), nil
+	case !slices.Contains(status.DecommissionedNodes, node):
+		return "WaitingForKoorCluster", fmt.Sprintf("Waiting for KoorCluster %s to remove %s from the storage nodes",
+			koorCluster.Name, node), nil
+	case status.Upgrade.InProgress():
+		return "UpgradeInProgress", fmt.Sprintf("%s is removed from the storage nodes once the upgrade of KoorCluster %s completes",
+			node, koorCluster.Name), nil
+	case meta.IsStatusConditionFalse(status.Conditions, storagev1alpha1.ConditionInMaintenanceWindow):
+		return "OutsideMaintenanceWindow", fmt.Sprintf("KoorCluster %s is outside its maintenance window, %s is removed from the storage nodes "+
+			"in the next window", koorCluster.Name, node), nil
+	}
+	return "WaitingForRook", fmt.Sprintf("Waiting for rook to remove %s from the CephCluster storage nodes", node), nil
+}
+
+// storageUsesNode returns true if rook may still create OSDs on the node
+func storageUsesNode(cephCluster *unstructured.Unstructured, storageNodeName string) bool {
+	useAllNodes, _, _ := unstructured.NestedBool(cephCluster.Object, "spec", "storage", "useAllNodes")
+	if useAllNodes {
+		return !osdPlacementExcludesNode(cephCluster, storageNodeName)
+	}
+	nodes, _, _ := unstructured.NestedSlice(cephCluster.Object, "spec", "storage", "nodes")
+	for _, node := range nodes {
+		if node, ok := node.(map[string]any); ok && node["name"] == storageNodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// osdPlacementExcludesNode returns true if the node affinity of the OSDs rules out the node by its hostname
+func osdPlacementExcludesNode(cephCluster *unstructured.Unstructured, storageNodeName string) bool {
+	terms, _, _ := unstructured.NestedSlice(cephCluster.Object, "spec", "placement", "osd", "nodeAffinity",
+		"requiredDuringSchedulingIgnoredDuringExecution", "nodeSelectorTerms")
+	for _, term := range terms {
+		term, _ := term.(map[string]any)
+		expressions, _, _ := unstructured.NestedSlice(term, "matchExpressions")
+		for _, expression := range expressions {
+			expression, _ := expression.(map[string]any)
+			values, _, _ := unstructured.NestedStringSlice(expression, "values")
+			if expression["key"] == hostnameLabel && expression["operator"] == "NotIn" &&
+				slices.Contains(values, storageNodeName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseOsdPGs sums the placement groups of the OSDs in the output of `ceph osd df --format json`
+func parseOsdPGs(output string, osds []int) (int64, error) {
+	var df struct {
+		Nodes []struct {
+			ID  int   `json:"id"`
+			PGs int64 `json:"pgs"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal([]byte(output), &df); err != nil {
+		return 0, fmt.Errorf("parsing OSD usage failed: %w", err)
+	}
+	var pgs int64
+	for _, node := range df.Nodes {
+		if slices.Contains(osds, node.ID) {
+			pgs += node.PGs
+		}
+	}
+	return pgs, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KoorNodeDecommissionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&storagev1alpha1.KoorNodeDecommission{}).
+		Complete(r)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+)
+
+var _ = Describe("KoorNodeDecommission controller", func() {
+	const namespace = "rook-ceph"
+
+	var (
+		ctx         context.Context
+		k8sClient   client.Client
+		mockToolbox *mocks.MockCephToolbox
+		reconciler  *KoorNodeDecommissionReconciler
+		request     ctrl.Request
+		cephCluster *unstructured.Unstructured
+	)
+
+	newNode := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{hostnameLabel: name},
+		}}
+	}
+
+	newOsd := func(id int, node string) *appsv1.Deployment {
+		replicas := int32(1)
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("rook-ceph-osd-%d", id),
+				Namespace: namespace,
+				Labels:    map[string]string{"app": osdAppLabel},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					NodeSelector: map[string]string{hostnameLabel: node},
+				}},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockToolbox = mocks.NewMockCephToolbox(gomock.NewController(GinkgoT()))
+
+		cephCluster = &unstructured.Unstructured{Object: map[string]any{
+			"spec": map[string]any{"storage": map[string]any{"useAllNodes": true}},
+		}}
+		cephCluster.SetGroupVersionKind(cephClusterGVK)
+		cephCluster.SetName("rook-ceph")
+		cephCluster.SetNamespace(namespace)
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		scheme.AddKnownTypeWithName(cephClusterGVK, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(cephClusterGVK.GroupVersion().WithKind(cephClusterGVK.Kind+"List"),
+			&unstructured.UnstructuredList{})
+		k8sClient = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithStatusSubresource(&storagev1alpha1.KoorNodeDecommission{}, &storagev1alpha1.KoorCluster{}).
+			WithObjects(
+				newNode("storage-1"),
+				newNode("storage-2"),
+				newNode("storage-3"),
+				newNode("control-plane-1"),
+				newOsd(1, "storage-2"),
+				newOsd(2, "storage-1"),
+				newOsd(3, "storage-1"),
+				newOsd(4, "storage-3"),
+				cephCluster,
+			).
+			Build()
+
+		reconciler = &KoorNodeDecommissionReconciler{
+			Client:   k8sClient,
+			Scheme:   scheme,
+			Recorder: record.NewFakeRecorder(10),
+			toolbox:  mockToolbox,
+		}
+		request = ctrl.Request{NamespacedName: client.ObjectKey{Name: "storage-3", Namespace: namespace}}
+		Expect(k8sClient.Create(ctx, &storagev1alpha1.KoorNodeDecommission{
+			ObjectMeta: metav1.ObjectMeta{Name: request.Name, Namespace: namespace},
+			Spec:       storagev1alpha1.KoorNodeDecommissionSpec{NodeName: "storage-3"},
+		})).To(Succeed())
+	})
+
+	reconcile := func() (ctrl.Result, *storagev1alpha1.KoorNodeDecommission) {
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		decommission := &storagev1alpha1.KoorNodeDecommission{}
+		Expect(k8sClient.Get(ctx, request.NamespacedName, decommission)).To(Succeed())
+		return result, decommission
+	}
+
+	expectOsdDf := func(pgs int) {
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "df", "--format", "json").Return(
+			`{"nodes":[{"id":3,"pgs":40},{"id":4,"pgs":`+fmt.Sprint(pgs)+`},{"id":5,"pgs":0}]}`, nil)
+	}
+
+	It("Should migrate the data, remove the node and purge its OSDs", func() {
+		By("Marking the OSDs out")
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "ls-tree", "storage-3",
+				"--format", "json").Return("[5,4]", nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "out", "4", "5").Return("", nil),
+		)
+		expectOsdDf(12)
+		result, decommission := reconcile()
+		Expect(decommission.Status.Phase).To(Equal(storagev1alpha1.DecommissionPhaseMigrating))
+		Expect(decommission.Status.Osds).To(Equal([]int{4, 5}))
+		Expect(decommission.Status.RemainingPGs).To(Equal(int64(12)))
+		Expect(result.RequeueAfter).To(Equal(maintenancePollInterval))
+
+		By("Removing the node once the data migrated")
+		expectOsdDf(0)
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "pg", "stat", "--format", "json").Return(
+				`{"pg_summary":{"num_pg_by_state":[{"name":"active+clean","num":33}],"num_pgs":33}}`, nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "safe-to-destroy", "4", "5").Return("", nil),
+		)
+		result, decommission = reconcile()
+		Expect(decommission.Status.Phase).To(Equal(storagev1alpha1.DecommissionPhaseRemovingNode))
+		Expect(result.RequeueAfter).To(Equal(maintenancePollInterval))
+
+		By("Explaining why the node is not removed yet")
+		koorCluster := &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "koor", Namespace: namespace},
+			Spec:       storagev1alpha1.KoorClusterSpec{Paused: true},
+		}
+		Expect(k8sClient.Create(ctx, koorCluster)).To(Succeed())
+		result, decommission = reconcile()
+		Expect(decommission.Status.Phase).To(Equal(storagev1alpha1.DecommissionPhaseRemovingNode))
+		Expect(result.RequeueAfter).To(Equal(maintenancePollInterval))
+		condition := meta.FindStatusCondition(decommission.Status.Conditions, storagev1alpha1.ConditionStorageNodesApplied)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("ClusterPaused"))
+		Expect(decommission.Status.Message).To(Equal(condition.Message))
+
+		By("Rendering the storage nodes of the OSDs without the node")
+		koorReconciler := &KoorClusterReconciler{Client: k8sClient}
+		Expect(koorReconciler.reconcileStorageNodes(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.StorageNodes).To(Equal([]string{"storage-1", "storage-2"}))
+		Expect(koorCluster.Status.DecommissionedNodes).To(Equal([]string{"storage-3"}))
+
+		cephClusterSpec := renderClusterValues(koorCluster)["cephClusterSpec"].(map[string]any)
+		Expect(cephClusterSpec["storage"]).NotTo(HaveKey("useAllNodes"))
+		placement := cephClusterSpec["placement"]
+		Expect(placement).To(HaveKeyWithValue("osd", map[string]any{
+			"nodeAffinity": map[string]any{
+				"requiredDuringSchedulingIgnoredDuringExecution": map[string]any{
+					"nodeSelectorTerms": []any{map[string]any{
+						"matchExpressions": []any{map[string]any{
+							"key": hostnameLabel, "operator": "NotIn", "values": []any{"storage-3"},
+						}},
+					}},
+				},
+			},
+		}))
+
+		By("Purging the OSDs once rook applied the placement")
+		Expect(unstructured.SetNestedField(cephCluster.Object, placement, "spec", "placement")).To(Succeed())
+		Expect(k8sClient.Update(ctx, cephCluster)).To(Succeed())
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "purge", "4",
+				"--yes-i-really-mean-it").Return("", nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "purge", "5",
+				"--yes-i-really-mean-it").Return("", nil),
+		)
+		_, decommission = reconcile()
+		Expect(decommission.Status.Phase).To(Equal(storagev1alpha1.DecommissionPhaseCompleted))
+		Expect(decommission.Status.PurgedOsds).To(Equal([]int{4, 5}))
+		Expect(meta.IsStatusConditionTrue(decommission.Status.Conditions,
+			storagev1alpha1.ConditionStorageNodesApplied)).To(BeTrue())
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: "rook-ceph-osd-4", Namespace: namespace}, &appsv1.Deployment{})
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should not remove the node while the OSDs are not safe to destroy", func() {
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "ls-tree", "storage-3",
+				"--format", "json").Return("[4]", nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "out", "4").Return("", nil),
+		)
+		expectOsdDf(0)
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "pg", "stat", "--format", "json").Return(
+				`{"pg_summary":{"num_pg_by_state":[{"name":"active+clean","num":33}],"num_pgs":33}}`, nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "safe-to-destroy", "4").Return(
+				"", errors.New("OSD(s) 4 have 3 pgs currently mapped to them")),
+		)
+		_, decommission := reconcile()
+		Expect(decommission.Status.Phase).To(Equal(storagev1alpha1.DecommissionPhaseMigrating))
+		Expect(decommission.Status.Message).To(ContainSubstring("not safe to destroy"))
+	})
+})
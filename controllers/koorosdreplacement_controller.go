@@ -0,0 +1,459 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+const (
+	// Wipes the partition table and the start of the disk, so that rook creates an OSD on it.
+	// Kernel names change across reboots, so the disk of the failed OSD is looked up by the serial of its
+	// device id. The disk is left alone if it, a partition or a logical volume on it is mounted or belongs
+	// to another OSD.
+	zapScript = `set -e
+device="$DEVICE"
+if [ -n "$DEVICE_ID" ]; then
+  device=""
+  for link in /dev/disk/by-id/*_"${DEVICE_ID##*_}"; do
+    if [ -e "$link" ]; then
+      device="$link"
+      break
+    fi
+  done
+  if [ -z "$device" ]; then
+    echo "The disk $DEVICE_ID of OSD $OSD_ID is gone, set spec.device to wipe another disk" >&2
+    exit 1
+  fi
+  echo "Wiping $device"
+fi
+for dev in $(lsblk -nrpo NAME "$device"); do
+  if grep -q "^$dev " /proc/1/mounts; then
+    echo "$dev is mounted" >&2
+    exit 1
+  fi
+  whoami=$(ceph-bluestore-tool show-label --dev "$dev" 2>/dev/null | sed -n 's/.*"whoami": "\([0-9]*\)".*/\1/p')
+  if [ -n "$whoami" ] && [ "$whoami" != "$OSD_ID" ]; then
+    echo "$dev belongs to OSD $whoami" >&2
+    exit 1
+  fi
+done
+sgdisk --zap-all "$device"
+dd if=/dev/zero of="$device" bs=1M count=100 oflag=direct,dsync
+`
+	// The pod template annotation that restarts the pods of a deployment, as used by kubectl rollout restart
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+// KoorOsdReplacementReconciler reconciles a KoorOsdReplacement object
+type KoorOsdReplacementReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	toolbox  utils.CephToolbox
+}
+
+func NewKoorOsdReplacementReconciler(mgr ctrl.Manager) *KoorOsdReplacementReconciler {
+	return &KoorOsdReplacementReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("koorosdreplacement-controller"),
+		toolbox:  utils.NewCephToolbox(mgr.GetConfig()),
+	}
+}
+
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koorosdreplacements,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koorosdreplacements/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+
+// Reconcile replaces the disk of an OSD: it marks the OSD out, purges it once that is safe,
+// wipes the replacement disk and lets rook create a new OSD on it
+func (r *KoorOsdReplacementReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	replacement := &storagev1alpha1.KoorOsdReplacement{}
+	if err := r.Get(ctx, req.NamespacedName, replacement); err != nil {
+		log.Error(err, "unable to fetch KoorOsdReplacement")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if replacement.Status.IsFinished() {
+		return ctrl.Result{}, nil
+	}
+
+	result, reconcileErr := r.reconcileReplacement(ctx, replacement)
+	if err := r.Status().Update(ctx, replacement); err != nil {
+		log.Error(err, "Unable to update KoorOsdReplacement status")
+		return ctrl.Result{}, err
+	}
+	return result, reconcileErr
+}
+
+// reconcileReplacement advances the replacement through its phases
+func (r *KoorOsdReplacementReconciler) reconcileReplacement(
+	ctx context.Context,
+	replacement *storagev1alpha1.KoorOsdReplacement,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	status := &replacement.Status
+	namespace := replacement.Namespace
+	id := replacement.Spec.OsdID
+
+	for {
+		switch status.Phase {
+		case "":
+			if err := r.start(ctx, replacement); err != nil {
+				log.Error(err, "Cannot start the replacement")
+				status.Message = err.Error()
+				return ctrl.Result{}, err
+			}
+
+		case storagev1alpha1.ReplacementPhaseMigrating:
+			safe, err := r.safeToDestroy(ctx, replacement)
+			if err != nil {
+				log.Error(err, "Cannot check if the OSD is safe to destroy")
+				return ctrl.Result{}, err
+			}
+			if !safe {
+				return ctrl.Result{RequeueAfter: maintenancePollInterval}, nil
+			}
+			status.Phase = storagev1alpha1.ReplacementPhasePurging
+
+		case storagev1alpha1.ReplacementPhasePurging:
+			if err := purgeOsd(ctx, r.Client, r.toolbox, namespace, id); err != nil {
+				log.Error(err, "Cannot purge the OSD")
+				status.Message = err.Error()
+				return ctrl.Result{}, err
+			}
+			status.Phase = storagev1alpha1.ReplacementPhaseZapping
+			status.Message = fmt.Sprintf("Purged OSD %d, wiping %s on node %s", id, status.Device, status.Node)
+
+		case storagev1alpha1.ReplacementPhaseZapping:
+			zapped, err := r.zapDevice(ctx, replacement)
+			if err != nil {
+				log.Error(err, "Cannot wipe the replacement disk")
+				status.Message = err.Error()
+				return ctrl.Result{}, err
+			}
+			if !zapped {
+				return ctrl.Result{RequeueAfter: maintenancePollInterval}, nil
+			}
+			if status.Phase == storagev1alpha1.ReplacementPhaseFailed {
+				return ctrl.Result{}, nil
+			}
+			if err := r.recreateOsd(ctx, replacement); err != nil {
+				log.Error(err, "Cannot restart the rook operator")
+				status.Message = err.Error()
+				return ctrl.Result{}, err
+			}
+
+		case storagev1alpha1.ReplacementPhaseRecreating:
+			created, err := r.osdCreated(ctx, replacement)
+			if err != nil {
+				log.Error(err, "Cannot check the new OSD")
+				return ctrl.Result{}, err
+			}
+			if !created {
+				return ctrl.Result{RequeueAfter: maintenancePollInterval}, nil
+			}
+			now := metav1.Now()
+			status.Phase = storagev1alpha1.ReplacementPhaseCompleted
+			status.CompletedAt = &now
+			status.Message = fmt.Sprintf("Replaced OSD %d with OSD %d on %s", id, *status.NewOsdID, status.Device)
+			log.Info(status.Message)
+			r.Recorder.Event(replacement, corev1.EventTypeNormal, "OsdReplaced", status.Message)
+
+		default:
+			return ctrl.Result{}, nil
+		}
+	}
+}
+
+// start finds the node and disk of the OSD and marks it out, so that ceph recovers its data
+func (r *KoorOsdReplacementReconciler) start(ctx context.Context, replacement *storagev1alpha1.KoorOsdReplacement) error {
+	status := &replacement.Status
+	namespace := replacement.Namespace
+	id := replacement.Spec.OsdID
+
+	osd, err := getOsdLocation(ctx, r.toolbox, namespace, id)
+	if err != nil {
+		return err
+	}
+	if osd.Node == "" {
+		return fmt.Errorf("ceph reports no node for OSD %d", id)
+	}
+	if replacement.Spec.Device != "" {
+		// The disk is named explicitly, e.g. a new disk in the slot of the failed one
+		osd.Device = replacement.Spec.Device
+		osd.DeviceID = ""
+	}
+	if osd.Device == "" {
+		return fmt.Errorf("ceph reports no disk for OSD %d, set spec.device", id)
+	}
+
+	if _, err := r.toolbox.Exec(ctx, namespace, nil, "ceph", "osd", "out", strconv.Itoa(id)); err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	status.Node = osd.Node
+	status.Device = osd.Device
+	status.DeviceID = osd.DeviceID
+	status.StartedAt = &now
+	status.Phase = storagev1alpha1.ReplacementPhaseMigrating
+	status.Message = fmt.Sprintf("Marked OSD %d out", id)
+
+	message := fmt.Sprintf("Replacing %s", osd)
+	log.FromContext(ctx).Info(message)
+	r.Recorder.Event(replacement, corev1.EventTypeNormal, "OsdReplacementStarted", message)
+	return nil
+}
+
+// safeToDestroy is the health guard before the OSD is purged. It returns true if ceph is not in
+// HEALTH_ERR, all placement groups are active+clean and ceph agrees that the OSD is safe to destroy.
+func (r *KoorOsdReplacementReconciler) safeToDestroy(
+	ctx context.Context,
+	replacement *storagev1alpha1.KoorOsdReplacement,
+) (bool, error) {
+	status := &replacement.Status
+	namespace := replacement.Namespace
+
+	cephCluster, err := findCephCluster(ctx, r.Client, namespace)
+	if err != nil {
+		return false, err
+	}
+	if cephCluster == nil {
+		status.Message = "Waiting for the CephCluster"
+		return false, nil
+	}
+	if health := getCephClusterStatus(cephCluster).Health; health == "" || health == storagev1alpha1.CephHealthErr {
+		status.Message = fmt.Sprintf("Waiting for ceph to recover, the health is %q", health)
+		return false, nil
+	}
+
+	output, err := r.toolbox.Exec(ctx, namespace, nil, "ceph", "pg", "stat", "--format", "json")
+	if err != nil {
+		return false, err
+	}
+	clean, err := parsePgsClean(output)
+	if err != nil {
+		return false, err
+	}
+	if !clean {
+		status.Message = "Waiting for all placement groups to be active+clean"
+		return false, nil
+	}
+
+	if _, err := r.toolbox.Exec(ctx, namespace, nil, "ceph", "osd", "safe-to-destroy",
+		strconv.Itoa(replacement.Spec.OsdID)); err != nil {
+		status.Message = fmt.Sprintf("The OSD is not safe to destroy yet: %s", err)
+		return false, nil
+	}
+	return true, nil
+}
+
+func zapJobName(replacement *storagev1alpha1.KoorOsdReplacement) string {
+	return fmt.Sprintf("koor-zap-osd-%d", replacement.Spec.OsdID)
+}
+
+// zapDevice runs a job on the node that wipes the replacement disk. It returns true once the job
+// finished and sets the Failed phase if the job failed.
+func (r *KoorOsdReplacementReconciler) zapDevice(
+	ctx context.Context,
+	replacement *storagev1alpha1.KoorOsdReplacement,
+) (bool, error) {
+	status := &replacement.Status
+
+	job := &batchv1.Job{}
+	key := client.ObjectKey{Name: zapJobName(replacement), Namespace: replacement.Namespace}
+	err := r.Get(ctx, key, job)
+	if k8serrors.IsNotFound(err) {
+		job, err = r.newZapJob(ctx, replacement)
+		if err != nil {
+			return false, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return false, err
+		}
+		status.Message = fmt.Sprintf("Wiping %s on node %s", status.Device, status.Node)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			status.Phase = storagev1alpha1.ReplacementPhaseFailed
+			status.Message = fmt.Sprintf("Wiping %s on node %s failed: %s", status.Device, status.Node, condition.Message)
+			r.Recorder.Event(replacement, corev1.EventTypeWarning, "OsdReplacementFailed", status.Message)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *KoorOsdReplacementReconciler) newZapJob(
+	ctx context.Context,
+	replacement *storagev1alpha1.KoorOsdReplacement,
+) (*batchv1.Job, error) {
+	cephCluster, err := findCephCluster(ctx, r.Client, replacement.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if cephCluster == nil {
+		return nil, errors.New("cannot find the CephCluster for the ceph image")
+	}
+	// The ceph image ships the disk tools
+	image, _, _ := unstructured.NestedString(cephCluster.Object, "spec", "cephVersion", "image")
+	if image == "" {
+		return nil, errors.New("the CephCluster has no ceph image")
+	}
+
+	backoffLimit := int32(2)
+	privileged := true
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      zapJobName(replacement),
+			Namespace: replacement.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					NodeSelector:  map[string]string{hostnameLabel: replacement.Status.Node},
+					// The mounts of the node are checked before the disk is wiped
+					HostPID: true,
+					// Storage nodes are often tainted
+					Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					Containers: []corev1.Container{{
+						Name:    "zap",
+						Image:   image,
+						Command: []string{"sh", "-c", zapScript},
+						Env: []corev1.EnvVar{
+							{Name: "DEVICE", Value: replacement.Status.Device},
+							{Name: "DEVICE_ID", Value: replacement.Status.DeviceID},
+							{Name: "OSD_ID", Value: strconv.Itoa(replacement.Spec.OsdID)},
+						},
+						SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+						VolumeMounts:    []corev1.VolumeMount{{Name: "dev", MountPath: "/dev"}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "dev",
+						VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{Path: "/dev"},
+						},
+					}},
+				},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(replacement, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// recreateOsd remembers the OSDs of the node and restarts the rook operator,
+// which prepares OSDs on the empty disks when it starts
+func (r *KoorOsdReplacementReconciler) recreateOsd(ctx context.Context, replacement *storagev1alpha1.KoorOsdReplacement) error {
+	status := &replacement.Status
+	namespace := replacement.Namespace
+
+	tree, err := getOsdTree(ctx, r.toolbox, namespace)
+	if err != nil {
+		return err
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKey{Name: rookOperatorName, Namespace: namespace}, deployment); err != nil {
+		return err
+	}
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[restartedAtAnnotation] = time.Now().Format(time.RFC3339)
+	if err := r.Update(ctx, deployment); err != nil {
+		return err
+	}
+
+	status.HostOsds = tree.hostOsds(status.Node)
+	status.Phase = storagev1alpha1.ReplacementPhaseRecreating
+	status.Message = fmt.Sprintf("Waiting for rook to create an OSD on %s", status.Device)
+	return nil
+}
+
+// osdCreated returns true once the node has a new OSD that is up
+func (r *KoorOsdReplacementReconciler) osdCreated(
+	ctx context.Context,
+	replacement *storagev1alpha1.KoorOsdReplacement,
+) (bool, error) {
+	status := &replacement.Status
+	tree, err := getOsdTree(ctx, r.toolbox, replacement.Namespace)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range tree.hostOsds(status.Node) {
+		if slices.Contains(status.HostOsds, id) {
+			continue
+		}
+		if tree.osdStatus(id) != osdStatusUp {
+			status.Message = fmt.Sprintf("Waiting for the new OSD %d to be up", id)
+			return false, nil
+		}
+		newOsdID := id
+		status.NewOsdID = &newOsdID
+		return true, nil
+	}
+	return false, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KoorOsdReplacementReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&storagev1alpha1.KoorOsdReplacement{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+)
+
+var _ = Describe("KoorOsdReplacement controller", func() {
+	const (
+		namespace       = "rook-ceph"
+		osdTreeWithOsd2 = `{"nodes":[
+			{"id":-1,"name":"default","type":"root","children":[-3]},
+			{"id":-3,"name":"storage-2","type":"host","children":[1,2]},
+			{"id":1,"name":"osd.1","type":"osd","status":"up"},
+			{"id":2,"name":"osd.2","type":"osd","status":"down"}]}`
+		osdTreeWithoutOsd2 = `{"nodes":[
+			{"id":-1,"name":"default","type":"root","children":[-3]},
+			{"id":-3,"name":"storage-2","type":"host","children":[1]},
+			{"id":1,"name":"osd.1","type":"osd","status":"up"}]}`
+		osdTreeWithNewOsd2 = `{"nodes":[
+			{"id":-1,"name":"default","type":"root","children":[-3]},
+			{"id":-3,"name":"storage-2","type":"host","children":[1,2]},
+			{"id":1,"name":"osd.1","type":"osd","status":"up"},
+			{"id":2,"name":"osd.2","type":"osd","status":"up"}]}`
+		cleanPgs = `{"pg_summary":{"num_pg_by_state":[{"name":"active+clean","num":33}],"num_pgs":33}}`
+	)
+
+	var (
+		ctx         context.Context
+		k8sClient   client.Client
+		mockToolbox *mocks.MockCephToolbox
+		reconciler  *KoorOsdReplacementReconciler
+		request     ctrl.Request
+		cephCluster *unstructured.Unstructured
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockToolbox = mocks.NewMockCephToolbox(gomock.NewController(GinkgoT()))
+
+		cephCluster = &unstructured.Unstructured{Object: map[string]any{
+			"spec":   map[string]any{"cephVersion": map[string]any{"image": "quay.io/ceph/ceph:v17.2.6"}},
+			"status": map[string]any{"ceph": map[string]any{"health": "HEALTH_ERR"}},
+		}}
+		cephCluster.SetGroupVersionKind(cephClusterGVK)
+		cephCluster.SetName("rook-ceph")
+		cephCluster.SetNamespace(namespace)
+
+		replicas := int32(1)
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		scheme.AddKnownTypeWithName(cephClusterGVK, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(cephClusterGVK.GroupVersion().WithKind(cephClusterGVK.Kind+"List"),
+			&unstructured.UnstructuredList{})
+		k8sClient = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithStatusSubresource(&storagev1alpha1.KoorOsdReplacement{}, &batchv1.Job{}).
+			WithObjects(
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "rook-ceph-osd-2", Namespace: namespace},
+					Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: rookOperatorName, Namespace: namespace},
+					Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				},
+				cephCluster,
+			).
+			Build()
+
+		reconciler = &KoorOsdReplacementReconciler{
+			Client:   k8sClient,
+			Scheme:   scheme,
+			Recorder: record.NewFakeRecorder(10),
+			toolbox:  mockToolbox,
+		}
+		request = ctrl.Request{NamespacedName: client.ObjectKey{Name: "osd-2", Namespace: namespace}}
+		Expect(k8sClient.Create(ctx, &storagev1alpha1.KoorOsdReplacement{
+			ObjectMeta: metav1.ObjectMeta{Name: request.Name, Namespace: namespace},
+			Spec:       storagev1alpha1.KoorOsdReplacementSpec{OsdID: 2},
+		})).To(Succeed())
+	})
+
+	reconcile := func() (ctrl.Result, *storagev1alpha1.KoorOsdReplacement) {
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		replacement := &storagev1alpha1.KoorOsdReplacement{}
+		Expect(k8sClient.Get(ctx, request.NamespacedName, replacement)).To(Succeed())
+		return result, replacement
+	}
+
+	setHealth := func(health string) {
+		Expect(unstructured.SetNestedField(cephCluster.Object, health, "status", "ceph", "health")).To(Succeed())
+		Expect(k8sClient.Update(ctx, cephCluster)).To(Succeed())
+	}
+
+	setJobCondition := func(conditionType batchv1.JobConditionType) {
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "koor-zap-osd-2", Namespace: namespace}, job)).To(Succeed())
+		job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+	}
+
+	startReplacement := func() {
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "metadata", "2", "--format", "json").
+				Return(`{"id":2,"hostname":"storage-2","devices":"sdb","device_ids":"sdb=ATA_ST4000NM0035_ZC1234AB"}`, nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "out", "2").Return("", nil),
+		)
+		result, replacement := reconcile()
+		Expect(replacement.Status.Phase).To(Equal(storagev1alpha1.ReplacementPhaseMigrating))
+		Expect(replacement.Status.Node).To(Equal("storage-2"))
+		Expect(replacement.Status.Device).To(Equal("/dev/sdb"))
+		Expect(replacement.Status.DeviceID).To(Equal("ATA_ST4000NM0035_ZC1234AB"))
+		Expect(replacement.Status.Message).To(ContainSubstring("HEALTH_ERR"))
+		Expect(result.RequeueAfter).To(Equal(maintenancePollInterval))
+	}
+
+	It("Should purge the OSD, wipe the disk and wait for the new OSD", func() {
+		By("Marking the OSD out and waiting while ceph is in HEALTH_ERR")
+		startReplacement()
+
+		By("Purging the OSD once it is safe to destroy")
+		setHealth("HEALTH_WARN")
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "pg", "stat", "--format", "json").
+				Return(cleanPgs, nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "safe-to-destroy", "2").Return("", nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "purge", "2",
+				"--yes-i-really-mean-it").Return("", nil),
+		)
+		result, replacement := reconcile()
+		Expect(replacement.Status.Phase).To(Equal(storagev1alpha1.ReplacementPhaseZapping))
+		Expect(result.RequeueAfter).To(Equal(maintenancePollInterval))
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "rook-ceph-osd-2", Namespace: namespace},
+			&appsv1.Deployment{})).NotTo(Succeed())
+
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "koor-zap-osd-2", Namespace: namespace}, job)).To(Succeed())
+		podSpec := job.Spec.Template.Spec
+		Expect(podSpec.NodeSelector).To(Equal(map[string]string{hostnameLabel: "storage-2"}))
+		Expect(podSpec.Containers[0].Image).To(Equal("quay.io/ceph/ceph:v17.2.6"))
+		Expect(podSpec.HostPID).To(BeTrue())
+		Expect(podSpec.Containers[0].Env).To(ConsistOf(
+			corev1.EnvVar{Name: "DEVICE", Value: "/dev/sdb"},
+			corev1.EnvVar{Name: "DEVICE_ID", Value: "ATA_ST4000NM0035_ZC1234AB"},
+			corev1.EnvVar{Name: "OSD_ID", Value: "2"},
+		))
+		Expect(job.OwnerReferences).To(HaveLen(1))
+
+		By("Restarting the rook operator once the disk is wiped")
+		setJobCondition(batchv1.JobComplete)
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "tree", "--format", "json").
+			Return(osdTreeWithoutOsd2, nil).Times(2)
+		result, replacement = reconcile()
+		Expect(replacement.Status.Phase).To(Equal(storagev1alpha1.ReplacementPhaseRecreating))
+		Expect(replacement.Status.HostOsds).To(Equal([]int{1}))
+		Expect(result.RequeueAfter).To(Equal(maintenancePollInterval))
+		operator := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: rookOperatorName, Namespace: namespace}, operator)).To(Succeed())
+		Expect(operator.Spec.Template.Annotations).To(HaveKey(restartedAtAnnotation))
+
+		By("Completing once the new OSD is up")
+		mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "tree", "--format", "json").
+			Return(osdTreeWithNewOsd2, nil)
+		result, replacement = reconcile()
+		Expect(replacement.Status.Phase).To(Equal(storagev1alpha1.ReplacementPhaseCompleted))
+		Expect(*replacement.Status.NewOsdID).To(Equal(2))
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+
+	It("Should not purge the OSD while it is not safe to destroy", func() {
+		startReplacement()
+
+		setHealth("HEALTH_WARN")
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "pg", "stat", "--format", "json").
+				Return(cleanPgs, nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "safe-to-destroy", "2").
+				Return("", errors.New("OSD(s) 2 have 12 pgs currently mapped to them")),
+		)
+		_, replacement := reconcile()
+		Expect(replacement.Status.Phase).To(Equal(storagev1alpha1.ReplacementPhaseMigrating))
+		Expect(replacement.Status.Message).To(ContainSubstring("not safe to destroy"))
+	})
+
+	It("Should not check the serial of an explicitly named disk", func() {
+		replacement := &storagev1alpha1.KoorOsdReplacement{}
+		Expect(k8sClient.Get(ctx, request.NamespacedName, replacement)).To(Succeed())
+		replacement.Spec.Device = "/dev/sdc"
+		Expect(k8sClient.Update(ctx, replacement)).To(Succeed())
+
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "metadata", "2", "--format", "json").
+				Return(`{"id":2,"hostname":"storage-2","devices":"sdb","device_ids":"sdb=ATA_ST4000NM0035_ZC1234AB"}`, nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "out", "2").Return("", nil),
+		)
+		_, replacement = reconcile()
+		Expect(replacement.Status.Device).To(Equal("/dev/sdc"))
+		Expect(replacement.Status.DeviceID).To(BeEmpty())
+	})
+
+	It("Should fail when the disk cannot be wiped", func() {
+		startReplacement()
+
+		setHealth("HEALTH_OK")
+		gomock.InOrder(
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "pg", "stat", "--format", "json").
+				Return(cleanPgs, nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "safe-to-destroy", "2").Return("", nil),
+			mockToolbox.EXPECT().Exec(gomock.Any(), namespace, nil, "ceph", "osd", "purge", "2",
+				"--yes-i-really-mean-it").Return("", nil),
+		)
+		reconcile()
+
+		setJobCondition(batchv1.JobFailed)
+		result, replacement := reconcile()
+		Expect(replacement.Status.Phase).To(Equal(storagev1alpha1.ReplacementPhaseFailed))
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+})
+
+var _ = Describe("parseOsdMetadata", func() {
+	It("Should read the node and the first disk", func() {
+		osd, err := parseOsdMetadata(4, `{"id":4,"hostname":"storage-1","devices":"sdc,sdd",`+
+			`"device_ids":"sdd=ATA_ST4000NM0035_ZC5678CD,sdc=ATA_ST4000NM0035_ZC1234AB"}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(osd).To(Equal(storagev1alpha1.DownOsd{
+			ID:       4,
+			Node:     "storage-1",
+			Device:   "/dev/sdc",
+			DeviceID: "ATA_ST4000NM0035_ZC1234AB",
+		}))
+		Expect(osd.String()).To(Equal("osd.4 on node storage-1 device /dev/sdc"))
+	})
+
+	It("Should leave the disk empty if ceph reports none", func() {
+		osd, err := parseOsdMetadata(4, `{"id":4,"hostname":"storage-1"}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(osd.Device).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Replace OSDs annotation", func() {
+	It("Should create a KoorOsdReplacement for each OSD and remove the annotation", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		koorCluster := &storagev1alpha1.KoorCluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "koor",
+			Namespace:   "rook-ceph",
+			Annotations: map[string]string{storagev1alpha1.ReplaceOsdsAnnotation: "2, x,5"},
+		}}
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(koorCluster).Build()
+		recorder := record.NewFakeRecorder(10)
+		reconciler := &KoorClusterReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+
+		Expect(reconciler.reconcileReplaceOsdsAnnotation(ctx, koorCluster)).To(Succeed())
+
+		replacements := &storagev1alpha1.KoorOsdReplacementList{}
+		Expect(k8sClient.List(ctx, replacements)).To(Succeed())
+		Expect(replacements.Items).To(HaveLen(2))
+		Expect(replacements.Items[0].Name).To(Equal("osd-2"))
+		Expect(replacements.Items[0].Spec.OsdID).To(Equal(2))
+		Expect(replacements.Items[0].OwnerReferences).To(HaveLen(1))
+		Expect(replacements.Items[1].Name).To(Equal("osd-5"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("OsdReplacementCreated")))
+		Expect(recorder.Events).To(Receive(ContainSubstring("InvalidOsdReplacement")))
+
+		updated := &storagev1alpha1.KoorCluster{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(koorCluster), updated)).To(Succeed())
+		Expect(updated.Annotations).NotTo(HaveKey(storagev1alpha1.ReplaceOsdsAnnotation))
+	})
+})
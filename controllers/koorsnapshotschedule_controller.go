@@ -0,0 +1,275 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+// The label of the VolumeSnapshots taken by a schedule. Its value is the name of the schedule.
+const snapshotScheduleLabel = "storage.koor.tech/snapshot-schedule"
+
+// KoorSnapshotScheduleReconciler reconciles a KoorSnapshotSchedule object
+type KoorSnapshotScheduleReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	crons    utils.CronRegistry
+}
+
+func NewKoorSnapshotScheduleReconciler(mgr ctrl.Manager) *KoorSnapshotScheduleReconciler {
+	return &KoorSnapshotScheduleReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("koorsnapshotschedule-controller"),
+		crons:    utils.NewCronRegistry(),
+	}
+}
+
+func snapshotJobName(nn types.NamespacedName) string {
+	return fmt.Sprintf("snapshot/%s", nn.String())
+}
+
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koorsnapshotschedules,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=storage.koor.tech,resources=koorsnapshotschedules/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;delete
+
+// Reconcile registers the schedule in the cron registry. The snapshots are taken by the cron job.
+func (r *KoorSnapshotScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	jobName := snapshotJobName(req.NamespacedName)
+
+	schedule := &storagev1alpha1.KoorSnapshotSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, schedule); err != nil {
+		if k8serrors.IsNotFound(err) {
+			r.removeJob(jobName)
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "unable to fetch KoorSnapshotSchedule")
+		return ctrl.Result{}, err
+	}
+
+	status := &schedule.Status
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	switch {
+	case err != nil:
+		r.removeJob(jobName)
+		status.NextSnapshotTime = nil
+		status.Message = fmt.Sprintf("Invalid schedule %q: %s", schedule.Spec.Schedule, err)
+		r.Recorder.Event(schedule, corev1.EventTypeWarning, "InvalidSchedule", status.Message)
+
+	case schedule.Spec.Suspend:
+		r.removeJob(jobName)
+		status.NextSnapshotTime = nil
+		status.Message = "Suspended"
+
+	default:
+		if registered, ok := r.crons.Get(jobName); !ok || registered != schedule.Spec.Schedule {
+			if ok {
+				r.crons.Remove(jobName)
+			}
+			nn := req.NamespacedName
+			if err := r.crons.Add(jobName, schedule.Spec.Schedule, func() {
+				r.runSchedule(context.Background(), nn)
+			}); err != nil {
+				log.Error(err, "Cannot add the snapshot schedule")
+				return ctrl.Result{}, err
+			}
+			log.Info("Scheduled snapshots", "schedule", schedule.Spec.Schedule)
+		}
+		next := metav1.NewTime(cronSchedule.Next(time.Now()))
+		status.NextSnapshotTime = &next
+	}
+
+	if err := r.Status().Update(ctx, schedule); err != nil {
+		log.Error(err, "Unable to update KoorSnapshotSchedule status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *KoorSnapshotScheduleReconciler) removeJob(jobName string) {
+	if _, ok := r.crons.Get(jobName); ok {
+		r.crons.Remove(jobName)
+	}
+}
+
+// runSchedule is the cron job of a schedule
+func (r *KoorSnapshotScheduleReconciler) runSchedule(ctx context.Context, nn types.NamespacedName) {
+	log := log.FromContext(ctx).WithValues("koorsnapshotschedule", nn)
+
+	schedule := &storagev1alpha1.KoorSnapshotSchedule{}
+	if err := r.Get(ctx, nn, schedule); err != nil {
+		if k8serrors.IsNotFound(err) {
+			log.Info("KoorSnapshotSchedule not found, deleting the job")
+			r.removeJob(snapshotJobName(nn))
+			return
+		}
+		log.Error(err, "unable to fetch KoorSnapshotSchedule inside schedule")
+		return
+	}
+
+	if err := r.takeSnapshots(ctx, schedule, time.Now()); err != nil {
+		log.Error(err, "Cannot take snapshots")
+		schedule.Status.Message = err.Error()
+		r.Recorder.Event(schedule, corev1.EventTypeWarning, "SnapshotsFailed", schedule.Status.Message)
+	}
+	if err := r.Status().Update(ctx, schedule); err != nil {
+		log.Error(err, "Unable to update KoorSnapshotSchedule status in cronjob")
+	}
+}
+
+// takeSnapshots snapshots the selected claims and deletes the snapshots beyond the retention
+func (r *KoorSnapshotScheduleReconciler) takeSnapshots(
+	ctx context.Context,
+	schedule *storagev1alpha1.KoorSnapshotSchedule,
+	now time.Time,
+) error {
+	status := &schedule.Status
+	namespace := schedule.Namespace
+
+	selector, err := metav1.LabelSelectorAsSelector(&schedule.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	claims := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, claims, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+
+	var taken int
+	var failures []string
+	for _, claim := range claims.Items {
+		if claim.Status.Phase != corev1.ClaimBound {
+			continue
+		}
+		snapshot := newVolumeSnapshot(schedule, claim.Name, now)
+		if err := r.Create(ctx, snapshot); err != nil {
+			if meta.IsNoMatchError(err) {
+				return fmt.Errorf("the VolumeSnapshot CRDs are not installed")
+			}
+			log.FromContext(ctx).Error(err, "Cannot create VolumeSnapshot", "claim", claim.Name)
+			failures = append(failures, claim.Name)
+			continue
+		}
+		taken++
+	}
+
+	deleted, remaining, err := r.pruneSnapshots(ctx, schedule)
+	if err != nil {
+		return err
+	}
+
+	lastSnapshotTime := metav1.NewTime(now)
+	status.LastSnapshotTime = &lastSnapshotTime
+	if cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule); err == nil {
+		next := metav1.NewTime(cronSchedule.Next(now))
+		status.NextSnapshotTime = &next
+	}
+	status.Snapshots = int32(remaining)
+	status.Message = fmt.Sprintf("Took %d snapshots and deleted %d", taken, deleted)
+	if len(failures) > 0 {
+		return fmt.Errorf("%s, the snapshots of %v failed", status.Message, failures)
+	}
+	r.Recorder.Event(schedule, corev1.EventTypeNormal, "SnapshotsTaken", status.Message)
+	return nil
+}
+
+func newVolumeSnapshot(schedule *storagev1alpha1.KoorSnapshotSchedule, claimName string, now time.Time) *unstructured.Unstructured {
+	spec := map[string]any{
+		"source": map[string]any{"persistentVolumeClaimName": claimName},
+	}
+	if schedule.Spec.VolumeSnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = schedule.Spec.VolumeSnapshotClassName
+	}
+	snapshot := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	// The timestamp sorts the snapshots of a claim by age
+	snapshot.SetName(fmt.Sprintf("%s-%s-%s", claimName, schedule.Name, now.UTC().Format("20060102-150405")))
+	snapshot.SetNamespace(schedule.Namespace)
+	snapshot.SetLabels(map[string]string{snapshotScheduleLabel: schedule.Name})
+	return snapshot
+}
+
+// pruneSnapshots deletes the oldest snapshots of each claim beyond the retention.
+// It returns the number of deleted and remaining snapshots.
+func (r *KoorSnapshotScheduleReconciler) pruneSnapshots(
+	ctx context.Context,
+	schedule *storagev1alpha1.KoorSnapshotSchedule,
+) (int, int, error) {
+	snapshots := &unstructured.UnstructuredList{}
+	snapshots.SetGroupVersionKind(volumeSnapshotGVK.GroupVersion().WithKind(volumeSnapshotGVK.Kind + "List"))
+	if err := r.List(ctx, snapshots, client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{snapshotScheduleLabel: schedule.Name}); err != nil {
+		return 0, 0, err
+	}
+
+	byClaim := map[string][]string{}
+	for _, snapshot := range snapshots.Items {
+		claimName, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+		byClaim[claimName] = append(byClaim[claimName], snapshot.GetName())
+	}
+
+	retention := int(schedule.Spec.Retention)
+	if retention < 1 {
+		retention = 1
+	}
+	deleted, remaining := 0, 0
+	for _, names := range byClaim {
+		slices.Sort(names)
+		for len(names) > retention {
+			snapshot := &unstructured.Unstructured{}
+			snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+			snapshot.SetName(names[0])
+			snapshot.SetNamespace(schedule.Namespace)
+			if err := r.Delete(ctx, snapshot); client.IgnoreNotFound(err) != nil {
+				return deleted, remaining, err
+			}
+			names = names[1:]
+			deleted++
+		}
+		remaining += len(names)
+	}
+	return deleted, remaining, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KoorSnapshotScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&storagev1alpha1.KoorSnapshotSchedule{}).
+		Complete(r)
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+)
+
+// addSnapshotKinds registers the external snapshot CRDs as unstructured kinds
+func addSnapshotKinds(scheme *runtime.Scheme) {
+	for _, gvk := range []schema.GroupVersionKind{volumeSnapshotGVK, volumeSnapshotClassGVK} {
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	}
+}
+
+var _ = Describe("KoorSnapshotSchedule controller", func() {
+	const namespace = "apps"
+
+	var (
+		ctx        context.Context
+		k8sClient  client.Client
+		recorder   *record.FakeRecorder
+		mockCrons  *mocks.MockCronRegistry
+		reconciler *KoorSnapshotScheduleReconciler
+		nn         types.NamespacedName
+		jobName    string
+	)
+
+	newClaim := func(name string, phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"backup": "daily"}},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+		}
+	}
+
+	listSnapshots := func() []string {
+		snapshots := &unstructured.UnstructuredList{}
+		snapshots.SetGroupVersionKind(volumeSnapshotGVK.GroupVersion().WithKind("VolumeSnapshotList"))
+		Expect(k8sClient.List(ctx, snapshots, client.InNamespace(namespace))).To(Succeed())
+		var names []string
+		for _, snapshot := range snapshots.Items {
+			names = append(names, snapshot.GetName())
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		addSnapshotKinds(scheme)
+
+		schedule := &storagev1alpha1.KoorSnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "daily", Namespace: namespace},
+			Spec: storagev1alpha1.KoorSnapshotScheduleSpec{
+				Schedule:                "0 1 * * *",
+				Selector:                metav1.LabelSelector{MatchLabels: map[string]string{"backup": "daily"}},
+				VolumeSnapshotClassName: "ceph-block",
+				Retention:               2,
+			},
+		}
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(
+				schedule,
+				newClaim("data", corev1.ClaimBound),
+				newClaim("pending", corev1.ClaimPending),
+				&corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: namespace},
+					Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+				},
+			).
+			WithStatusSubresource(schedule).
+			Build()
+		recorder = record.NewFakeRecorder(10)
+		mockCrons = mocks.NewMockCronRegistry(gomock.NewController(GinkgoT()))
+		reconciler = &KoorSnapshotScheduleReconciler{
+			Client:   k8sClient,
+			Scheme:   scheme,
+			Recorder: recorder,
+			crons:    mockCrons,
+		}
+		nn = types.NamespacedName{Name: "daily", Namespace: namespace}
+		jobName = "snapshot/apps/daily"
+	})
+
+	It("Should schedule snapshots and keep the configured number per claim", func() {
+		var job func()
+		mockCrons.EXPECT().Get(jobName).Return("", false)
+		mockCrons.EXPECT().Add(jobName, "0 1 * * *", gomock.Any()).
+			DoAndReturn(func(_ string, _ string, cmd func()) error {
+				job = cmd
+				return nil
+			})
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: nn})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(job).NotTo(BeNil())
+
+		schedule := &storagev1alpha1.KoorSnapshotSchedule{}
+		Expect(k8sClient.Get(ctx, nn, schedule)).To(Succeed())
+		Expect(schedule.Status.NextSnapshotTime).NotTo(BeNil())
+
+		start := time.Date(2023, 10, 1, 1, 0, 0, 0, time.UTC)
+		for day := 0; day < 3; day++ {
+			Expect(k8sClient.Get(ctx, nn, schedule)).To(Succeed())
+			Expect(reconciler.takeSnapshots(ctx, schedule, start.AddDate(0, 0, day))).To(Succeed())
+		}
+		Expect(listSnapshots()).To(ConsistOf("data-daily-20231002-010000", "data-daily-20231003-010000"))
+		Expect(schedule.Status.Snapshots).To(BeEquivalentTo(2))
+		Expect(schedule.Status.Message).To(Equal("Took 1 snapshots and deleted 1"))
+		Expect(schedule.Status.LastSnapshotTime.Time).To(BeTemporally("==", start.AddDate(0, 0, 2)))
+		Expect(schedule.Status.NextSnapshotTime.Time).To(BeTemporally("==", start.AddDate(0, 0, 3)))
+
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "data-daily-20231003-010000", Namespace: namespace}, snapshot)).To(Succeed())
+		Expect(snapshot.GetLabels()).To(HaveKeyWithValue(snapshotScheduleLabel, "daily"))
+		Expect(snapshot.Object["spec"]).To(Equal(map[string]any{
+			"source":                  map[string]any{"persistentVolumeClaimName": "data"},
+			"volumeSnapshotClassName": "ceph-block",
+		}))
+	})
+
+	It("Should remove the job when the schedule is suspended or deleted", func() {
+		schedule := &storagev1alpha1.KoorSnapshotSchedule{}
+		Expect(k8sClient.Get(ctx, nn, schedule)).To(Succeed())
+		schedule.Spec.Suspend = true
+		Expect(k8sClient.Update(ctx, schedule)).To(Succeed())
+
+		gomock.InOrder(
+			mockCrons.EXPECT().Get(jobName).Return("0 1 * * *", true),
+			mockCrons.EXPECT().Remove(jobName).Return(nil),
+		)
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: nn})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, nn, schedule)).To(Succeed())
+		Expect(schedule.Status.Message).To(Equal("Suspended"))
+		Expect(schedule.Status.NextSnapshotTime).To(BeNil())
+
+		Expect(k8sClient.Delete(ctx, schedule)).To(Succeed())
+		gomock.InOrder(
+			mockCrons.EXPECT().Get(jobName).Return("0 1 * * *", true),
+			mockCrons.EXPECT().Remove(jobName).Return(nil),
+		)
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: nn})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should report when the snapshot CRDs are not installed", func() {
+		reconciler.Client = interceptor.NewClient(k8sClient.(client.WithWatch), interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				return &meta.NoKindMatchError{GroupKind: volumeSnapshotGVK.GroupKind()}
+			},
+		})
+
+		schedule := &storagev1alpha1.KoorSnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "daily", Namespace: namespace},
+			Spec:       storagev1alpha1.KoorSnapshotScheduleSpec{Schedule: "0 1 * * *", Retention: 1},
+		}
+		err := reconciler.takeSnapshots(ctx, schedule, time.Now())
+		Expect(err).To(MatchError(ContainSubstring("VolumeSnapshot CRDs are not installed")))
+	})
+})
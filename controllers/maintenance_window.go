@@ -0,0 +1,96 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// maintenanceWindowState returns whether one of the windows is open at the given time.
+// If a window is open, the returned time is when the last open window closes,
+// otherwise it is when the next window opens.
+func maintenanceWindowState(windows []storagev1alpha1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	open := false
+	var closes, opens time.Time
+	for _, window := range windows {
+		schedule, err := window.Schedule()
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("maintenance window %q is invalid: %w", window.Start, err)
+		}
+
+		// The first start after now-duration is either the start of an open window or the next start
+		start := schedule.Next(now.Add(-window.Duration.Duration))
+		if !start.After(now) {
+			open = true
+			if end := start.Add(window.Duration.Duration); end.After(closes) {
+				closes = end
+			}
+			continue
+		}
+		if opens.IsZero() || start.Before(opens) {
+			opens = start
+		}
+	}
+
+	if open {
+		return true, closes, nil
+	}
+	return false, opens, nil
+}
+
+// checkMaintenanceWindow reports whether the versions may be changed now and records
+// the state of the maintenance windows in the status.
+func checkMaintenanceWindow(koorCluster *storagev1alpha1.KoorCluster, now time.Time) (bool, error) {
+	status := &koorCluster.Status
+	windows := koorCluster.Spec.UpgradeOptions.MaintenanceWindows
+	if len(windows) == 0 {
+		status.NextMaintenanceWindow = nil
+		meta.RemoveStatusCondition(&status.Conditions, storagev1alpha1.ConditionInMaintenanceWindow)
+		return true, nil
+	}
+
+	open, at, err := maintenanceWindowState(windows, now)
+	if err != nil {
+		return false, err
+	}
+
+	if open {
+		status.NextMaintenanceWindow = nil
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionInMaintenanceWindow,
+			Status:  metav1.ConditionTrue,
+			Reason:  "WindowOpen",
+			Message: fmt.Sprintf("The maintenance window is open until %s", at.UTC().Format(time.RFC3339)),
+		})
+		return true, nil
+	}
+
+	status.NextMaintenanceWindow = &metav1.Time{Time: at}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionInMaintenanceWindow,
+		Status:  metav1.ConditionFalse,
+		Reason:  "WindowClosed",
+		Message: fmt.Sprintf("The next maintenance window opens at %s", at.UTC().Format(time.RFC3339)),
+	})
+	return false, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	hc "github.com/mittwald/go-helm-client"
+	hcmock "github.com/mittwald/go-helm-client/mock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+var _ = Describe("Maintenance windows", func() {
+	// Saturdays from 22:00 to 02:00 in Berlin, which is UTC+2 in June
+	saturdayNight := storagev1alpha1.MaintenanceWindow{
+		Start:    "0 22 * * 6",
+		Duration: metav1.Duration{Duration: 4 * time.Hour},
+		TimeZone: "Europe/Berlin",
+	}
+	// Every day from 12:00 to 13:00 UTC
+	noon := storagev1alpha1.MaintenanceWindow{
+		Start:    "0 12 * * *",
+		Duration: metav1.Duration{Duration: time.Hour},
+	}
+	at := func(value string) time.Time {
+		t, err := time.Parse(time.RFC3339, value)
+		Expect(err).NotTo(HaveOccurred())
+		return t
+	}
+
+	DescribeTable("Should find open windows and the next window",
+		func(windows []storagev1alpha1.MaintenanceWindow, now string, expectedOpen bool, expectedAt string) {
+			open, when, err := maintenanceWindowState(windows, at(now))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(open).To(Equal(expectedOpen))
+			Expect(when.Equal(at(expectedAt))).To(BeTrue(), "got %s", when)
+		},
+		Entry("before the window", []storagev1alpha1.MaintenanceWindow{saturdayNight},
+			"2023-06-17T19:00:00Z", false, "2023-06-17T20:00:00Z"),
+		Entry("at the start of the window", []storagev1alpha1.MaintenanceWindow{saturdayNight},
+			"2023-06-17T20:00:00Z", true, "2023-06-18T00:00:00Z"),
+		Entry("after midnight in the window", []storagev1alpha1.MaintenanceWindow{saturdayNight},
+			"2023-06-17T23:30:00Z", true, "2023-06-18T00:00:00Z"),
+		Entry("when the window closed", []storagev1alpha1.MaintenanceWindow{saturdayNight},
+			"2023-06-18T00:00:00Z", false, "2023-06-24T20:00:00Z"),
+		Entry("the earliest of several windows", []storagev1alpha1.MaintenanceWindow{saturdayNight, noon},
+			"2023-06-18T00:30:00Z", false, "2023-06-18T12:00:00Z"),
+		Entry("in one of several windows", []storagev1alpha1.MaintenanceWindow{saturdayNight, noon},
+			"2023-06-18T12:30:00Z", true, "2023-06-18T13:00:00Z"),
+	)
+
+	It("Should report an invalid window", func() {
+		_, _, err := maintenanceWindowState([]storagev1alpha1.MaintenanceWindow{{Start: "every night"}}, time.Now())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should keep the deployed chart version outside of the windows", func() {
+		mockCtrl := gomock.NewController(GinkgoT())
+		mockHelmClient := hcmock.NewMockClient(mockCtrl)
+		reconciler := &KoorClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+		// A window that opened an hour ago and closed right after
+		closed := time.Now().UTC().Add(-time.Hour)
+		koorCluster := &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "maintenance", Namespace: "default"},
+			Spec: storagev1alpha1.KoorClusterSpec{
+				UpgradeOptions: storagev1alpha1.UpgradeOptions{
+					MaintenanceWindows: []storagev1alpha1.MaintenanceWindow{{
+						Start:    closed.Format("4 15 * * *"),
+						Duration: metav1.Duration{Duration: time.Minute},
+					}},
+				},
+			},
+		}
+
+		gomock.InOrder(
+			mockHelmClient.EXPECT().GetRelease("ksd").Return(&release.Release{
+				Chart: &chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.0"}},
+			}, nil),
+			mockHelmClient.EXPECT().GetChart("koor-release/rook-ceph", gomock.Any()).Return(
+				&chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.1"}}, "", nil),
+			expectRelease(mockHelmClient, "ksd-cluster", "v1.11.0"),
+			expectInstall(mockHelmClient, "ksd", "v1.11.0"),
+			mockHelmClient.EXPECT().GetRelease("ksd-cluster").Return(&release.Release{
+				Chart: &chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.0"}},
+			}, nil),
+			expectInstall(mockHelmClient, "ksd-cluster", "v1.11.0"),
+		)
+
+		operatorChartSpec := &hc.ChartSpec{ReleaseName: "ksd", ChartName: "koor-release/rook-ceph"}
+		clusterChartSpec := &hc.ChartSpec{ReleaseName: "ksd-cluster", ChartName: "koor-release/rook-ceph-cluster"}
+		Expect(reconciler.reconcileUpgrade(context.Background(), koorCluster, mockHelmClient,
+			operatorChartSpec, clusterChartSpec)).To(Succeed())
+		Expect(koorCluster.Status.Upgrade).To(BeNil())
+
+		Expect(meta.IsStatusConditionFalse(koorCluster.Status.Conditions, storagev1alpha1.ConditionInMaintenanceWindow)).To(BeTrue())
+		Expect(koorCluster.Status.NextMaintenanceWindow).NotTo(BeNil())
+		Expect(koorCluster.Status.NextMaintenanceWindow.Time).To(BeTemporally("~", closed.Add(24*time.Hour), time.Minute))
+	})
+})
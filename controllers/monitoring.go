@@ -0,0 +1,193 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/values"
+)
+
+const (
+	prometheusRuleName = "koor-ceph-alerts"
+	// The label of the dashboard ConfigMaps with the KoorCluster that owns them
+	dashboardOwnerLabel = "storage.koor.tech/cluster"
+)
+
+// The PrometheusRules of the Prometheus Operator, which is optional
+var prometheusRuleGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "PrometheusRule",
+}
+
+// The labels that the Grafana sidecar watches by default
+var defaultDashboardLabels = map[string]string{"grafana_dashboard": "1"}
+
+// promDuration formats the duration like Prometheus or returns the fallback when it is not set
+func promDuration(d metav1.Duration, fallback string) string {
+	if d.Duration <= 0 {
+		return fallback
+	}
+	return model.Duration(d.Duration).String()
+}
+
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+
+// reconcileMonitoring creates the PrometheusRule with the ceph alerts and the ConfigMaps
+// with the Grafana dashboards of the cluster
+func (r *KoorClusterReconciler) reconcileMonitoring(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	monitoring := isEnabled(koorCluster.Spec.MonitoringEnabled)
+	if err := r.reconcileGrafanaDashboards(ctx, koorCluster, monitoring && isEnabled(koorCluster.Spec.Monitoring.Dashboards)); err != nil {
+		return err
+	}
+	return r.reconcilePrometheusRule(ctx, koorCluster, monitoring && isEnabled(koorCluster.Spec.Monitoring.Alerts))
+}
+
+func (r *KoorClusterReconciler) reconcilePrometheusRule(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster, enabled bool) error {
+	status := &koorCluster.Status
+	rule := &unstructured.Unstructured{}
+	rule.SetGroupVersionKind(prometheusRuleGVK)
+	rule.SetName(prometheusRuleName)
+	rule.SetNamespace(koorCluster.Namespace)
+
+	if !enabled {
+		meta.RemoveStatusCondition(&status.Conditions, storagev1alpha1.ConditionMonitoringReady)
+		if err := r.deleteOwned(ctx, koorCluster, rule); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+		return nil
+	}
+
+	spec, err := renderPrometheusRuleSpec(koorCluster)
+	if err != nil {
+		return err
+	}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, rule, func() error {
+		rule.SetLabels(koorCluster.Spec.Monitoring.RuleLabels)
+		rule.Object["spec"] = spec
+		return controllerutil.SetControllerReference(koorCluster, rule, r.Scheme)
+	})
+	if meta.IsNoMatchError(err) {
+		if !meta.IsStatusConditionFalse(status.Conditions, storagev1alpha1.ConditionMonitoringReady) {
+			r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "PrometheusOperatorMissing",
+				"Cannot create the ceph alerts, the Prometheus Operator CRDs are not installed")
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionMonitoringReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  "PrometheusOperatorMissing",
+			Message: "The PrometheusRule CRD is not installed, install the Prometheus Operator for the ceph alerts",
+		})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionMonitoringReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  "AlertsCreated",
+		Message: fmt.Sprintf("The ceph alerts are in the PrometheusRule %s", prometheusRuleName),
+	})
+	return nil
+}
+
+// renderPrometheusRuleSpec renders the spec of the PrometheusRule with the thresholds of the KoorCluster
+func renderPrometheusRuleSpec(koorCluster *storagev1alpha1.KoorCluster) (map[string]any, error) {
+	templates, err := parseValueTemplates()
+	if err != nil {
+		return nil, err
+	}
+	buffer := new(bytes.Buffer)
+	if err := templates.ExecuteTemplate(buffer, "prometheusRule.yaml", koorCluster); err != nil {
+		return nil, err
+	}
+	spec := map[string]any{}
+	if err := yaml.Unmarshal(buffer.Bytes(), &spec); err != nil {
+		return nil, fmt.Errorf("cannot parse the PrometheusRule: %w", err)
+	}
+	return spec, nil
+}
+
+func (r *KoorClusterReconciler) reconcileGrafanaDashboards(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster, enabled bool) error {
+	templates, err := template.New("").Delims("[[", "]]").Funcs(sprig.TxtFuncMap()).ParseFS(&values.Dashboards, "dashboards/*.json")
+	if err != nil {
+		return err
+	}
+
+	labels := koorCluster.Spec.Monitoring.DashboardLabels
+	if len(labels) == 0 {
+		labels = defaultDashboardLabels
+	}
+	for _, dashboard := range templates.Templates() {
+		fileName := dashboard.Name()
+		if path.Ext(fileName) != ".json" {
+			continue
+		}
+		configMap := &corev1.ConfigMap{}
+		configMap.Name = grafanaDashboardName(fileName)
+		configMap.Namespace = koorCluster.Namespace
+
+		if !enabled {
+			if err := r.deleteOwned(ctx, koorCluster, configMap); err != nil {
+				return err
+			}
+			continue
+		}
+
+		buffer := new(bytes.Buffer)
+		if err := dashboard.Execute(buffer, koorCluster); err != nil {
+			return err
+		}
+		_, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+			configMap.Labels = map[string]string{dashboardOwnerLabel: koorCluster.Name}
+			for key, value := range labels {
+				configMap.Labels[key] = value
+			}
+			configMap.Data = map[string]string{fileName: buffer.String()}
+			return controllerutil.SetControllerReference(koorCluster, configMap, r.Scheme)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grafanaDashboardName returns the name of the ConfigMap with the dashboard of the file
+func grafanaDashboardName(fileName string) string {
+	return "koor-" + strings.TrimSuffix(fileName, ".json") + "-dashboard"
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// ruleExprs returns the expressions of the alerts in the PrometheusRule
+func ruleExprs(rule *unstructured.Unstructured) map[string]string {
+	exprs := map[string]string{}
+	groups, _, _ := unstructured.NestedSlice(rule.Object, "spec", "groups")
+	for _, group := range groups {
+		for _, r := range group.(map[string]any)["rules"].([]any) {
+			alert := r.(map[string]any)
+			exprs[alert["alert"].(string)] = alert["expr"].(string)
+		}
+	}
+	return exprs
+}
+
+var _ = Describe("Monitoring", func() {
+	const namespace = "rook-ceph"
+
+	var (
+		ctx         context.Context
+		k8sClient   client.Client
+		recorder    *record.FakeRecorder
+		reconciler  *KoorClusterReconciler
+		koorCluster *storagev1alpha1.KoorCluster
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		scheme.AddKnownTypeWithName(prometheusRuleGVK, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(prometheusRuleGVK.GroupVersion().WithKind("PrometheusRuleList"), &unstructured.UnstructuredList{})
+
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "koor", Namespace: namespace, UID: "koor-uid"},
+		}
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(koorCluster).Build()
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(koorCluster), koorCluster)).To(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		reconciler = &KoorClusterReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+	})
+
+	getRule := func() (*unstructured.Unstructured, error) {
+		rule := &unstructured.Unstructured{}
+		rule.SetGroupVersionKind(prometheusRuleGVK)
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: prometheusRuleName, Namespace: namespace}, rule)
+		return rule, err
+	}
+
+	It("Should create the alerts with the thresholds and labels of the cluster", func() {
+		koorCluster.Spec.CapacityOptions.NearFullPercent = 70
+		koorCluster.Spec.Monitoring = storagev1alpha1.MonitoringSpec{
+			RuleLabels:  map[string]string{"release": "prometheus"},
+			AlertLabels: map[string]string{"team": "storage"},
+			AlertThresholds: storagev1alpha1.AlertThresholds{
+				HealthErrorFor: metav1.Duration{Duration: 10 * time.Minute},
+				FullPercent:    90,
+			},
+		}
+		Expect(reconciler.reconcileMonitoring(ctx, koorCluster)).To(Succeed())
+
+		rule, err := getRule()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rule.GetLabels()).To(Equal(map[string]string{"release": "prometheus"}))
+		Expect(metav1.IsControlledBy(rule, koorCluster)).To(BeTrue())
+
+		exprs := ruleExprs(rule)
+		Expect(exprs).To(HaveLen(8))
+		Expect(exprs).To(HaveKeyWithValue("CephHealthError", `max(ceph_health_status{namespace="rook-ceph"}) == 2`))
+		Expect(exprs).To(HaveKeyWithValue("CephClusterNearFull", HaveSuffix("> 70")))
+		Expect(exprs).To(HaveKeyWithValue("CephClusterFull", HaveSuffix("> 90")))
+		Expect(exprs).To(HaveKeyWithValue("CephPoolQuotaNearFull", HaveSuffix("> 85")))
+
+		groups, _, _ := unstructured.NestedSlice(rule.Object, "spec", "groups")
+		healthError := groups[0].(map[string]any)["rules"].([]any)[0].(map[string]any)
+		Expect(healthError).To(HaveKeyWithValue("for", "10m"))
+		Expect(healthError).To(HaveKeyWithValue("labels", map[string]any{"severity": "critical", "team": "storage"}))
+		Expect(healthError["annotations"]).To(HaveKeyWithValue("description", ContainSubstring("namespace rook-ceph")))
+
+		warning := groups[0].(map[string]any)["rules"].([]any)[1].(map[string]any)
+		Expect(warning).To(HaveKeyWithValue("for", "15m"))
+
+		cond := meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionMonitoringReady)
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("Should create the Grafana dashboards with the sidecar labels", func() {
+		Expect(reconciler.reconcileMonitoring(ctx, koorCluster)).To(Succeed())
+
+		for _, name := range []string{"ceph-cluster", "ceph-pools"} {
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "koor-" + name + "-dashboard", Namespace: namespace}, configMap)).To(Succeed())
+			Expect(configMap.Labels).To(HaveKeyWithValue("grafana_dashboard", "1"))
+			Expect(configMap.Labels).To(HaveKeyWithValue(dashboardOwnerLabel, "koor"))
+			Expect(configMap.Data).To(HaveKey(name + ".json"))
+
+			dashboard := map[string]any{}
+			Expect(json.Unmarshal([]byte(configMap.Data[name+".json"]), &dashboard)).To(Succeed())
+			Expect(dashboard["uid"]).To(Equal("koor-" + name + "-rook-ceph"))
+		}
+
+		koorCluster.Spec.Monitoring.DashboardLabels = map[string]string{"grafana": "ceph"}
+		Expect(reconciler.reconcileMonitoring(ctx, koorCluster)).To(Succeed())
+		configMap := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "koor-ceph-cluster-dashboard", Namespace: namespace}, configMap)).To(Succeed())
+		Expect(configMap.Labels).To(Equal(map[string]string{"grafana": "ceph", dashboardOwnerLabel: "koor"}))
+	})
+
+	It("Should delete the alerts and dashboards when they are disabled", func() {
+		Expect(reconciler.reconcileMonitoring(ctx, koorCluster)).To(Succeed())
+
+		koorCluster.Spec.Monitoring.Alerts = pointer.Bool(false)
+		koorCluster.Spec.Monitoring.Dashboards = pointer.Bool(false)
+		Expect(reconciler.reconcileMonitoring(ctx, koorCluster)).To(Succeed())
+
+		_, err := getRule()
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: "koor-ceph-cluster-dashboard", Namespace: namespace}, &corev1.ConfigMap{})
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		Expect(meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionMonitoringReady)).To(BeNil())
+	})
+
+	It("Should report when the Prometheus Operator is not installed", func() {
+		reconciler.Client = interceptor.NewClient(k8sClient.(client.WithWatch), interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if obj.GetObjectKind().GroupVersionKind() == prometheusRuleGVK {
+					return &meta.NoKindMatchError{GroupKind: prometheusRuleGVK.GroupKind()}
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+		})
+
+		Expect(reconciler.reconcileMonitoring(ctx, koorCluster)).To(Succeed())
+		cond := meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionMonitoringReady)
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal("PrometheusOperatorMissing"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("PrometheusOperatorMissing")))
+
+		// The warning is raised once
+		Expect(reconciler.reconcileMonitoring(ctx, koorCluster)).To(Succeed())
+		Expect(recorder.Events).NotTo(Receive())
+	})
+})
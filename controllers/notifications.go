@@ -0,0 +1,304 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+const (
+	// A failed notification is dropped after this many attempts
+	maxNotificationAttempts = 5
+	// The delay before the first retry, which doubles with every attempt
+	notificationRetryInterval    = 30 * time.Second
+	maxNotificationRetryInterval = 30 * time.Minute
+)
+
+// pendingNotification is the notification of an event that applies to the KoorCluster
+type pendingNotification struct {
+	// Identifies the notification, so that it is delivered once
+	key          string
+	notification utils.Notification
+}
+
+// activeNotifications returns the notifications of the events that currently apply to the KoorCluster
+func activeNotifications(koorCluster *storagev1alpha1.KoorCluster, now time.Time) map[storagev1alpha1.NotificationEvent]*pendingNotification {
+	status := &koorCluster.Status
+	active := map[storagev1alpha1.NotificationEvent]*pendingNotification{}
+	add := func(event storagev1alpha1.NotificationEvent, key string, severity string, summary string) {
+		active[event] = &pendingNotification{
+			key: key,
+			notification: utils.Notification{
+				Cluster:   koorCluster.Name,
+				Namespace: koorCluster.Namespace,
+				Event:     string(event),
+				Severity:  severity,
+				Summary:   summary,
+				Time:      now,
+			},
+		}
+	}
+
+	if plan := upgradePlan(status.CurrentVersions, status.LatestVersions); plan != nil {
+		target := formatUpgradeTarget(plan)
+		switch severity, update := securityUpdate(status); severity {
+		case storagev1alpha1.VersionSeverityCritical:
+			add(storagev1alpha1.NotificationEventUpgradeAvailable, target, "critical",
+				fmt.Sprintf("A critical security upgrade to %s is available: %s", target, update))
+		case storagev1alpha1.VersionSeveritySecurity:
+			add(storagev1alpha1.NotificationEventUpgradeAvailable, target, "warning",
+				fmt.Sprintf("A security upgrade to %s is available: %s", target, update))
+		default:
+			add(storagev1alpha1.NotificationEventUpgradeAvailable, target, "info",
+				fmt.Sprintf("An upgrade to %s is available", target))
+		}
+	}
+
+	if upgrade := status.Upgrade; upgrade != nil && !upgrade.InProgress() {
+		key := upgrade.StartedAt.UTC().Format(time.RFC3339)
+		summary := upgrade.Message
+		if summary == "" {
+			summary = fmt.Sprintf("The upgrade to chart version %s finished with the result %s", upgrade.ChartVersion, upgrade.Result)
+		}
+		switch upgrade.Result {
+		case storagev1alpha1.UpgradeResultSucceeded:
+			add(storagev1alpha1.NotificationEventUpgradeFinished, key, "info", summary)
+		case storagev1alpha1.UpgradeResultFailed, storagev1alpha1.UpgradeResultRolledBack:
+			add(storagev1alpha1.NotificationEventUpgradeFailed, key, "critical", summary)
+		}
+	}
+
+	if cephCluster := status.CephCluster; cephCluster != nil &&
+		(cephCluster.Health == storagev1alpha1.CephHealthWarn || cephCluster.Health == storagev1alpha1.CephHealthErr) {
+		severity := "warning"
+		if cephCluster.Health == storagev1alpha1.CephHealthErr {
+			severity = "critical"
+		}
+		// A notification is sent again when the failing checks change
+		var names, messages []string
+		for _, check := range cephCluster.HealthChecks {
+			names = append(names, check.Name)
+			messages = append(messages, fmt.Sprintf("%s: %s", check.Name, check.Message))
+		}
+		slices.Sort(names)
+		summary := fmt.Sprintf("Ceph reports %s", cephCluster.Health)
+		if len(messages) > 0 {
+			summary += "\n" + strings.Join(messages, "\n")
+		}
+		add(storagev1alpha1.NotificationEventHealthDegraded,
+			strings.Join(append([]string{string(cephCluster.Health)}, names...), ","), severity, summary)
+	}
+
+	if resources := status.TotalResources; !status.MeetsMinimumResources && resources.Nodes != nil {
+		key := fmt.Sprintf("%s nodes, %s CPUs, %s memory, %s storage",
+			resources.Nodes, resources.Cpu, resources.Memory, resources.Storage)
+		add(storagev1alpha1.NotificationEventResourcesInsufficient, key, "warning",
+			fmt.Sprintf("The cluster has %s, which is below the minimum recommended resources", key))
+	}
+	return active
+}
+
+// reconcileNotificationSinks delivers the notifications of the current events to the sinks of the spec
+func (r *KoorClusterReconciler) reconcileNotificationSinks(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	status := &koorCluster.Status
+	if len(koorCluster.Spec.Notifications) == 0 {
+		status.Notifications = nil
+		return nil
+	}
+
+	now := time.Now()
+	active := activeNotifications(koorCluster, now)
+	previous := map[string]*storagev1alpha1.NotificationSinkStatus{}
+	for i := range status.Notifications {
+		previous[status.Notifications[i].Name] = &status.Notifications[i]
+	}
+
+	statuses := make([]storagev1alpha1.NotificationSinkStatus, 0, len(koorCluster.Spec.Notifications))
+	for i := range koorCluster.Spec.Notifications {
+		sink := &koorCluster.Spec.Notifications[i]
+		sinkStatus := storagev1alpha1.NotificationSinkStatus{Name: sink.Name}
+		if prev, ok := previous[sink.Name]; ok {
+			prev.DeepCopyInto(&sinkStatus)
+		}
+		if sinkStatus.Delivered == nil {
+			sinkStatus.Delivered = map[storagev1alpha1.NotificationEvent]string{}
+		}
+		// Forget the events that no longer apply, so that they are delivered again when they recur
+		for event := range sinkStatus.Delivered {
+			if _, ok := active[event]; !ok || !sink.Accepts(event) {
+				delete(sinkStatus.Delivered, event)
+			}
+		}
+
+		r.deliverNotifications(ctx, koorCluster, sink, &sinkStatus, active, now)
+		if len(sinkStatus.Delivered) == 0 {
+			sinkStatus.Delivered = nil
+		}
+		statuses = append(statuses, sinkStatus)
+	}
+	status.Notifications = statuses
+	return nil
+}
+
+// deliverNotifications sends the notifications that the sink did not receive yet. It stops at the
+// first failure, and the notification is retried with a backoff in a later reconcile.
+func (r *KoorClusterReconciler) deliverNotifications(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	sink *storagev1alpha1.NotificationSink,
+	sinkStatus *storagev1alpha1.NotificationSinkStatus,
+	active map[storagev1alpha1.NotificationEvent]*pendingNotification,
+	now time.Time,
+) {
+	log := log.FromContext(ctx)
+	for _, event := range storagev1alpha1.NotificationEvents {
+		pending, ok := active[event]
+		if !ok || !sink.Accepts(event) || sinkStatus.Delivered[event] == pending.key {
+			continue
+		}
+
+		attempts := int32(1)
+		if last := sinkStatus.LastDelivery; last != nil && !last.Succeeded {
+			if now.Before(nextNotificationAttempt(last)) {
+				return
+			}
+			if last.Event == event && last.Key == pending.key {
+				attempts = last.Attempts + 1
+			}
+		}
+
+		err := r.sendNotification(ctx, koorCluster.Namespace, sink, &pending.notification)
+		delivery := &storagev1alpha1.NotificationDelivery{
+			Event:     event,
+			Key:       pending.key,
+			Time:      metav1.NewTime(now),
+			Attempts:  attempts,
+			Succeeded: err == nil,
+		}
+		sinkStatus.LastDelivery = delivery
+		if err == nil {
+			sinkStatus.Delivered[event] = pending.key
+			continue
+		}
+
+		delivery.Error = err.Error()
+		log.Error(err, "Cannot deliver the notification", "sink", sink.Name, "event", event, "attempts", attempts)
+		if attempts < maxNotificationAttempts {
+			return
+		}
+		// Drop the notification, it is not sent again
+		sinkStatus.Delivered[event] = pending.key
+		r.Recorder.Eventf(koorCluster, corev1.EventTypeWarning, "NotificationFailed",
+			"Cannot notify %s about %s after %d attempts: %s", sink.Name, event, attempts, err)
+	}
+}
+
+// nextNotificationAttempt returns when a failed delivery is retried
+func nextNotificationAttempt(delivery *storagev1alpha1.NotificationDelivery) time.Time {
+	backoff := notificationRetryInterval
+	for i := int32(1); i < delivery.Attempts && backoff < maxNotificationRetryInterval; i++ {
+		backoff *= 2
+	}
+	return delivery.Time.Add(min(backoff, maxNotificationRetryInterval))
+}
+
+// nextNotificationRetry returns when the next failed notification is retried or nil if none failed
+func nextNotificationRetry(status *storagev1alpha1.KoorClusterStatus) *time.Time {
+	var next *time.Time
+	for i := range status.Notifications {
+		last := status.Notifications[i].LastDelivery
+		if last == nil || last.Succeeded || status.Notifications[i].Delivered[last.Event] == last.Key {
+			continue
+		}
+		if retry := nextNotificationAttempt(last); next == nil || retry.Before(*next) {
+			next = &retry
+		}
+	}
+	return next
+}
+
+// sendNotification sends the notification to the target of the sink
+func (r *KoorClusterReconciler) sendNotification(
+	ctx context.Context,
+	namespace string,
+	sink *storagev1alpha1.NotificationSink,
+	notification *utils.Notification,
+) error {
+	switch {
+	case sink.Webhook != nil:
+		url, err := r.webhookURL(ctx, namespace, sink.Webhook)
+		if err != nil {
+			return err
+		}
+		return r.notifier.Webhook(ctx, url, notification)
+	case sink.Slack != nil:
+		url, err := r.webhookURL(ctx, namespace, &sink.Slack.WebhookSink)
+		if err != nil {
+			return err
+		}
+		return r.notifier.Slack(ctx, url, sink.Slack.Channel, notification)
+	case sink.Email != nil:
+		email := sink.Email
+		server := utils.SMTPServer{Host: email.Host, Port: int(email.Port), Username: email.Username}
+		if server.Port == 0 {
+			server.Port = 587
+		}
+		if email.PasswordSecret != nil {
+			password, err := r.secretValue(ctx, namespace, email.PasswordSecret)
+			if err != nil {
+				return err
+			}
+			server.Password = password
+		}
+		return r.notifier.Email(ctx, server, email.From, email.To, notification)
+	case sink.Alertmanager != nil:
+		return r.notifier.Alertmanager(ctx, sink.Alertmanager.URL, notification)
+	}
+	return fmt.Errorf("the sink %s has no target", sink.Name)
+}
+
+func (r *KoorClusterReconciler) webhookURL(ctx context.Context, namespace string, webhook *storagev1alpha1.WebhookSink) (string, error) {
+	if webhook.URLSecret != nil {
+		return r.secretValue(ctx, namespace, webhook.URLSecret)
+	}
+	return webhook.URL, nil
+}
+
+// secretValue returns the value of the key of the Secret in the namespace
+func (r *KoorClusterReconciler) secretValue(ctx context.Context, namespace string, selector *corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Name: selector.Name, Namespace: namespace}, secret); err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", fmt.Errorf("the Secret %s has no key %s", selector.Name, selector.Key)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+var _ = Describe("Notification sinks", func() {
+	const namespace = "rook-ceph"
+
+	var (
+		ctx          context.Context
+		recorder     *record.FakeRecorder
+		mockNotifier *mocks.MockNotifier
+		reconciler   *KoorClusterReconciler
+		koorCluster  *storagev1alpha1.KoorCluster
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: namespace},
+				Data:       map[string][]byte{"url": []byte("https://hooks.example.com/T000/B000\n")},
+			},
+		).Build()
+		recorder = record.NewFakeRecorder(10)
+		mockNotifier = mocks.NewMockNotifier(gomock.NewController(GinkgoT()))
+		reconciler = &KoorClusterReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder, notifier: mockNotifier}
+
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "koor", Namespace: namespace},
+			Spec: storagev1alpha1.KoorClusterSpec{
+				Notifications: []storagev1alpha1.NotificationSink{
+					{
+						Name:    "ops",
+						Events:  []storagev1alpha1.NotificationEvent{storagev1alpha1.NotificationEventHealthDegraded},
+						Webhook: &storagev1alpha1.WebhookSink{URL: "https://ops.example.com/hook"},
+					},
+					{
+						Name: "chat",
+						Slack: &storagev1alpha1.SlackSink{
+							WebhookSink: storagev1alpha1.WebhookSink{
+								URLSecret: &corev1.SecretKeySelector{
+									LocalObjectReference: corev1.LocalObjectReference{Name: "slack"},
+									Key:                  "url",
+								},
+							},
+							Channel: "#storage",
+						},
+					},
+				},
+			},
+			Status: storagev1alpha1.KoorClusterStatus{
+				MeetsMinimumResources: true,
+				CurrentVersions:       storagev1alpha1.ProductVersions{Ksd: "v1.12.0", Ceph: "v17.2.6"},
+				CephCluster: &storagev1alpha1.CephClusterStatus{
+					Name:   "rook-ceph",
+					Health: storagev1alpha1.CephHealthWarn,
+					HealthChecks: []storagev1alpha1.CephHealthCheck{
+						{Name: "OSD_DOWN", Severity: storagev1alpha1.CephHealthWarn, Message: "1 osds down"},
+					},
+				},
+			},
+		}
+	})
+
+	It("Should notify the sinks about the events they accept once", func() {
+		mockNotifier.EXPECT().Webhook(gomock.Any(), "https://ops.example.com/hook", gomock.Any()).
+			DoAndReturn(func(ctx context.Context, url string, notification *utils.Notification) error {
+				Expect(notification.Event).To(Equal("HealthDegraded"))
+				Expect(notification.Severity).To(Equal("warning"))
+				Expect(notification.Summary).To(Equal("Ceph reports HEALTH_WARN\nOSD_DOWN: 1 osds down"))
+				return nil
+			})
+		mockNotifier.EXPECT().Slack(gomock.Any(), "https://hooks.example.com/T000/B000", "#storage", gomock.Any()).Return(nil)
+
+		Expect(reconciler.reconcileNotificationSinks(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.Notifications).To(HaveLen(2))
+		Expect(koorCluster.Status.Notifications[0].Delivered).To(Equal(map[storagev1alpha1.NotificationEvent]string{
+			storagev1alpha1.NotificationEventHealthDegraded: "HEALTH_WARN,OSD_DOWN",
+		}))
+		Expect(koorCluster.Status.Notifications[1].LastDelivery.Succeeded).To(BeTrue())
+
+		// Nothing changed, so nothing is sent again
+		Expect(reconciler.reconcileNotificationSinks(ctx, koorCluster)).To(Succeed())
+
+		// A new version is only sent to the sink without a filter
+		koorCluster.Status.LatestVersions = &storagev1alpha1.DetailedProductVersions{
+			Ceph: &storagev1alpha1.DetailedVersion{Version: "v18.2.0"},
+		}
+		mockNotifier.EXPECT().Slack(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, url string, channel string, notification *utils.Notification) error {
+				Expect(notification.Event).To(Equal("UpgradeAvailable"))
+				Expect(notification.Summary).To(Equal("An upgrade to ceph v18.2.0 is available"))
+				return nil
+			})
+		Expect(reconciler.reconcileNotificationSinks(ctx, koorCluster)).To(Succeed())
+	})
+
+	It("Should notify again when an event recurs", func() {
+		koorCluster.Spec.Notifications = koorCluster.Spec.Notifications[:1]
+		mockNotifier.EXPECT().Webhook(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+		Expect(reconciler.reconcileNotificationSinks(ctx, koorCluster)).To(Succeed())
+		koorCluster.Status.CephCluster.Health = storagev1alpha1.CephHealthOK
+		koorCluster.Status.CephCluster.HealthChecks = nil
+		Expect(reconciler.reconcileNotificationSinks(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.Notifications[0].Delivered).To(BeEmpty())
+
+		koorCluster.Status.CephCluster.Health = storagev1alpha1.CephHealthWarn
+		Expect(reconciler.reconcileNotificationSinks(ctx, koorCluster)).To(Succeed())
+	})
+
+	It("Should retry failed deliveries with a backoff and then drop them", func() {
+		koorCluster.Spec.Notifications = koorCluster.Spec.Notifications[:1]
+		mockNotifier.EXPECT().Webhook(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(errors.New("connection refused")).Times(maxNotificationAttempts)
+
+		Expect(reconciler.reconcileNotificationSinks(ctx, koorCluster)).To(Succeed())
+		last := koorCluster.Status.Notifications[0].LastDelivery
+		Expect(last.Succeeded).To(BeFalse())
+		Expect(last.Error).To(Equal("connection refused"))
+		Expect(last.Attempts).To(BeEquivalentTo(1))
+		Expect(nextNotificationRetry(&koorCluster.Status)).To(HaveValue(BeTemporally("~", last.Time.Add(notificationRetryInterval))))
+
+		// The backoff has not passed yet
+		Expect(reconciler.reconcileNotificationSinks(ctx, koorCluster)).To(Succeed())
+		Expect(koorCluster.Status.Notifications[0].LastDelivery.Attempts).To(BeEquivalentTo(1))
+
+		for attempt := 2; attempt <= maxNotificationAttempts; attempt++ {
+			last := koorCluster.Status.Notifications[0].LastDelivery
+			last.Time = metav1.NewTime(last.Time.Add(-time.Hour))
+			Expect(reconciler.reconcileNotificationSinks(ctx, koorCluster)).To(Succeed())
+			Expect(koorCluster.Status.Notifications[0].LastDelivery.Attempts).To(BeEquivalentTo(attempt))
+		}
+		Expect(koorCluster.Status.Notifications[0].Delivered).To(HaveKey(storagev1alpha1.NotificationEventHealthDegraded))
+		Expect(nextNotificationRetry(&koorCluster.Status)).To(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring("NotificationFailed")))
+	})
+
+	It("Should describe the events of the cluster", func() {
+		koorCluster.Status.CephCluster.Health = storagev1alpha1.CephHealthErr
+		koorCluster.Status.MeetsMinimumResources = false
+		koorCluster.Status.TotalResources = storagev1alpha1.Resources{
+			Nodes:   resource.NewQuantity(2, resource.DecimalSI),
+			Cpu:     resource.NewQuantity(4, resource.DecimalSI),
+			Memory:  resource.NewQuantity(8<<30, resource.BinarySI),
+			Storage: resource.NewQuantity(100<<30, resource.BinarySI),
+		}
+		koorCluster.Status.Upgrade = &storagev1alpha1.UpgradeStatus{
+			ChartVersion: "v1.13.0",
+			StartedAt:    metav1.NewTime(time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)),
+			Result:       storagev1alpha1.UpgradeResultRolledBack,
+		}
+
+		active := activeNotifications(koorCluster, time.Now())
+		Expect(active).To(HaveLen(3))
+		Expect(active[storagev1alpha1.NotificationEventHealthDegraded].notification.Severity).To(Equal("critical"))
+		Expect(active[storagev1alpha1.NotificationEventUpgradeFailed].key).To(Equal("2023-10-01T12:00:00Z"))
+		Expect(active[storagev1alpha1.NotificationEventUpgradeFailed].notification.Summary).
+			To(Equal("The upgrade to chart version v1.13.0 finished with the result RolledBack"))
+		Expect(active[storagev1alpha1.NotificationEventResourcesInsufficient].notification.Summary).
+			To(Equal("The cluster has 2 nodes, 4 CPUs, 8Gi memory, 100Gi storage, which is below the minimum recommended resources"))
+	})
+})
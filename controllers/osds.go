@@ -0,0 +1,167 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+// The OSD states in `ceph osd tree`
+const (
+	osdStatusUp   = "up"
+	osdStatusDown = "down"
+)
+
+// osdTree is the output of `ceph osd tree --format json`
+type osdTree struct {
+	Nodes []struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Status   string `json:"status"`
+		Children []int  `json:"children"`
+	} `json:"nodes"`
+}
+
+func getOsdTree(ctx context.Context, toolbox utils.CephToolbox, namespace string) (*osdTree, error) {
+	output, err := toolbox.Exec(ctx, namespace, nil, "ceph", "osd", "tree", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	tree := &osdTree{}
+	if err := json.Unmarshal([]byte(output), tree); err != nil {
+		return nil, fmt.Errorf("parsing the OSD tree failed: %w", err)
+	}
+	return tree, nil
+}
+
+// osdsWithStatus returns the sorted ids of the OSDs in the state
+func (t *osdTree) osdsWithStatus(status string) []int {
+	var osds []int
+	for _, node := range t.Nodes {
+		if node.Type == "osd" && node.Status == status {
+			osds = append(osds, node.ID)
+		}
+	}
+	slices.Sort(osds)
+	return osds
+}
+
+// hostOsds returns the sorted ids of the OSDs in the CRUSH bucket of the host
+func (t *osdTree) hostOsds(host string) []int {
+	var osds []int
+	for _, node := range t.Nodes {
+		if node.Type == "host" && node.Name == crushName(host) {
+			osds = append(osds, node.Children...)
+		}
+	}
+	slices.Sort(osds)
+	return osds
+}
+
+// osdStatus returns the state of the OSD or an empty string if it is not in the tree
+func (t *osdTree) osdStatus(id int) string {
+	for _, node := range t.Nodes {
+		if node.Type == "osd" && node.ID == id {
+			return node.Status
+		}
+	}
+	return ""
+}
+
+// getOsdLocation returns the node and the disk of the OSD
+func getOsdLocation(ctx context.Context, toolbox utils.CephToolbox, namespace string, id int) (storagev1alpha1.DownOsd, error) {
+	output, err := toolbox.Exec(ctx, namespace, nil, "ceph", "osd", "metadata", strconv.Itoa(id), "--format", "json")
+	if err != nil {
+		return storagev1alpha1.DownOsd{ID: id}, err
+	}
+	return parseOsdMetadata(id, output)
+}
+
+// parseOsdMetadata reads the node and the disk from `ceph osd metadata <id> --format json`.
+// The devices field lists the kernel names of the disks, e.g. "sdb", and device_ids their
+// device ids, e.g. "sdb=ATA_ST4000NM0035_ZC1234AB".
+func parseOsdMetadata(id int, output string) (storagev1alpha1.DownOsd, error) {
+	osd := storagev1alpha1.DownOsd{ID: id}
+	var metadata struct {
+		Hostname  string `json:"hostname"`
+		Devices   string `json:"devices"`
+		DeviceIDs string `json:"device_ids"`
+	}
+	if err := json.Unmarshal([]byte(output), &metadata); err != nil {
+		return osd, fmt.Errorf("parsing the metadata of OSD %d failed: %w", id, err)
+	}
+	osd.Node = metadata.Hostname
+	if device, _, _ := strings.Cut(metadata.Devices, ","); device != "" {
+		osd.Device = "/dev/" + device
+		for _, deviceID := range strings.Split(metadata.DeviceIDs, ",") {
+			if name, id, _ := strings.Cut(deviceID, "="); name == device {
+				osd.DeviceID = id
+			}
+		}
+	}
+	return osd, nil
+}
+
+// purgeOsd stops the OSD deployment and removes the OSD from the ceph cluster
+func purgeOsd(ctx context.Context, c client.Client, toolbox utils.CephToolbox, namespace string, id int) error {
+	deployment := &appsv1.Deployment{}
+	key := client.ObjectKey{Name: fmt.Sprintf("rook-ceph-osd-%d", id), Namespace: namespace}
+	err := c.Get(ctx, key, deployment)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+	if found && (deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 0) {
+		replicas := int32(0)
+		deployment.Spec.Replicas = &replicas
+		if err := c.Update(ctx, deployment); err != nil {
+			return fmt.Errorf("stopping OSD %d failed: %w", id, err)
+		}
+	}
+
+	if _, err := toolbox.Exec(ctx, namespace, nil, "ceph", "osd", "purge", strconv.Itoa(id),
+		"--yes-i-really-mean-it"); err != nil {
+		return err
+	}
+	if found {
+		if err := c.Delete(ctx, deployment); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("deleting OSD %d failed: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func osdIDs(osds []int) []string {
+	ids := make([]string, len(osds))
+	for i, id := range osds {
+		ids[i] = strconv.Itoa(id)
+	}
+	return ids
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// checkPaused reports whether the KoorCluster is paused and records it in the Paused condition
+func (r *KoorClusterReconciler) checkPaused(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) bool {
+	log := log.FromContext(ctx)
+	conditions := &koorCluster.Status.Conditions
+	wasPaused := meta.IsStatusConditionTrue(*conditions, storagev1alpha1.ConditionPaused)
+
+	if !koorCluster.IsPaused() {
+		if wasPaused {
+			log.Info("Reconciliation resumed")
+			r.Recorder.Event(koorCluster, corev1.EventTypeNormal, "Resumed", "Reconciliation resumed")
+		}
+		meta.RemoveStatusCondition(conditions, storagev1alpha1.ConditionPaused)
+		return false
+	}
+
+	reason := "PausedBySpec"
+	source := "spec.paused"
+	if !koorCluster.Spec.Paused {
+		reason = "PausedByAnnotation"
+		source = fmt.Sprintf("the %s annotation", storagev1alpha1.PausedAnnotation)
+	}
+	message := fmt.Sprintf("Changes to the cluster, helm releases and upgrades are paused by %s", source)
+	if !wasPaused {
+		log.Info(message)
+		r.Recorder.Event(koorCluster, corev1.EventTypeNormal, "Paused", message)
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionPaused,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	})
+	return true
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	hcmock "github.com/mittwald/go-helm-client/mock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+)
+
+var _ = Describe("Pausing a KoorCluster", func() {
+	var (
+		koorCluster *storagev1alpha1.KoorCluster
+		recorder    *record.FakeRecorder
+		reconciler  *KoorClusterReconciler
+	)
+
+	BeforeEach(func() {
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "paused", Namespace: "default"},
+		}
+		recorder = record.NewFakeRecorder(10)
+		reconciler = &KoorClusterReconciler{Recorder: recorder}
+	})
+
+	It("Should not pause by default", func() {
+		Expect(reconciler.checkPaused(context.Background(), koorCluster)).To(BeFalse())
+		Expect(meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionPaused)).To(BeNil())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("Should pause with spec.paused", func() {
+		koorCluster.Spec.Paused = true
+		koorCluster.Spec.UpgradeOptions.Mode = storagev1alpha1.UpgradeModeDisabled
+		Expect(reconciler.checkPaused(context.Background(), koorCluster)).To(BeTrue())
+		condition := meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionPaused)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("PausedBySpec"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Paused")))
+	})
+
+	It("Should pause with the annotation and notify once when resumed", func() {
+		koorCluster.Annotations = map[string]string{storagev1alpha1.PausedAnnotation: "true"}
+		Expect(reconciler.checkPaused(context.Background(), koorCluster)).To(BeTrue())
+		Expect(reconciler.checkPaused(context.Background(), koorCluster)).To(BeTrue())
+		condition := meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionPaused)
+		Expect(condition.Reason).To(Equal("PausedByAnnotation"))
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Paused")))
+
+		koorCluster.Annotations[storagev1alpha1.PausedAnnotation] = "false"
+		Expect(reconciler.checkPaused(context.Background(), koorCluster)).To(BeFalse())
+		Expect(meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionPaused)).To(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring("Resumed")))
+	})
+
+	It("Should not change the cluster while paused", func() {
+		ctx := context.Background()
+		koorCluster.Spec.Paused = true
+		koorCluster.Spec.UpgradeOptions.Mode = storagev1alpha1.UpgradeModeDisabled
+		koorCluster.Annotations = map[string]string{
+			storagev1alpha1.ReplaceOsdsAnnotation:             "2",
+			storagev1alpha1.RotateDashboardPasswordAnnotation: "true",
+		}
+		koorCluster.Spec.CephConfig = storagev1alpha1.CephConfig{"global": {"osd_pool_default_size": "3"}}
+		koorCluster.Spec.StorageClasses = []storagev1alpha1.StorageClassSpec{{Name: "ceph-block", BlockPool: "replicapool"}}
+		koorCluster.Status.CephCluster = &storagev1alpha1.CephClusterStatus{Health: storagev1alpha1.CephHealthOK}
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		scheme.AddKnownTypeWithName(cephClusterGVK, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(cephClusterGVK.GroupVersion().WithKind(cephClusterGVK.Kind+"List"),
+			&unstructured.UnstructuredList{})
+		k8sClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithStatusSubresource(&storagev1alpha1.KoorCluster{}).
+			WithObjects(koorCluster).
+			Build()
+		mockController := gomock.NewController(GinkgoT())
+		// The strict mocks fail on any ceph command or helm call
+		reconciler.Client = k8sClient
+		reconciler.Scheme = scheme
+		reconciler.toolbox = mocks.NewMockCephToolbox(mockController)
+		crons := mocks.NewMockCronRegistry(mockController)
+		crons.EXPECT().Get(gomock.Any()).Return("", false).AnyTimes()
+		reconciler.crons = crons
+
+		Expect(reconciler.reconcileNormal(ctx, koorCluster, hcmock.NewMockClient(mockController))).To(Succeed())
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(koorCluster), koorCluster)).To(Succeed())
+		Expect(koorCluster.Annotations).To(HaveKey(storagev1alpha1.ReplaceOsdsAnnotation))
+		Expect(koorCluster.Annotations).To(HaveKey(storagev1alpha1.RotateDashboardPasswordAnnotation))
+		Expect(meta.IsStatusConditionTrue(koorCluster.Status.Conditions, storagev1alpha1.ConditionPaused)).To(BeTrue())
+		Expect(koorCluster.Status.CephConfig).To(BeEmpty())
+
+		replacements := &storagev1alpha1.KoorOsdReplacementList{}
+		Expect(k8sClient.List(ctx, replacements)).To(Succeed())
+		Expect(replacements.Items).To(BeEmpty())
+		storageClasses := &storagev1.StorageClassList{}
+		Expect(k8sClient.List(ctx, storageClasses)).To(Succeed())
+		Expect(storageClasses.Items).To(BeEmpty())
+	})
+})
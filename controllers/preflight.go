@@ -0,0 +1,81 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// isEnabled treats unset boolean options as enabled, matching their CRD defaults
+func isEnabled(option *bool) bool {
+	return option == nil || *option
+}
+
+// preflightChecks returns the reasons why the KSD or ceph version must not be changed right now
+func preflightChecks(koorCluster *storagev1alpha1.KoorCluster) []string {
+	options := koorCluster.Spec.UpgradeOptions.Preflight
+	status := &koorCluster.Status
+	cephStatus := status.CephCluster
+	var failures []string
+
+	if isEnabled(options.RequireHealthOK) && !cephStatus.IsHealthy() {
+		health := "unknown"
+		if cephStatus != nil && cephStatus.Health != "" {
+			health = string(cephStatus.Health)
+		}
+		failures = append(failures, fmt.Sprintf("ceph health is %s", health))
+	}
+
+	if isEnabled(options.RequireCleanPGs) {
+		switch {
+		case cephStatus == nil || cephStatus.Pgs == nil:
+			failures = append(failures, "placement group states are unknown")
+		case cephStatus.Pgs.Degraded > 0 || cephStatus.Pgs.Backfilling > 0:
+			failures = append(failures, fmt.Sprintf("%d placement groups are degraded and %d are backfilling",
+				cephStatus.Pgs.Degraded, cephStatus.Pgs.Backfilling))
+		}
+	}
+
+	if isEnabled(options.RequireAllOsdsUp) {
+		switch {
+		case cephStatus == nil || cephStatus.Osds == nil:
+			failures = append(failures, "OSD states are unknown")
+		case cephStatus.Osds.Up < cephStatus.Osds.Total || cephStatus.Osds.In < cephStatus.Osds.Total:
+			failures = append(failures, fmt.Sprintf("%d of %d OSDs are up and %d are in",
+				cephStatus.Osds.Up, cephStatus.Osds.Total, cephStatus.Osds.In))
+		}
+	}
+
+	if options.MinAvailablePercent > 0 {
+		var usedPercent int32
+		ok := false
+		if cephStatus != nil {
+			usedPercent, ok = cephStatus.Capacity.UsedPercent()
+		}
+		switch {
+		case !ok:
+			failures = append(failures, "available capacity is unknown")
+		case 100-usedPercent < options.MinAvailablePercent:
+			failures = append(failures, fmt.Sprintf("%d%% of the capacity is available, %d%% is required",
+				100-usedPercent, options.MinAvailablePercent))
+		}
+	}
+
+	return failures
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	hc "github.com/mittwald/go-helm-client"
+	hcmock "github.com/mittwald/go-helm-client/mock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+var _ = Describe("Preflight checks", func() {
+	var koorCluster *storagev1alpha1.KoorCluster
+
+	BeforeEach(func() {
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "preflight", Namespace: "default"},
+			Spec: storagev1alpha1.KoorClusterSpec{
+				UpgradeOptions: storagev1alpha1.UpgradeOptions{
+					Preflight: storagev1alpha1.PreflightOptions{MinAvailablePercent: 20},
+				},
+			},
+			Status: storagev1alpha1.KoorClusterStatus{
+				CurrentVersions: storagev1alpha1.ProductVersions{Kube: "v1.27.3+k3s1"},
+				CephCluster: &storagev1alpha1.CephClusterStatus{
+					Health: storagev1alpha1.CephHealthOK,
+					Capacity: storagev1alpha1.CephCapacity{
+						Total: resource.NewQuantity(100<<30, resource.BinarySI),
+						Used:  resource.NewQuantity(50<<30, resource.BinarySI),
+					},
+					Osds: &storagev1alpha1.CephOsdCounts{Total: 3, Up: 3, In: 3},
+					Pgs:  &storagev1alpha1.CephPgCounts{Total: 33},
+				},
+			},
+		}
+	})
+
+	It("Should pass on a healthy cluster", func() {
+		Expect(preflightChecks(koorCluster)).To(BeEmpty())
+	})
+
+	It("Should report every failed check", func() {
+		cephStatus := koorCluster.Status.CephCluster
+		cephStatus.Health = storagev1alpha1.CephHealthWarn
+		cephStatus.Pgs.Degraded = 2
+		cephStatus.Osds.Up = 2
+		cephStatus.Capacity.Used = resource.NewQuantity(90<<30, resource.BinarySI)
+
+		Expect(preflightChecks(koorCluster)).To(ConsistOf(
+			"ceph health is HEALTH_WARN",
+			"2 placement groups are degraded and 0 are backfilling",
+			"2 of 3 OSDs are up and 3 are in",
+			"10% of the capacity is available, 20% is required",
+		))
+	})
+
+	It("Should skip disabled checks", func() {
+		disabled := false
+		koorCluster.Spec.UpgradeOptions.Preflight = storagev1alpha1.PreflightOptions{
+			RequireHealthOK:  &disabled,
+			RequireCleanPGs:  &disabled,
+			RequireAllOsdsUp: &disabled,
+		}
+		koorCluster.Status.CephCluster = nil
+		Expect(preflightChecks(koorCluster)).To(BeEmpty())
+	})
+
+	It("Should keep the deployed chart version when the checks fail", func() {
+		mockCtrl := gomock.NewController(GinkgoT())
+		mockHelmClient := hcmock.NewMockClient(mockCtrl)
+		reconciler := &KoorClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+		koorCluster.Status.CephCluster.Health = storagev1alpha1.CephHealthErr
+
+		gomock.InOrder(
+			mockHelmClient.EXPECT().GetRelease("ksd").Return(&release.Release{
+				Chart: &chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.0"}},
+			}, nil),
+			mockHelmClient.EXPECT().GetChart("koor-release/rook-ceph", gomock.Any()).Return(
+				&chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.1"}}, "", nil),
+			expectRelease(mockHelmClient, "ksd-cluster", "v1.11.0"),
+			expectInstall(mockHelmClient, "ksd", "v1.11.0"),
+			mockHelmClient.EXPECT().GetRelease("ksd-cluster").Return(&release.Release{
+				Chart: &chart.Chart{Metadata: &chart.Metadata{Version: "v1.11.0"}},
+			}, nil),
+			expectInstall(mockHelmClient, "ksd-cluster", "v1.11.0"),
+		)
+
+		operatorChartSpec := &hc.ChartSpec{ReleaseName: "ksd", ChartName: "koor-release/rook-ceph"}
+		clusterChartSpec := &hc.ChartSpec{ReleaseName: "ksd-cluster", ChartName: "koor-release/rook-ceph-cluster"}
+		Expect(reconciler.reconcileUpgrade(context.Background(), koorCluster, mockHelmClient,
+			operatorChartSpec, clusterChartSpec)).To(Succeed())
+		Expect(koorCluster.Status.Upgrade).To(BeNil())
+
+		condition := meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionPreflightPassed)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Message).To(ContainSubstring("ceph health is HEALTH_ERR"))
+	})
+})
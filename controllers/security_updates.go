@@ -0,0 +1,99 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// securityUpdate returns the highest severity and a description of the available upgrades that fix
+// security vulnerabilities, or an empty description if the available upgrades are routine updates
+func securityUpdate(status *storagev1alpha1.KoorClusterStatus) (storagev1alpha1.VersionSeverity, string) {
+	plan := upgradePlan(status.CurrentVersions, status.LatestVersions)
+	if plan == nil {
+		return "", ""
+	}
+
+	severity := storagev1alpha1.VersionSeveritySecurity
+	var updates []string
+	for _, product := range []struct {
+		name    string
+		target  string
+		version *storagev1alpha1.DetailedVersion
+	}{
+		{"KSD", plan.Ksd, status.LatestVersions.Ksd},
+		{"ceph", plan.Ceph, status.LatestVersions.Ceph},
+	} {
+		if product.target == "" || !product.version.IsSecurityUpdate() {
+			continue
+		}
+		if product.version.Severity == storagev1alpha1.VersionSeverityCritical {
+			severity = storagev1alpha1.VersionSeverityCritical
+		}
+		update := fmt.Sprintf("%s %s", product.name, product.target)
+		if len(product.version.Advisories) > 0 {
+			update += " fixes " + strings.Join(product.version.Advisories, ", ")
+		}
+		if product.version.ReleaseNotesURL != "" {
+			update += fmt.Sprintf(" (%s)", product.version.ReleaseNotesURL)
+		}
+		updates = append(updates, update)
+	}
+	if len(updates) == 0 {
+		return "", ""
+	}
+	return severity, strings.Join(updates, " and ")
+}
+
+// reconcileSecurityUpdate sets the SecurityUpdateAvailable condition and warns once about new security updates
+func (r *KoorClusterReconciler) reconcileSecurityUpdate(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	status := &koorCluster.Status
+	severity, update := securityUpdate(status)
+	if update == "" {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionSecurityUpdateAvailable,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoSecurityUpdate",
+			Message: "No available upgrade fixes security vulnerabilities",
+		})
+		return nil
+	}
+
+	message := fmt.Sprintf("A security upgrade is available: %s", update)
+	if severity == storagev1alpha1.VersionSeverityCritical {
+		message = fmt.Sprintf("A critical security upgrade is available: %s", update)
+	}
+	previous := meta.FindStatusCondition(status.Conditions, storagev1alpha1.ConditionSecurityUpdateAvailable)
+	if previous == nil || previous.Status != metav1.ConditionTrue || previous.Message != message {
+		r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "SecurityUpdateAvailable", message)
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionSecurityUpdateAvailable,
+		Status:  metav1.ConditionTrue,
+		Reason:  string(severity) + "Update",
+		Message: message,
+	})
+	return nil
+}
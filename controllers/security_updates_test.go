@@ -0,0 +1,102 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+var _ = Describe("Security updates", func() {
+	var (
+		recorder    *record.FakeRecorder
+		reconciler  *KoorClusterReconciler
+		koorCluster *storagev1alpha1.KoorCluster
+	)
+
+	BeforeEach(func() {
+		recorder = record.NewFakeRecorder(10)
+		reconciler = &KoorClusterReconciler{Client: newFakeClient(), Recorder: recorder}
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "koor", Namespace: "default"},
+			Status: storagev1alpha1.KoorClusterStatus{
+				CurrentVersions: storagev1alpha1.ProductVersions{Ksd: "v1.12.0", Ceph: "v18.2.0"},
+				LatestVersions: &storagev1alpha1.DetailedProductVersions{
+					Ksd: &storagev1alpha1.DetailedVersion{Version: "v1.12.1", Severity: storagev1alpha1.VersionSeverityRoutine},
+					Ceph: &storagev1alpha1.DetailedVersion{
+						Version:         "v18.2.1",
+						Severity:        storagev1alpha1.VersionSeveritySecurity,
+						Advisories:      []string{"CVE-2023-43040"},
+						ReleaseNotesURL: "https://docs.ceph.com/en/latest/releases/reef/",
+					},
+				},
+			},
+		}
+	})
+
+	reconcile := func() *metav1.Condition {
+		Expect(reconciler.reconcileSecurityUpdate(context.Background(), koorCluster)).To(Succeed())
+		return meta.FindStatusCondition(koorCluster.Status.Conditions, storagev1alpha1.ConditionSecurityUpdateAvailable)
+	}
+
+	It("Should warn once about security updates", func() {
+		condition := reconcile()
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("SecurityUpdate"))
+		Expect(condition.Message).To(Equal("A security upgrade is available: " +
+			"ceph v18.2.1 fixes CVE-2023-43040 (https://docs.ceph.com/en/latest/releases/reef/)"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Warning SecurityUpdateAvailable")))
+
+		reconcile()
+		Expect(recorder.Events).NotTo(Receive())
+
+		koorCluster.Status.LatestVersions.Ksd.Severity = storagev1alpha1.VersionSeverityCritical
+		condition = reconcile()
+		Expect(condition.Reason).To(Equal("CriticalUpdate"))
+		Expect(condition.Message).To(HavePrefix("A critical security upgrade is available: KSD v1.12.1 and ceph v18.2.1"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Warning SecurityUpdateAvailable")))
+	})
+
+	It("Should not warn about routine updates", func() {
+		koorCluster.Status.LatestVersions.Ceph.Severity = ""
+		condition := reconcile()
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(recorder.Events).NotTo(Receive())
+
+		active := activeNotifications(koorCluster, time.Now())
+		Expect(active[storagev1alpha1.NotificationEventUpgradeAvailable].notification.Severity).To(Equal("info"))
+	})
+
+	It("Should ignore security updates that are installed", func() {
+		koorCluster.Status.CurrentVersions.Ceph = "v18.2.1"
+		Expect(reconcile().Status).To(Equal(metav1.ConditionFalse))
+	})
+
+	It("Should raise the severity of the notification", func() {
+		notification := activeNotifications(koorCluster, time.Now())[storagev1alpha1.NotificationEventUpgradeAvailable].notification
+		Expect(notification.Severity).To(Equal("warning"))
+		Expect(notification.Summary).To(HavePrefix("A security upgrade to KSD v1.12.1 and ceph v18.2.1 is available: ceph v18.2.1 fixes"))
+	})
+})
@@ -0,0 +1,141 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// The snapshot kinds of the external snapshotter. We use unstructured objects because
+// the CRDs are optional and to avoid depending on the snapshotter api.
+var (
+	volumeSnapshotClassGVK = schema.GroupVersionKind{
+		Group:   "snapshot.storage.k8s.io",
+		Version: "v1",
+		Kind:    "VolumeSnapshotClass",
+	}
+	volumeSnapshotGVK = schema.GroupVersionKind{
+		Group:   "snapshot.storage.k8s.io",
+		Version: "v1",
+		Kind:    "VolumeSnapshot",
+	}
+)
+
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshotclasses,verbs=get;list;watch;create;update;patch;delete
+
+// newVolumeSnapshotClass builds the VolumeSnapshotClass for the volumes of the StorageClass
+func newVolumeSnapshotClass(koorCluster *storagev1alpha1.KoorCluster, storageClass *storagev1.StorageClass) *unstructured.Unstructured {
+	namespace := koorCluster.Namespace
+	snapshotClass := &unstructured.Unstructured{Object: map[string]any{
+		"driver": storageClass.Provisioner,
+		"parameters": map[string]any{
+			"clusterID": namespace,
+			// The provisioner secret also allows taking snapshots
+			"csi.storage.k8s.io/snapshotter-secret-name":      storageClass.Parameters["csi.storage.k8s.io/provisioner-secret-name"],
+			"csi.storage.k8s.io/snapshotter-secret-namespace": namespace,
+		},
+		"deletionPolicy": string(*storageClass.ReclaimPolicy),
+	}}
+	snapshotClass.SetGroupVersionKind(volumeSnapshotClassGVK)
+	snapshotClass.SetName(storageClass.Name)
+	snapshotClass.SetLabels(map[string]string{storageClassOwnerLabel: namespace})
+	return snapshotClass
+}
+
+// applyVolumeSnapshotClass creates or updates the VolumeSnapshotClass. It returns false if the snapshot
+// CRDs are not installed or a VolumeSnapshotClass with the same name exists that the operator does not manage.
+func (r *KoorClusterReconciler) applyVolumeSnapshotClass(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	snapshotClass *unstructured.Unstructured,
+) (bool, error) {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(volumeSnapshotClassGVK)
+	err := r.Get(ctx, client.ObjectKey{Name: snapshotClass.GetName()}, existing)
+	switch {
+	case meta.IsNoMatchError(err):
+		r.Recorder.Eventf(koorCluster, corev1.EventTypeWarning, "VolumeSnapshotClassFailed",
+			"Cannot create VolumeSnapshotClass %s, the VolumeSnapshot CRDs are not installed", snapshotClass.GetName())
+		return false, nil
+	case k8serrors.IsNotFound(err):
+		return true, r.Create(ctx, snapshotClass)
+	case err != nil:
+		return false, err
+	}
+
+	if existing.GetLabels()[storageClassOwnerLabel] != koorCluster.Namespace {
+		r.Recorder.Eventf(koorCluster, corev1.EventTypeWarning, "VolumeSnapshotClassConflict",
+			"VolumeSnapshotClass %s already exists and is not managed by the operator", snapshotClass.GetName())
+		return false, nil
+	}
+
+	// The driver and the parameters are immutable
+	if existing.Object["driver"] != snapshotClass.Object["driver"] ||
+		!equality.Semantic.DeepEqual(existing.Object["parameters"], snapshotClass.Object["parameters"]) {
+		log.FromContext(ctx).Info("Recreating VolumeSnapshotClass with changed immutable fields", "name", snapshotClass.GetName())
+		if err := r.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+		return true, r.Create(ctx, snapshotClass)
+	}
+	if existing.Object["deletionPolicy"] == snapshotClass.Object["deletionPolicy"] {
+		return true, nil
+	}
+	existing.Object["deletionPolicy"] = snapshotClass.Object["deletionPolicy"]
+	return true, r.Update(ctx, existing)
+}
+
+// deleteVolumeSnapshotClasses deletes the VolumeSnapshotClasses managed by the operator except the ones to keep
+func (r *KoorClusterReconciler) deleteVolumeSnapshotClasses(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	keep []string,
+) error {
+	snapshotClasses := &unstructured.UnstructuredList{}
+	snapshotClasses.SetGroupVersionKind(volumeSnapshotClassGVK.GroupVersion().WithKind(volumeSnapshotClassGVK.Kind + "List"))
+	err := r.List(ctx, snapshotClasses, client.MatchingLabels{storageClassOwnerLabel: koorCluster.Namespace})
+	if meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for i := range snapshotClasses.Items {
+		snapshotClass := &snapshotClasses.Items[i]
+		if slices.Contains(keep, snapshotClass.GetName()) {
+			continue
+		}
+		log.FromContext(ctx).Info("Deleting VolumeSnapshotClass removed from the spec", "name", snapshotClass.GetName())
+		if err := r.Delete(ctx, snapshotClass); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+const (
+	// The label of the StorageClasses managed by the operator. Its value is the namespace of the KoorCluster.
+	storageClassOwnerLabel = "storage.koor.tech/koorcluster"
+
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch;create;update;patch;delete
+
+// reconcileStorageClasses creates the StorageClasses of the spec, deletes the ones removed from the spec
+// and makes sure that the default StorageClass of the spec is the only default
+func (r *KoorClusterReconciler) reconcileStorageClasses(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+
+	var statuses []storagev1alpha1.StorageClassStatus
+	var snapshotClasses []string
+	defaultClass := ""
+	for _, spec := range koorCluster.Spec.StorageClasses {
+		storageClass, status := newStorageClass(koorCluster, spec)
+		applied, err := r.applyStorageClass(ctx, koorCluster, storageClass)
+		if err != nil {
+			log.Error(err, "Cannot apply StorageClass", "name", storageClass.Name)
+			return err
+		}
+		if !applied {
+			continue
+		}
+		if spec.Snapshots {
+			applied, err := r.applyVolumeSnapshotClass(ctx, koorCluster, newVolumeSnapshotClass(koorCluster, storageClass))
+			if err != nil {
+				log.Error(err, "Cannot apply VolumeSnapshotClass", "name", storageClass.Name)
+				return err
+			}
+			if applied {
+				status.VolumeSnapshotClass = storageClass.Name
+				snapshotClasses = append(snapshotClasses, storageClass.Name)
+			}
+		}
+		statuses = append(statuses, status)
+		if spec.Default {
+			defaultClass = spec.Name
+		}
+	}
+
+	storageClasses := &storagev1.StorageClassList{}
+	if err := r.List(ctx, storageClasses); err != nil {
+		return err
+	}
+	for i := range storageClasses.Items {
+		storageClass := &storageClasses.Items[i]
+		if storageClass.Labels[storageClassOwnerLabel] == koorCluster.Namespace &&
+			!slices.ContainsFunc(koorCluster.Spec.StorageClasses, func(spec storagev1alpha1.StorageClassSpec) bool {
+				return spec.Name == storageClass.Name
+			}) {
+			log.Info("Deleting StorageClass removed from the spec", "name", storageClass.Name)
+			if err := r.Delete(ctx, storageClass); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+
+		if defaultClass != "" && storageClass.Name != defaultClass && isDefaultStorageClass(storageClass) {
+			for _, annotation := range []string{defaultStorageClassAnnotation, betaDefaultStorageClassAnnotation} {
+				if _, ok := storageClass.Annotations[annotation]; ok {
+					storageClass.Annotations[annotation] = "false"
+				}
+			}
+			if err := r.Update(ctx, storageClass); err != nil {
+				return err
+			}
+			r.Recorder.Eventf(koorCluster, corev1.EventTypeNormal, "DefaultStorageClassChanged",
+				"StorageClass %s is no longer the default, %s is the default", storageClass.Name, defaultClass)
+		}
+	}
+
+	if err := r.deleteVolumeSnapshotClasses(ctx, koorCluster, snapshotClasses); err != nil {
+		log.Error(err, "Cannot delete VolumeSnapshotClasses")
+		return err
+	}
+
+	koorCluster.Status.StorageClasses = statuses
+	return nil
+}
+
+// applyStorageClass creates or updates the StorageClass. StorageClasses are recreated when immutable
+// fields change, which does not affect existing volumes. It returns false if a StorageClass with the
+// same name exists that the operator does not manage.
+func (r *KoorClusterReconciler) applyStorageClass(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	storageClass *storagev1.StorageClass,
+) (bool, error) {
+	existing := &storagev1.StorageClass{}
+	err := r.Get(ctx, client.ObjectKey{Name: storageClass.Name}, existing)
+	if k8serrors.IsNotFound(err) {
+		return true, r.Create(ctx, storageClass)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if existing.Labels[storageClassOwnerLabel] != koorCluster.Namespace {
+		r.Recorder.Eventf(koorCluster, corev1.EventTypeWarning, "StorageClassConflict",
+			"StorageClass %s already exists and is not managed by the operator", storageClass.Name)
+		return false, nil
+	}
+
+	if existing.Provisioner != storageClass.Provisioner ||
+		!equality.Semantic.DeepEqual(existing.Parameters, storageClass.Parameters) ||
+		!equality.Semantic.DeepEqual(existing.ReclaimPolicy, storageClass.ReclaimPolicy) ||
+		!equality.Semantic.DeepEqual(existing.VolumeBindingMode, storageClass.VolumeBindingMode) {
+		log.FromContext(ctx).Info("Recreating StorageClass with changed immutable fields", "name", storageClass.Name)
+		if err := r.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+		return true, r.Create(ctx, storageClass)
+	}
+
+	defaultValue := storageClass.Annotations[defaultStorageClassAnnotation]
+	if existing.Annotations[defaultStorageClassAnnotation] == defaultValue &&
+		equality.Semantic.DeepEqual(existing.AllowVolumeExpansion, storageClass.AllowVolumeExpansion) &&
+		equality.Semantic.DeepEqual(existing.MountOptions, storageClass.MountOptions) &&
+		equality.Semantic.DeepEqual(existing.AllowedTopologies, storageClass.AllowedTopologies) {
+		return true, nil
+	}
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	existing.Annotations[defaultStorageClassAnnotation] = defaultValue
+	existing.AllowVolumeExpansion = storageClass.AllowVolumeExpansion
+	existing.MountOptions = storageClass.MountOptions
+	existing.AllowedTopologies = storageClass.AllowedTopologies
+	return true, r.Update(ctx, existing)
+}
+
+// newStorageClass builds the StorageClass with the parameters of the rook CSI drivers
+func newStorageClass(
+	koorCluster *storagev1alpha1.KoorCluster,
+	spec storagev1alpha1.StorageClassSpec,
+) (*storagev1.StorageClass, storagev1alpha1.StorageClassStatus) {
+	// The CSI drivers and the cluster are in the namespace of the KoorCluster
+	namespace := koorCluster.Namespace
+
+	reclaimPolicy := spec.ReclaimPolicy
+	if reclaimPolicy == "" {
+		reclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	}
+	allowVolumeExpansion := isEnabled(spec.AllowVolumeExpansion)
+	volumeBindingMode := storagev1.VolumeBindingImmediate
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        spec.Name,
+			Labels:      map[string]string{storageClassOwnerLabel: namespace},
+			Annotations: map[string]string{defaultStorageClassAnnotation: strconv.FormatBool(spec.Default)},
+		},
+		ReclaimPolicy:        &reclaimPolicy,
+		AllowVolumeExpansion: &allowVolumeExpansion,
+		MountOptions:         spec.MountOptions,
+		AllowedTopologies:    spec.AllowedTopologies,
+		VolumeBindingMode:    &volumeBindingMode,
+	}
+	status := storagev1alpha1.StorageClassStatus{Name: spec.Name, Default: spec.Default}
+
+	if spec.BlockPool != "" {
+		storageClass.Provisioner = namespace + ".rbd.csi.ceph.com"
+		storageClass.Parameters = map[string]string{
+			"clusterID":     namespace,
+			"pool":          spec.BlockPool,
+			"imageFormat":   "2",
+			"imageFeatures": "layering",
+			"csi.storage.k8s.io/provisioner-secret-name":            "rook-csi-rbd-provisioner",
+			"csi.storage.k8s.io/provisioner-secret-namespace":       namespace,
+			"csi.storage.k8s.io/controller-expand-secret-name":      "rook-csi-rbd-provisioner",
+			"csi.storage.k8s.io/controller-expand-secret-namespace": namespace,
+			"csi.storage.k8s.io/node-stage-secret-name":             "rook-csi-rbd-node",
+			"csi.storage.k8s.io/node-stage-secret-namespace":        namespace,
+			"csi.storage.k8s.io/fstype":                             "ext4",
+		}
+		status.Pool = spec.BlockPool
+	} else {
+		storageClass.Provisioner = namespace + ".cephfs.csi.ceph.com"
+		storageClass.Parameters = map[string]string{
+			"clusterID": namespace,
+			"fsName":    spec.Filesystem,
+			"csi.storage.k8s.io/provisioner-secret-name":            "rook-csi-cephfs-provisioner",
+			"csi.storage.k8s.io/provisioner-secret-namespace":       namespace,
+			"csi.storage.k8s.io/controller-expand-secret-name":      "rook-csi-cephfs-provisioner",
+			"csi.storage.k8s.io/controller-expand-secret-namespace": namespace,
+			"csi.storage.k8s.io/node-stage-secret-name":             "rook-csi-cephfs-node",
+			"csi.storage.k8s.io/node-stage-secret-namespace":        namespace,
+		}
+		status.Pool = spec.Filesystem
+		// rook prefixes the data pools with the name of the filesystem
+		for _, filesystem := range koorCluster.Spec.Filesystems {
+			if filesystem.Name == spec.Filesystem && len(filesystem.DataPools) > 0 {
+				pool := filesystem.Name + "-" + filesystem.DataPools[len(filesystem.DataPools)-1].Name
+				storageClass.Parameters["pool"] = pool
+				status.Pool = pool
+			}
+		}
+	}
+	status.Provisioner = storageClass.Provisioner
+	return storageClass, status
+}
+
+func isDefaultStorageClass(storageClass *storagev1.StorageClass) bool {
+	return storageClass.Annotations[defaultStorageClassAnnotation] == "true" ||
+		storageClass.Annotations[betaDefaultStorageClassAnnotation] == "true"
+}
+
+// deleteStorageClasses deletes the StorageClasses and VolumeSnapshotClasses managed by the operator
+func (r *KoorClusterReconciler) deleteStorageClasses(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	if err := r.deleteVolumeSnapshotClasses(ctx, koorCluster, nil); err != nil {
+		return err
+	}
+	return r.DeleteAllOf(ctx, &storagev1.StorageClass{},
+		client.MatchingLabels{storageClassOwnerLabel: koorCluster.Namespace})
+}
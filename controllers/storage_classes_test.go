@@ -0,0 +1,190 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+var _ = Describe("StorageClasses", func() {
+	const namespace = "rook-ceph"
+
+	var (
+		ctx         context.Context
+		k8sClient   client.Client
+		recorder    *record.FakeRecorder
+		reconciler  *KoorClusterReconciler
+		koorCluster *storagev1alpha1.KoorCluster
+	)
+
+	getStorageClass := func(name string) *storagev1.StorageClass {
+		storageClass := &storagev1.StorageClass{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: name}, storageClass)).To(Succeed())
+		return storageClass
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+		addSnapshotKinds(scheme)
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "standard",
+					Annotations: map[string]string{defaultStorageClassAnnotation: "true"},
+				},
+				Provisioner: "rancher.io/local-path",
+			},
+			&storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "removed",
+					Labels: map[string]string{storageClassOwnerLabel: namespace},
+				},
+				Provisioner: namespace + ".rbd.csi.ceph.com",
+			},
+		).Build()
+		recorder = record.NewFakeRecorder(10)
+		reconciler = &KoorClusterReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "koor", Namespace: namespace},
+			Spec: storagev1alpha1.KoorClusterSpec{
+				Filesystems: []storagev1alpha1.Filesystem{{
+					Name: "cephfs",
+					DataPools: []storagev1alpha1.NamedPoolSpec{
+						{Name: "default"},
+						{Name: "ec"},
+					},
+				}},
+				StorageClasses: []storagev1alpha1.StorageClassSpec{
+					{Name: "fast", BlockPool: "replicapool", Default: true, MountOptions: []string{"discard"}},
+					{Name: "shared", Filesystem: "cephfs", ReclaimPolicy: corev1.PersistentVolumeReclaimRetain},
+				},
+			},
+		}
+	})
+
+	It("Should create the StorageClasses and make the default the only default", func() {
+		Expect(reconciler.reconcileStorageClasses(ctx, koorCluster)).To(Succeed())
+
+		fast := getStorageClass("fast")
+		Expect(fast.Provisioner).To(Equal("rook-ceph.rbd.csi.ceph.com"))
+		Expect(fast.Parameters).To(HaveKeyWithValue("pool", "replicapool"))
+		Expect(fast.Parameters).To(HaveKeyWithValue("clusterID", namespace))
+		Expect(fast.Annotations).To(HaveKeyWithValue(defaultStorageClassAnnotation, "true"))
+		Expect(*fast.ReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimDelete))
+		Expect(*fast.AllowVolumeExpansion).To(BeTrue())
+		Expect(fast.MountOptions).To(Equal([]string{"discard"}))
+
+		shared := getStorageClass("shared")
+		Expect(shared.Provisioner).To(Equal("rook-ceph.cephfs.csi.ceph.com"))
+		Expect(shared.Parameters).To(HaveKeyWithValue("fsName", "cephfs"))
+		Expect(shared.Parameters).To(HaveKeyWithValue("pool", "cephfs-ec"))
+		Expect(shared.Annotations).To(HaveKeyWithValue(defaultStorageClassAnnotation, "false"))
+		Expect(*shared.ReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimRetain))
+
+		Expect(getStorageClass("standard").Annotations).To(HaveKeyWithValue(defaultStorageClassAnnotation, "false"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("StorageClass standard is no longer the default")))
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: "removed"}, &storagev1.StorageClass{})
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+		Expect(koorCluster.Status.StorageClasses).To(Equal([]storagev1alpha1.StorageClassStatus{
+			{Name: "fast", Provisioner: "rook-ceph.rbd.csi.ceph.com", Pool: "replicapool", Default: true},
+			{Name: "shared", Provisioner: "rook-ceph.cephfs.csi.ceph.com", Pool: "cephfs-ec"},
+		}))
+	})
+
+	It("Should update mutable fields and recreate StorageClasses with changed immutable fields", func() {
+		Expect(reconciler.reconcileStorageClasses(ctx, koorCluster)).To(Succeed())
+		fast := getStorageClass("fast")
+		fast.Annotations["kept"] = "true"
+		Expect(k8sClient.Update(ctx, fast)).To(Succeed())
+		uid := fast.UID
+
+		koorCluster.Spec.StorageClasses[0].MountOptions = nil
+		Expect(reconciler.reconcileStorageClasses(ctx, koorCluster)).To(Succeed())
+		fast = getStorageClass("fast")
+		Expect(fast.UID).To(Equal(uid))
+		Expect(fast.MountOptions).To(BeEmpty())
+		Expect(fast.Annotations).To(HaveKeyWithValue("kept", "true"))
+
+		koorCluster.Spec.StorageClasses[0].BlockPool = "otherpool"
+		Expect(reconciler.reconcileStorageClasses(ctx, koorCluster)).To(Succeed())
+		fast = getStorageClass("fast")
+		Expect(fast.Parameters).To(HaveKeyWithValue("pool", "otherpool"))
+		Expect(fast.Annotations).NotTo(HaveKey("kept"))
+	})
+
+	It("Should not take over StorageClasses that it does not manage", func() {
+		koorCluster.Spec.StorageClasses = []storagev1alpha1.StorageClassSpec{
+			{Name: "standard", BlockPool: "replicapool", Default: true},
+		}
+		Expect(reconciler.reconcileStorageClasses(ctx, koorCluster)).To(Succeed())
+		standard := getStorageClass("standard")
+		Expect(standard.Provisioner).To(Equal("rancher.io/local-path"))
+		Expect(standard.Annotations).To(HaveKeyWithValue(defaultStorageClassAnnotation, "true"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("StorageClassConflict")))
+		Expect(koorCluster.Status.StorageClasses).To(BeEmpty())
+	})
+
+	It("Should create and delete the VolumeSnapshotClasses", func() {
+		koorCluster.Spec.StorageClasses[1].Snapshots = true
+		Expect(reconciler.reconcileStorageClasses(ctx, koorCluster)).To(Succeed())
+
+		snapshotClass := &unstructured.Unstructured{}
+		snapshotClass.SetGroupVersionKind(volumeSnapshotClassGVK)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "shared"}, snapshotClass)).To(Succeed())
+		Expect(snapshotClass.Object["driver"]).To(Equal("rook-ceph.cephfs.csi.ceph.com"))
+		Expect(snapshotClass.Object["deletionPolicy"]).To(Equal("Retain"))
+		Expect(snapshotClass.Object["parameters"]).To(HaveKeyWithValue("clusterID", namespace))
+		Expect(snapshotClass.Object["parameters"]).To(HaveKeyWithValue(
+			"csi.storage.k8s.io/snapshotter-secret-name", "rook-csi-cephfs-provisioner"))
+		Expect(koorCluster.Status.StorageClasses[1].VolumeSnapshotClass).To(Equal("shared"))
+
+		koorCluster.Spec.StorageClasses[1].Snapshots = false
+		Expect(reconciler.reconcileStorageClasses(ctx, koorCluster)).To(Succeed())
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: "shared"}, snapshotClass)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		Expect(koorCluster.Status.StorageClasses[1].VolumeSnapshotClass).To(BeEmpty())
+	})
+
+	It("Should delete the StorageClasses it manages", func() {
+		Expect(reconciler.reconcileStorageClasses(ctx, koorCluster)).To(Succeed())
+		Expect(reconciler.deleteStorageClasses(ctx, koorCluster)).To(Succeed())
+		storageClasses := &storagev1.StorageClassList{}
+		Expect(k8sClient.List(ctx, storageClasses)).To(Succeed())
+		Expect(storageClasses.Items).To(HaveLen(1))
+		Expect(storageClasses.Items[0].Name).To(Equal("standard"))
+	})
+})
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// The label of the rook OSD deployments
+const osdAppLabel = "rook-ceph-osd"
+
+// reconcileStorageNodes lists the decommissioned nodes, which the cluster chart values keep rook from creating
+// OSDs on, and the other nodes that run OSDs. Rook still uses all other nodes, including new nodes.
+func (r *KoorClusterReconciler) reconcileStorageNodes(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+	status := &koorCluster.Status
+
+	decommissionList := &storagev1alpha1.KoorNodeDecommissionList{}
+	if err := r.List(ctx, decommissionList, client.InNamespace(koorCluster.Namespace)); err != nil {
+		log.Error(err, "unable to list KoorNodeDecommissions")
+		return err
+	}
+	var decommissioned []string
+	for _, decommission := range decommissionList.Items {
+		if decommission.Status.RemovesNode() {
+			decommissioned = append(decommissioned, decommission.Status.StorageNodeName)
+		}
+	}
+	if len(decommissioned) == 0 {
+		status.StorageNodes = nil
+		status.DecommissionedNodes = nil
+		return nil
+	}
+
+	// Only nodes with OSDs are storage nodes, so that other nodes, e.g. of the control plane, are not added
+	osdList := &appsv1.DeploymentList{}
+	if err := r.List(ctx, osdList, client.InNamespace(koorCluster.Namespace), client.MatchingLabels{"app": osdAppLabel}); err != nil {
+		log.Error(err, "unable to list the OSD deployments")
+		return err
+	}
+	var storageNodes []string
+	for _, osd := range osdList.Items {
+		// rook pins the OSDs to their node with the hostname label, which also names the storage nodes
+		name := osd.Spec.Template.Spec.NodeSelector[hostnameLabel]
+		if name != "" && !slices.Contains(decommissioned, name) && !slices.Contains(storageNodes, name) {
+			storageNodes = append(storageNodes, name)
+		}
+	}
+	slices.Sort(storageNodes)
+	slices.Sort(decommissioned)
+	status.StorageNodes = storageNodes
+	status.DecommissionedNodes = decommissioned
+	return nil
+}
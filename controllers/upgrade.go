@@ -0,0 +1,496 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	hc "github.com/mittwald/go-helm-client"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// How often a running upgrade is checked while waiting for the rook operator or the ceph daemons
+const upgradePollInterval = 30 * time.Second
+
+// chartVersions are the deployed and the target version of a chart
+type chartVersions struct {
+	installed bool
+	deployed  string
+	target    string
+}
+
+// resolveChartVersions finds the deployed chart version of the release and the version the chart spec resolves to.
+// In approval mode, unpinned charts resolve to the approved version.
+func resolveChartVersions(
+	koorCluster *storagev1alpha1.KoorCluster,
+	helmClient hc.Client,
+	chartSpec *hc.ChartSpec,
+) (*chartVersions, error) {
+	deployedRelease, err := helmClient.GetRelease(chartSpec.ReleaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return &chartVersions{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot get release %s", chartSpec.ReleaseName)
+	}
+
+	versions := &chartVersions{
+		installed: true,
+		deployed:  deployedRelease.Chart.Metadata.Version,
+	}
+	version := chartSpec.Version
+	// Pinned versions need no approval
+	if koorCluster.Spec.UpgradeOptions.Mode == storagev1alpha1.UpgradeModeApproval && version == "" {
+		version = approvedChartVersion(koorCluster, versions.deployed)
+	}
+
+	targetChart, _, err := helmClient.GetChart(chartSpec.ChartName, &action.ChartPathOptions{Version: version})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot get chart %s", chartSpec.ChartName)
+	}
+	versions.target = targetChart.Metadata.Version
+	return versions, nil
+}
+
+// deployedChartVersion returns the chart version of the release, or an empty string if it is not installed
+func deployedChartVersion(helmClient hc.Client, releaseName string) (string, error) {
+	deployedRelease, err := helmClient.GetRelease(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "Cannot get release %s", releaseName)
+	}
+	return deployedRelease.Chart.Metadata.Version, nil
+}
+
+// installChart installs or upgrades the chart. An installed release keeps its chart version
+// if keepVersion is set, so that value changes apply without a version change.
+func installChart(ctx context.Context, helmClient hc.Client, chartSpec *hc.ChartSpec, keepVersion bool) error {
+	if keepVersion {
+		deployed, err := deployedChartVersion(helmClient, chartSpec.ReleaseName)
+		if err != nil {
+			return err
+		}
+		if deployed != "" {
+			chartSpec.Version = deployed
+		}
+	}
+
+	if _, err := helmClient.InstallOrUpgradeChart(ctx, chartSpec, nil); err != nil {
+		return errors.Wrapf(err, "Cannot install or upgrade chart %s", chartSpec.ChartName)
+	}
+	return nil
+}
+
+// reconcileUpgrade installs the charts and runs version changes as a staged upgrade: the rook
+// operator is upgraded first, then ceph once the operator is ready, and finally the upgrade is verified.
+// The upgrade state is kept in the status, so an interrupted upgrade resumes after a restart of the operator.
+func (r *KoorClusterReconciler) reconcileUpgrade(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	helmClient hc.Client,
+	operatorChartSpec *hc.ChartSpec,
+	clusterChartSpec *hc.ChartSpec,
+) error {
+	log := log.FromContext(ctx)
+
+	// Evaluated on every reconcile, so that no new step starts after the window closed
+	inWindow, err := checkMaintenanceWindow(koorCluster, time.Now())
+	if err != nil {
+		log.Error(err, "Cannot check the maintenance windows")
+		return err
+	}
+
+	if koorCluster.Status.Upgrade.InProgress() {
+		return r.continueUpgrade(ctx, koorCluster, helmClient, operatorChartSpec, clusterChartSpec, inWindow)
+	}
+
+	versions, err := resolveChartVersions(koorCluster, helmClient, operatorChartSpec)
+	if err != nil {
+		log.Error(err, "Cannot resolve the chart versions")
+		return err
+	}
+
+	if !versions.installed {
+		// Fresh installs need no staging
+		if err := installChart(ctx, helmClient, operatorChartSpec, false); err != nil {
+			log.Error(err, "Cannot install operator chart")
+			return err
+		}
+		if err := installChart(ctx, helmClient, clusterChartSpec, false); err != nil {
+			log.Error(err, "Cannot install cluster chart")
+			return err
+		}
+		return nil
+	}
+
+	// The cluster chart stays behind if the upgrade status was lost after the operator chart was upgraded
+	clusterDeployed, err := deployedChartVersion(helmClient, clusterChartSpec.ReleaseName)
+	if err != nil {
+		log.Error(err, "Cannot resolve the chart versions")
+		return err
+	}
+
+	cephVersion, err := targetCephVersion(koorCluster)
+	if err != nil {
+		log.Error(err, "Cannot resolve the target ceph version")
+		return err
+	}
+	currentCephVersion := koorCluster.Status.CurrentVersions.Ceph
+	operatorChanges := versions.deployed != versions.target
+	clusterChanges := clusterDeployed != "" && clusterDeployed != versions.target
+	chartChanges := operatorChanges || clusterChanges
+	cephChanges := cephVersion != "" && currentCephVersion != "" && !sameVersion(cephVersion, currentCephVersion)
+
+	if !chartChanges && !cephChanges {
+		operatorChartSpec.Version = versions.deployed
+		if err := installChart(ctx, helmClient, operatorChartSpec, false); err != nil {
+			log.Error(err, "Cannot upgrade operator chart")
+			return err
+		}
+		if err := installChart(ctx, helmClient, clusterChartSpec, true); err != nil {
+			log.Error(err, "Cannot upgrade cluster chart")
+			return err
+		}
+		return nil
+	}
+
+	if last := koorCluster.Status.Upgrade; last != nil &&
+		(last.Result == storagev1alpha1.UpgradeResultFailed || last.Result == storagev1alpha1.UpgradeResultRolledBack) &&
+		last.ChartVersion == versions.target && sameVersion(last.CephVersion, cephVersion) {
+		// A failed upgrade is not retried until the target changes
+		log.Info("The last upgrade to the target versions failed, it is not retried", "message", last.Message)
+		operatorChartSpec.Version = versions.deployed
+		if err := installChart(ctx, helmClient, operatorChartSpec, false); err != nil {
+			log.Error(err, "Cannot upgrade operator chart")
+			return err
+		}
+		if !cephChanges {
+			if err := installChart(ctx, helmClient, clusterChartSpec, true); err != nil {
+				log.Error(err, "Cannot upgrade cluster chart")
+				return err
+			}
+		}
+		return nil
+	}
+
+	fromChartVersion := versions.deployed
+	var changes []string
+	if operatorChanges {
+		changes = append(changes, fmt.Sprintf("%s from %s to %s", operatorChartSpec.ReleaseName, versions.deployed, versions.target))
+	} else if clusterChanges {
+		fromChartVersion = clusterDeployed
+		changes = append(changes, fmt.Sprintf("%s from %s to %s", clusterChartSpec.ReleaseName, clusterDeployed, versions.target))
+	}
+	if cephChanges {
+		changes = append(changes, fmt.Sprintf("ceph from %s to %s", currentCephVersion, cephVersion))
+	}
+	description := strings.Join(changes, " and ")
+
+	if !r.upgradeAllowed(ctx, koorCluster, description, inWindow) {
+		// Apply value changes only. The cluster chart values contain the pinned ceph image,
+		// so the cluster chart is left alone until the ceph upgrade may start.
+		operatorChartSpec.Version = versions.deployed
+		if err := installChart(ctx, helmClient, operatorChartSpec, false); err != nil {
+			log.Error(err, "Cannot upgrade operator chart")
+			return err
+		}
+		if !cephChanges {
+			if err := installChart(ctx, helmClient, clusterChartSpec, true); err != nil {
+				log.Error(err, "Cannot upgrade cluster chart")
+				return err
+			}
+		}
+		return nil
+	}
+
+	now := metav1.Now()
+	upgrade := &storagev1alpha1.UpgradeStatus{
+		FromChartVersion: fromChartVersion,
+		ChartVersion:     versions.target,
+		FromCephVersion:  currentCephVersion,
+		CephVersion:      cephVersion,
+		StartedAt:        now,
+	}
+	if cephChanges {
+		if upgrade.CephImage, err = targetCephImage(koorCluster); err != nil {
+			return err
+		}
+	}
+	if operatorChanges {
+		upgrade.Steps = append(upgrade.Steps,
+			storagev1alpha1.UpgradeStepStatus{Name: storagev1alpha1.UpgradeStepOperator},
+			storagev1alpha1.UpgradeStepStatus{Name: storagev1alpha1.UpgradeStepWaitForOperator},
+		)
+	}
+	upgrade.Steps = append(upgrade.Steps,
+		storagev1alpha1.UpgradeStepStatus{Name: storagev1alpha1.UpgradeStepCeph},
+		storagev1alpha1.UpgradeStepStatus{Name: storagev1alpha1.UpgradeStepWaitForDaemons},
+		storagev1alpha1.UpgradeStepStatus{Name: storagev1alpha1.UpgradeStepVerify},
+	)
+	upgrade.Step = upgrade.Steps[0].Name
+	upgrade.Steps[0].StartedAt = &now
+	koorCluster.Status.Upgrade = upgrade
+
+	message := fmt.Sprintf("Upgrading %s", description)
+	log.Info(message)
+	r.Recorder.Event(koorCluster, corev1.EventTypeNormal, "UpgradeStarted", message)
+	return r.continueUpgrade(ctx, koorCluster, helmClient, operatorChartSpec, clusterChartSpec, inWindow)
+}
+
+// upgradeAllowed checks the maintenance windows and the preflight checks before an upgrade starts
+func (r *KoorClusterReconciler) upgradeAllowed(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	description string,
+	inWindow bool,
+) bool {
+	log := log.FromContext(ctx)
+
+	if !inWindow {
+		message := fmt.Sprintf("Upgrade of %s waits for the next maintenance window", description)
+		log.Info(message)
+		r.Recorder.Event(koorCluster, corev1.EventTypeNormal, "UpgradeDeferred", message)
+		return false
+	}
+
+	if failures := preflightChecks(koorCluster); len(failures) > 0 {
+		message := fmt.Sprintf("Upgrade of %s is blocked: %s", description, strings.Join(failures, "; "))
+		log.Info(message)
+		meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionPreflightPassed,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ChecksFailed",
+			Message: message,
+		})
+		r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "UpgradeBlocked", message)
+		return false
+	}
+
+	meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionPreflightPassed,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ChecksPassed",
+		Message: fmt.Sprintf("Upgrading %s", description),
+	})
+	return true
+}
+
+// continueUpgrade runs the current step of the upgrade and advances to the next step once it is done
+func (r *KoorClusterReconciler) continueUpgrade(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	helmClient hc.Client,
+	operatorChartSpec *hc.ChartSpec,
+	clusterChartSpec *hc.ChartSpec,
+	inWindow bool,
+) error {
+	log := log.FromContext(ctx)
+	upgrade := koorCluster.Status.Upgrade
+
+	for upgrade.InProgress() {
+		done := false
+		switch upgrade.Step {
+		case storagev1alpha1.UpgradeStepOperator:
+			if !inWindow {
+				log.Info("The maintenance window closed, the operator upgrade waits for the next window")
+				break
+			}
+			operatorChartSpec.Version = upgrade.ChartVersion
+			if err := installChart(ctx, helmClient, operatorChartSpec, false); err != nil {
+				log.Error(err, "Cannot upgrade operator chart")
+				return err
+			}
+			done = true
+
+		case storagev1alpha1.UpgradeStepWaitForOperator:
+			ready, err := r.isRookOperatorReady(ctx, koorCluster.Namespace)
+			if err != nil {
+				log.Error(err, "unable to check the rook operator")
+				return err
+			}
+			done = ready
+
+		case storagev1alpha1.UpgradeStepCeph:
+			if !inWindow {
+				log.Info("The maintenance window closed, the ceph upgrade waits for the next window")
+				break
+			}
+			clusterChartSpec.Version = upgrade.ChartVersion
+			if err := installChart(ctx, helmClient, clusterChartSpec, false); err != nil {
+				log.Error(err, "Cannot upgrade cluster chart")
+				return err
+			}
+			done = true
+
+		case storagev1alpha1.UpgradeStepWaitForDaemons:
+			cephCluster, err := r.getCephCluster(ctx, koorCluster.Namespace)
+			if err != nil {
+				log.Error(err, "unable to fetch CephCluster")
+				return err
+			}
+			done = cephCluster != nil && cephDaemonsUpgraded(cephCluster, upgrade.CephVersion)
+			if !done && verificationTimedOut(koorCluster, time.Now()) {
+				r.failUpgrade(ctx, koorCluster, helmClient, operatorChartSpec, clusterChartSpec,
+					"the ceph daemons did not reach the new version in time")
+			}
+
+		case storagev1alpha1.UpgradeStepVerify:
+			failures := r.verifyUpgrade(ctx, koorCluster)
+			done = len(failures) == 0
+			if !done {
+				log.Info("The upgrade is not verified yet", "failures", failures)
+				if verificationTimedOut(koorCluster, time.Now()) {
+					r.failUpgrade(ctx, koorCluster, helmClient, operatorChartSpec, clusterChartSpec,
+						strings.Join(failures, "; "))
+				}
+			}
+
+		default:
+			log.Info("Unknown upgrade step, the upgrade is stopped", "step", upgrade.Step)
+			upgrade.Step = ""
+		}
+
+		if !done {
+			break
+		}
+		r.completeUpgradeStep(koorCluster)
+	}
+
+	setUpgradingCondition(koorCluster)
+	return nil
+}
+
+// completeUpgradeStep records the timing of the current step and starts the next one
+func (r *KoorClusterReconciler) completeUpgradeStep(koorCluster *storagev1alpha1.KoorCluster) {
+	upgrade := koorCluster.Status.Upgrade
+	now := metav1.Now()
+	next := -1
+	completed := 0
+	for i := range upgrade.Steps {
+		step := &upgrade.Steps[i]
+		if step.Name == upgrade.Step {
+			step.CompletedAt = &now
+			next = i + 1
+		}
+		if step.CompletedAt != nil {
+			completed++
+		}
+	}
+	upgrade.Progress = int32(completed * 100 / len(upgrade.Steps))
+
+	if next > 0 && next < len(upgrade.Steps) {
+		upgrade.Step = upgrade.Steps[next].Name
+		upgrade.Steps[next].StartedAt = &now
+		return
+	}
+
+	upgrade.Step = ""
+	upgrade.CompletedAt = &now
+	upgrade.Result = storagev1alpha1.UpgradeResultSucceeded
+	message := fmt.Sprintf("Upgraded from %s to %s in %s", upgrade.FromChartVersion, upgrade.ChartVersion,
+		now.Sub(upgrade.StartedAt.Time).Round(time.Second))
+	upgrade.Message = message
+	r.Recorder.Event(koorCluster, corev1.EventTypeNormal, "UpgradeCompleted", message)
+	meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionUpgradeVerified,
+		Status:  metav1.ConditionTrue,
+		Reason:  string(storagev1alpha1.UpgradeResultSucceeded),
+		Message: message,
+	})
+}
+
+func setUpgradingCondition(koorCluster *storagev1alpha1.KoorCluster) {
+	upgrade := koorCluster.Status.Upgrade
+	if upgrade.InProgress() {
+		meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionUpgrading,
+			Status:  metav1.ConditionTrue,
+			Reason:  string(upgrade.Step),
+			Message: fmt.Sprintf("Upgrading to %s, %d%% done", upgrade.ChartVersion, upgrade.Progress),
+		})
+		return
+	}
+	if upgrade.Result != storagev1alpha1.UpgradeResultSucceeded {
+		meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+			Type:    storagev1alpha1.ConditionUpgrading,
+			Status:  metav1.ConditionFalse,
+			Reason:  string(upgrade.Result),
+			Message: fmt.Sprintf("The upgrade to %s failed: %s", upgrade.ChartVersion, upgrade.Message),
+		})
+		return
+	}
+	meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionUpgrading,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Completed",
+		Message: fmt.Sprintf("Upgraded to %s", upgrade.ChartVersion),
+	})
+}
+
+// isRookOperatorReady returns true once the rook operator deployment rolled out and is available
+func (r *KoorClusterReconciler) isRookOperatorReady(ctx context.Context, namespace string) (bool, error) {
+	deployment := &appsv1.Deployment{}
+	key := types.NamespacedName{Name: rookOperatorName, Namespace: namespace}
+	if err := r.Get(ctx, key, deployment); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if isDeploymentRollingOut(deployment) {
+		return false, nil
+	}
+	return deployment.Status.Replicas > 0 && deployment.Status.AvailableReplicas == deployment.Status.Replicas, nil
+}
+
+// cephDaemonsUpgraded returns true once rook rolled out the ceph image of the CephCluster spec
+// and all daemons report the same version, which must match the pinned version if there is one
+func cephDaemonsUpgraded(cephCluster *unstructured.Unstructured, cephVersion string) bool {
+	specImage, _, _ := unstructured.NestedString(cephCluster.Object, "spec", "cephVersion", "image")
+	statusImage, _, _ := unstructured.NestedString(cephCluster.Object, "status", "version", "image")
+	if specImage == "" || specImage != statusImage {
+		return false
+	}
+
+	if cephVersion != "" {
+		return cephDaemonsOnVersion(cephCluster, cephVersion)
+	}
+	overall, _, _ := unstructured.NestedMap(cephCluster.Object, "status", "ceph", "versions", "overall")
+	return len(overall) == 1
+}
+
+// sameVersion compares versions with and without the v prefix
+func sameVersion(a, b string) bool {
+	return strings.TrimPrefix(a, "v") == strings.TrimPrefix(b, "v")
+}
@@ -0,0 +1,338 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	hc "github.com/mittwald/go-helm-client"
+	hcmock "github.com/mittwald/go-helm-client/mock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+)
+
+// expectInstall expects the release to be installed or upgraded with the chart version
+func expectInstall(helmClient *hcmock.MockClient, releaseName, version string) *gomock.Call {
+	return helmClient.EXPECT().InstallOrUpgradeChart(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, chartSpec *hc.ChartSpec, opts *hc.GenericHelmOptions) (*release.Release, error) {
+			Expect(chartSpec.ReleaseName).To(Equal(releaseName))
+			Expect(chartSpec.Version).To(Equal(version))
+			return &release.Release{Name: releaseName}, nil
+		})
+}
+
+// expectRelease expects the deployed chart version of the release to be read
+func expectRelease(helmClient *hcmock.MockClient, releaseName, version string) *gomock.Call {
+	return helmClient.EXPECT().GetRelease(releaseName).Return(&release.Release{
+		Name:  releaseName,
+		Chart: &chart.Chart{Metadata: &chart.Metadata{Version: version}},
+	}, nil)
+}
+
+// newCephCluster returns a CephCluster whose daemons all run the ceph image
+func newCephCluster(image, version string) *unstructured.Unstructured {
+	cephCluster := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{"cephVersion": map[string]any{"image": image}},
+		"status": map[string]any{
+			"version": map[string]any{"image": image},
+			"ceph": map[string]any{"versions": map[string]any{"overall": map[string]any{
+				version: int64(9),
+			}}},
+		},
+	}}
+	cephCluster.SetGroupVersionKind(cephClusterGVK)
+	cephCluster.SetName("rook-ceph")
+	cephCluster.SetNamespace("default")
+	return cephCluster
+}
+
+// newFakeClient returns a client that knows the rook CephCluster kind
+func newFakeClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(storagev1alpha1.AddToScheme(scheme)).To(Succeed())
+	scheme.AddKnownTypeWithName(cephClusterGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(cephClusterGVK.GroupVersion().WithKind(cephClusterGVK.Kind+"List"),
+		&unstructured.UnstructuredList{})
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+var _ = Describe("Staged upgrades", func() {
+	const (
+		deployedVersion = "v1.11.0"
+		targetVersion   = "v1.11.1"
+		cephImage       = "quay.io/ceph/ceph:v17.2.6"
+	)
+
+	var (
+		koorCluster       *storagev1alpha1.KoorCluster
+		mockHelmClient    *hcmock.MockClient
+		mockToolbox       *mocks.MockCephToolbox
+		operatorChartSpec *hc.ChartSpec
+		clusterChartSpec  *hc.ChartSpec
+	)
+
+	BeforeEach(func() {
+		mockCtrl := gomock.NewController(GinkgoT())
+		mockHelmClient = hcmock.NewMockClient(mockCtrl)
+		mockToolbox = mocks.NewMockCephToolbox(mockCtrl)
+		disabled := false
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "upgrade", Namespace: "default"},
+			Spec: storagev1alpha1.KoorClusterSpec{
+				UpgradeOptions: storagev1alpha1.UpgradeOptions{
+					Mode: storagev1alpha1.UpgradeModeUpgrade,
+					Preflight: storagev1alpha1.PreflightOptions{
+						RequireHealthOK:  &disabled,
+						RequireCleanPGs:  &disabled,
+						RequireAllOsdsUp: &disabled,
+					},
+				},
+			},
+			Status: storagev1alpha1.KoorClusterStatus{
+				CurrentVersions: storagev1alpha1.ProductVersions{Ksd: deployedVersion, Ceph: "v17.2.5"},
+				CephCluster:     &storagev1alpha1.CephClusterStatus{Health: storagev1alpha1.CephHealthOK},
+			},
+		}
+		operatorChartSpec = &hc.ChartSpec{ReleaseName: "ksd", ChartName: "koor-release/rook-ceph"}
+		clusterChartSpec = &hc.ChartSpec{ReleaseName: "ksd-cluster", ChartName: "koor-release/rook-ceph-cluster"}
+	})
+
+	reconcileUpgrade := func(c client.Client) {
+		// A new reconciler on every call, like after an operator restart
+		reconciler := &KoorClusterReconciler{Client: c, Recorder: record.NewFakeRecorder(10), toolbox: mockToolbox}
+		Expect(reconciler.reconcileUpgrade(context.Background(), koorCluster, mockHelmClient,
+			operatorChartSpec, clusterChartSpec)).To(Succeed())
+	}
+
+	It("Should upgrade the operator before ceph and resume after a restart", func() {
+		By("Upgrading the operator chart")
+		gomock.InOrder(
+			mockHelmClient.EXPECT().GetRelease("ksd").Return(&release.Release{
+				Chart: &chart.Chart{Metadata: &chart.Metadata{Version: deployedVersion}},
+			}, nil),
+			mockHelmClient.EXPECT().GetChart("koor-release/rook-ceph", gomock.Any()).Return(
+				&chart.Chart{Metadata: &chart.Metadata{Version: targetVersion}}, "", nil),
+			expectRelease(mockHelmClient, "ksd-cluster", deployedVersion),
+			expectInstall(mockHelmClient, "ksd", targetVersion),
+		)
+		reconcileUpgrade(newFakeClient())
+		upgrade := koorCluster.Status.Upgrade
+		Expect(upgrade.Step).To(Equal(storagev1alpha1.UpgradeStepWaitForOperator))
+		Expect(upgrade.Progress).To(Equal(int32(20)))
+		Expect(upgrade.Steps[0].CompletedAt).NotTo(BeNil())
+		Expect(meta.IsStatusConditionTrue(koorCluster.Status.Conditions, storagev1alpha1.ConditionUpgrading)).To(BeTrue())
+
+		By("Waiting for the rook operator")
+		reconcileUpgrade(newFakeClient())
+		Expect(koorCluster.Status.Upgrade.Step).To(Equal(storagev1alpha1.UpgradeStepWaitForOperator))
+
+		By("Upgrading the cluster chart once the rook operator is ready")
+		rookOperator := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: rookOperatorName, Namespace: "default"},
+			Status:     appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+		}
+		expectInstall(mockHelmClient, "ksd-cluster", targetVersion)
+		reconcileUpgrade(newFakeClient(rookOperator))
+		Expect(koorCluster.Status.Upgrade.Step).To(Equal(storagev1alpha1.UpgradeStepWaitForDaemons))
+		Expect(koorCluster.Status.Upgrade.Progress).To(Equal(int32(60)))
+
+		By("Completing once all daemons run the new version and the cluster is healthy")
+		cephCluster := newCephCluster(cephImage,
+			"ceph version 17.2.6 (d7ff0d10654d2280e08f1ab989c7cdf3064446a5) quincy (stable)")
+		reconcileUpgrade(newFakeClient(rookOperator, cephCluster))
+		upgrade = koorCluster.Status.Upgrade
+		Expect(upgrade.InProgress()).To(BeFalse())
+		Expect(upgrade.Result).To(Equal(storagev1alpha1.UpgradeResultSucceeded))
+		Expect(upgrade.Progress).To(Equal(int32(100)))
+		Expect(upgrade.CompletedAt).NotTo(BeNil())
+		for _, step := range upgrade.Steps {
+			Expect(step.StartedAt).NotTo(BeNil())
+			Expect(step.CompletedAt).NotTo(BeNil())
+		}
+		Expect(meta.IsStatusConditionFalse(koorCluster.Status.Conditions, storagev1alpha1.ConditionUpgrading)).To(BeTrue())
+		Expect(meta.IsStatusConditionTrue(koorCluster.Status.Conditions, storagev1alpha1.ConditionUpgradeVerified)).To(BeTrue())
+	})
+
+	It("Should upgrade the cluster chart when the status was lost after the operator upgrade", func() {
+		gomock.InOrder(
+			mockHelmClient.EXPECT().GetRelease("ksd").Return(&release.Release{
+				Chart: &chart.Chart{Metadata: &chart.Metadata{Version: targetVersion}},
+			}, nil),
+			mockHelmClient.EXPECT().GetChart("koor-release/rook-ceph", gomock.Any()).Return(
+				&chart.Chart{Metadata: &chart.Metadata{Version: targetVersion}}, "", nil),
+			expectRelease(mockHelmClient, "ksd-cluster", deployedVersion),
+			expectInstall(mockHelmClient, "ksd-cluster", targetVersion),
+		)
+		reconcileUpgrade(newFakeClient())
+		upgrade := koorCluster.Status.Upgrade
+		Expect(upgrade.FromChartVersion).To(Equal(deployedVersion))
+		Expect(upgrade.ChartVersion).To(Equal(targetVersion))
+		Expect(upgrade.Steps).To(HaveLen(3))
+		Expect(upgrade.Step).To(Equal(storagev1alpha1.UpgradeStepWaitForDaemons))
+	})
+
+	It("Should only upgrade ceph when the pinned ceph version changes", func() {
+		koorCluster.Spec.Versions = &storagev1alpha1.PinnedVersions{Ceph: "v17.2.6"}
+		gomock.InOrder(
+			mockHelmClient.EXPECT().GetRelease("ksd").Return(&release.Release{
+				Chart: &chart.Chart{Metadata: &chart.Metadata{Version: deployedVersion}},
+			}, nil),
+			mockHelmClient.EXPECT().GetChart("koor-release/rook-ceph", gomock.Any()).Return(
+				&chart.Chart{Metadata: &chart.Metadata{Version: deployedVersion}}, "", nil),
+			expectRelease(mockHelmClient, "ksd-cluster", deployedVersion),
+			expectInstall(mockHelmClient, "ksd-cluster", deployedVersion),
+		)
+		reconcileUpgrade(newFakeClient())
+		upgrade := koorCluster.Status.Upgrade
+		Expect(upgrade.CephVersion).To(Equal("v17.2.6"))
+		Expect(upgrade.Steps).To(HaveLen(3))
+		Expect(upgrade.Step).To(Equal(storagev1alpha1.UpgradeStepWaitForDaemons))
+	})
+
+	Context("When the verification fails", func() {
+		// verifying puts the upgrade into the Verify step, with the daemons upgraded an hour ago
+		verifying := func() {
+			started := metav1.NewTime(time.Now().Add(-time.Hour))
+			koorCluster.Spec.UpgradeOptions.Verification.Timeout = metav1.Duration{Duration: 30 * time.Minute}
+			koorCluster.Status.Upgrade = &storagev1alpha1.UpgradeStatus{
+				FromChartVersion: deployedVersion,
+				ChartVersion:     targetVersion,
+				FromCephVersion:  "v17.2.5",
+				Step:             storagev1alpha1.UpgradeStepVerify,
+				StartedAt:        started,
+				Steps: []storagev1alpha1.UpgradeStepStatus{
+					{Name: storagev1alpha1.UpgradeStepOperator, StartedAt: &started, CompletedAt: &started},
+					{Name: storagev1alpha1.UpgradeStepWaitForOperator, StartedAt: &started, CompletedAt: &started},
+					{Name: storagev1alpha1.UpgradeStepCeph, StartedAt: &started, CompletedAt: &started},
+					{Name: storagev1alpha1.UpgradeStepWaitForDaemons, StartedAt: &started, CompletedAt: &started},
+					{Name: storagev1alpha1.UpgradeStepVerify, StartedAt: &started},
+				},
+			}
+		}
+
+		It("Should keep verifying until the timeout", func() {
+			verifying()
+			koorCluster.Spec.UpgradeOptions.Verification.Timeout = metav1.Duration{Duration: 2 * time.Hour}
+			koorCluster.Status.CephCluster.Health = storagev1alpha1.CephHealthWarn
+			reconcileUpgrade(newFakeClient())
+			Expect(koorCluster.Status.Upgrade.Step).To(Equal(storagev1alpha1.UpgradeStepVerify))
+		})
+
+		It("Should time out after the default timeout if none is set", func() {
+			verifying()
+			koorCluster.Spec.UpgradeOptions.Verification.Timeout = metav1.Duration{}
+			koorCluster.Status.CephCluster.Health = storagev1alpha1.CephHealthWarn
+			reconcileUpgrade(newFakeClient())
+			upgrade := koorCluster.Status.Upgrade
+			Expect(upgrade.InProgress()).To(BeFalse())
+			Expect(upgrade.Result).To(Equal(storagev1alpha1.UpgradeResultFailed))
+			Expect(upgrade.Message).To(Equal("ceph health is HEALTH_WARN"))
+		})
+
+		It("Should pause and not retry the upgrade", func() {
+			verifying()
+			koorCluster.Spec.UpgradeOptions.Verification.SmokeTest.BlockPool = "replicapool"
+			mockToolbox.EXPECT().Exec(gomock.Any(), "default", nil, "sh", "-c", gomock.Any(), smokeTestName, "replicapool/"+smokeTestName).
+				Return("", errors.New("rbd: error opening pool 'replicapool'"))
+			reconcileUpgrade(newFakeClient())
+			upgrade := koorCluster.Status.Upgrade
+			Expect(upgrade.InProgress()).To(BeFalse())
+			Expect(upgrade.Result).To(Equal(storagev1alpha1.UpgradeResultFailed))
+			Expect(upgrade.Message).To(ContainSubstring("RBD smoke test failed"))
+			Expect(meta.IsStatusConditionFalse(koorCluster.Status.Conditions, storagev1alpha1.ConditionUpgradeVerified)).To(BeTrue())
+
+			By("Keeping the deployed versions")
+			gomock.InOrder(
+				mockHelmClient.EXPECT().GetRelease("ksd").Return(&release.Release{
+					Chart: &chart.Chart{Metadata: &chart.Metadata{Version: deployedVersion}},
+				}, nil),
+				mockHelmClient.EXPECT().GetChart("koor-release/rook-ceph", gomock.Any()).Return(
+					&chart.Chart{Metadata: &chart.Metadata{Version: targetVersion}}, "", nil),
+				expectRelease(mockHelmClient, "ksd-cluster", deployedVersion),
+				expectInstall(mockHelmClient, "ksd", deployedVersion),
+				mockHelmClient.EXPECT().GetRelease("ksd-cluster").Return(&release.Release{
+					Chart: &chart.Chart{Metadata: &chart.Metadata{Version: deployedVersion}},
+				}, nil),
+				expectInstall(mockHelmClient, "ksd-cluster", deployedVersion),
+			)
+			reconcileUpgrade(newFakeClient())
+			Expect(koorCluster.Status.Upgrade.InProgress()).To(BeFalse())
+		})
+
+		It("Should roll back while the daemons run the old ceph version", func() {
+			verifying()
+			koorCluster.Spec.UpgradeOptions.Verification.FailurePolicy = storagev1alpha1.VerificationFailurePolicyRollback
+			koorCluster.Status.CephCluster.Health = storagev1alpha1.CephHealthErr
+			gomock.InOrder(
+				mockHelmClient.EXPECT().RollbackRelease(clusterChartSpec).Return(nil),
+				mockHelmClient.EXPECT().RollbackRelease(operatorChartSpec).Return(nil),
+			)
+			reconcileUpgrade(newFakeClient(newCephCluster(cephImage, "ceph version 17.2.5 (hash) quincy (stable)")))
+			upgrade := koorCluster.Status.Upgrade
+			Expect(upgrade.Result).To(Equal(storagev1alpha1.UpgradeResultRolledBack))
+			Expect(upgrade.Message).To(Equal("ceph health is HEALTH_ERR"))
+		})
+
+		It("Should pause instead of rolling back upgraded ceph daemons", func() {
+			verifying()
+			koorCluster.Spec.UpgradeOptions.Verification.FailurePolicy = storagev1alpha1.VerificationFailurePolicyRollback
+			koorCluster.Status.CephCluster.Health = storagev1alpha1.CephHealthErr
+			reconcileUpgrade(newFakeClient(newCephCluster(cephImage, "ceph version 17.2.6 (hash) quincy (stable)")))
+			Expect(koorCluster.Status.Upgrade.Result).To(Equal(storagev1alpha1.UpgradeResultFailed))
+		})
+	})
+
+	DescribeTable("Should detect when the ceph daemons are upgraded",
+		func(statusImage string, versions map[string]any, cephVersion string, expected bool) {
+			cephCluster := &unstructured.Unstructured{Object: map[string]any{
+				"spec": map[string]any{"cephVersion": map[string]any{"image": cephImage}},
+				"status": map[string]any{
+					"version": map[string]any{"image": statusImage},
+					"ceph":    map[string]any{"versions": map[string]any{"overall": versions}},
+				},
+			}}
+			Expect(cephDaemonsUpgraded(cephCluster, cephVersion)).To(Equal(expected))
+		},
+		Entry("when rook did not roll out the image yet", "quay.io/ceph/ceph:v17.2.5",
+			map[string]any{"ceph version 17.2.5 (hash) quincy (stable)": int64(9)}, "", false),
+		Entry("while the daemons run mixed versions", cephImage,
+			map[string]any{"ceph version 17.2.5 (hash) quincy (stable)": int64(3),
+				"ceph version 17.2.6 (hash) quincy (stable)": int64(6)}, "", false),
+		Entry("when the daemons run another version", cephImage,
+			map[string]any{"ceph version 17.2.5 (hash) quincy (stable)": int64(9)}, "v17.2.6", false),
+		Entry("when the daemons run the pinned version", cephImage,
+			map[string]any{"ceph version 17.2.6 (hash) quincy (stable)": int64(9)}, "v17.2.6", true),
+	)
+})
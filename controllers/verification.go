@@ -0,0 +1,226 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	hc "github.com/mittwald/go-helm-client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+const (
+	// The name of the RBD image, the object store user and the bucket of the smoke tests
+	smokeTestName = "koor-smoke-test"
+	// The port of the object store services created by the rook-ceph-cluster chart
+	objectStorePort = 80
+)
+
+// verificationTimedOut returns true once the ceph daemons had more time than the verification timeout
+// to reach the new version and pass the checks
+func verificationTimedOut(koorCluster *storagev1alpha1.KoorCluster, now time.Time) bool {
+	timeout := koorCluster.Spec.UpgradeOptions.Verification.VerificationTimeout()
+	for _, step := range koorCluster.Status.Upgrade.Steps {
+		if step.Name == storagev1alpha1.UpgradeStepWaitForDaemons && step.StartedAt != nil {
+			return now.Sub(step.StartedAt.Time) > timeout
+		}
+	}
+	return false
+}
+
+// verifyUpgrade returns the reasons why the cluster is not healthy after the upgrade.
+// The smoke tests only run once ceph is healthy.
+func (r *KoorClusterReconciler) verifyUpgrade(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) []string {
+	options := koorCluster.Spec.UpgradeOptions.Verification
+	cephStatus := koorCluster.Status.CephCluster
+
+	if isEnabled(options.RequireHealthOK) && !cephStatus.IsHealthy() {
+		health := "unknown"
+		if cephStatus != nil && cephStatus.Health != "" {
+			health = string(cephStatus.Health)
+		}
+		return []string{fmt.Sprintf("ceph health is %s", health)}
+	}
+
+	var failures []string
+	if pool := options.SmokeTest.BlockPool; pool != "" {
+		if err := r.rbdSmokeTest(ctx, koorCluster.Namespace, pool); err != nil {
+			failures = append(failures, fmt.Sprintf("RBD smoke test failed: %s", err))
+		}
+	}
+	if objectStore := options.SmokeTest.ObjectStore; objectStore != "" {
+		if err := r.s3SmokeTest(ctx, koorCluster.Namespace, objectStore); err != nil {
+			failures = append(failures, fmt.Sprintf("S3 smoke test failed: %s", err))
+		}
+	}
+	return failures
+}
+
+// rbdSmokeTest writes random data to an RBD image and reads it back
+func (r *KoorClusterReconciler) rbdSmokeTest(ctx context.Context, namespace, pool string) error {
+	// The names are passed as arguments, so that they are not interpreted by the shell
+	const script = `set -e
+head -c 1048576 /dev/urandom > "/tmp/$0.in"
+rbd rm "$1" >/dev/null 2>&1 || true
+rbd import "/tmp/$0.in" "$1"
+rbd export "$1" "/tmp/$0.out"
+rc=0
+cmp "/tmp/$0.in" "/tmp/$0.out" || rc=$?
+rbd rm "$1"
+rm -f "/tmp/$0.in" "/tmp/$0.out"
+exit $rc`
+	image := fmt.Sprintf("%s/%s", pool, smokeTestName)
+	_, err := r.toolbox.Exec(ctx, namespace, nil, "sh", "-c", script, smokeTestName, image)
+	return err
+}
+
+// s3SmokeTest writes an object to the object store and reads it back
+func (r *KoorClusterReconciler) s3SmokeTest(ctx context.Context, namespace, objectStore string) error {
+	// rook names the realm, zone group and zone after the object store
+	scope := []string{"--rgw-realm=" + objectStore, "--rgw-zonegroup=" + objectStore, "--rgw-zone=" + objectStore}
+	output, err := r.toolbox.Exec(ctx, namespace, nil,
+		append([]string{"radosgw-admin", "user", "info", "--uid=" + smokeTestName}, scope...)...)
+	if err != nil {
+		output, err = r.toolbox.Exec(ctx, namespace, nil, append([]string{"radosgw-admin", "user", "create",
+			"--uid=" + smokeTestName, "--display-name=Koor Operator smoke test"}, scope...)...)
+		if err != nil {
+			return err
+		}
+	}
+
+	credentials, err := parseRGWUserCredentials(output)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("http://rook-ceph-rgw-%s.%s.svc:%d", objectStore, namespace, objectStorePort)
+	return r.s3.RoundTrip(ctx, endpoint, *credentials, smokeTestName)
+}
+
+// parseRGWUserCredentials reads the first S3 key of `radosgw-admin user info`
+func parseRGWUserCredentials(output string) (*utils.S3Credentials, error) {
+	var user struct {
+		Keys []struct {
+			AccessKey string `json:"access_key"`
+			SecretKey string `json:"secret_key"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal([]byte(output), &user); err != nil {
+		return nil, fmt.Errorf("parsing object store user failed: %w", err)
+	}
+	if len(user.Keys) == 0 {
+		return nil, fmt.Errorf("object store user %s has no S3 keys", smokeTestName)
+	}
+	return &utils.S3Credentials{AccessKey: user.Keys[0].AccessKey, SecretKey: user.Keys[0].SecretKey}, nil
+}
+
+// failUpgrade ends the upgrade according to the failure policy
+func (r *KoorClusterReconciler) failUpgrade(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	helmClient hc.Client,
+	operatorChartSpec *hc.ChartSpec,
+	clusterChartSpec *hc.ChartSpec,
+	reason string,
+) {
+	log := log.FromContext(ctx)
+	upgrade := koorCluster.Status.Upgrade
+	now := metav1.Now()
+	upgrade.Step = ""
+	upgrade.CompletedAt = &now
+	upgrade.Result = storagev1alpha1.UpgradeResultFailed
+	upgrade.Message = reason
+
+	message := fmt.Sprintf("Upgrade to %s failed the verification: %s", upgrade.ChartVersion, reason)
+	if koorCluster.Spec.UpgradeOptions.Verification.FailurePolicy == storagev1alpha1.VerificationFailurePolicyRollback {
+		if err := r.rollbackUpgrade(ctx, koorCluster, helmClient, operatorChartSpec, clusterChartSpec); err != nil {
+			log.Error(err, "Cannot roll back the upgrade")
+			message = fmt.Sprintf("%s. The upgrade was not rolled back: %s", message, err)
+		} else {
+			upgrade.Result = storagev1alpha1.UpgradeResultRolledBack
+			message = fmt.Sprintf("%s. Rolled back to %s", message, upgrade.FromChartVersion)
+		}
+	}
+
+	log.Info(message)
+	r.Recorder.Event(koorCluster, corev1.EventTypeWarning, "UpgradeVerificationFailed", message)
+	meta.SetStatusCondition(&koorCluster.Status.Conditions, metav1.Condition{
+		Type:    storagev1alpha1.ConditionUpgradeVerified,
+		Status:  metav1.ConditionFalse,
+		Reason:  string(upgrade.Result),
+		Message: message,
+	})
+}
+
+// rollbackUpgrade rolls back the releases that were upgraded. Ceph does not support downgrades,
+// so the cluster chart is only rolled back while no daemon runs a new ceph version.
+func (r *KoorClusterReconciler) rollbackUpgrade(
+	ctx context.Context,
+	koorCluster *storagev1alpha1.KoorCluster,
+	helmClient hc.Client,
+	operatorChartSpec *hc.ChartSpec,
+	clusterChartSpec *hc.ChartSpec,
+) error {
+	upgrade := koorCluster.Status.Upgrade
+	completed := map[storagev1alpha1.UpgradeStep]bool{}
+	for _, step := range upgrade.Steps {
+		completed[step.Name] = step.CompletedAt != nil
+	}
+
+	if completed[storagev1alpha1.UpgradeStepCeph] {
+		cephCluster, err := r.getCephCluster(ctx, koorCluster.Namespace)
+		if err != nil {
+			return err
+		}
+		if cephCluster == nil || upgrade.FromCephVersion == "" ||
+			!cephDaemonsOnVersion(cephCluster, upgrade.FromCephVersion) {
+			return fmt.Errorf("ceph daemons may already run a new version, which cannot be downgraded")
+		}
+		if err := helmClient.RollbackRelease(clusterChartSpec); err != nil {
+			return fmt.Errorf("rolling back %s failed: %w", clusterChartSpec.ReleaseName, err)
+		}
+	}
+	if completed[storagev1alpha1.UpgradeStepOperator] {
+		if err := helmClient.RollbackRelease(operatorChartSpec); err != nil {
+			return fmt.Errorf("rolling back %s failed: %w", operatorChartSpec.ReleaseName, err)
+		}
+	}
+	return nil
+}
+
+// cephDaemonsOnVersion returns true if all ceph daemons report the version
+func cephDaemonsOnVersion(cephCluster *unstructured.Unstructured, version string) bool {
+	overall, _, _ := unstructured.NestedMap(cephCluster.Object, "status", "ceph", "versions", "overall")
+	if len(overall) != 1 {
+		return false
+	}
+	for daemonVersion := range overall {
+		// For example: ceph version 17.2.6 (d7ff0d10654d2280e08f1ab989c7cdf3064446a5) quincy (stable)
+		return strings.Contains(daemonVersion, " "+strings.TrimPrefix(version, "v")+" ")
+	}
+	return false
+}
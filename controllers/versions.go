@@ -0,0 +1,264 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+const (
+	// The name of the rook operator deployment and its container, as created by the rook-ceph chart
+	rookOperatorName = "rook-ceph-operator"
+)
+
+// reconcileVersions reads the running KSD and ceph versions from the rook operator deployment
+// and the CephCluster status.
+func (r *KoorClusterReconciler) reconcileVersions(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) error {
+	log := log.FromContext(ctx)
+	mixedVersions := false
+
+	deployment := &appsv1.Deployment{}
+	key := types.NamespacedName{Name: rookOperatorName, Namespace: koorCluster.Namespace}
+	err := r.Get(ctx, key, deployment)
+	switch {
+	case k8serrors.IsNotFound(err):
+		log.Info("Rook operator deployment not found")
+	case err != nil:
+		log.Error(err, "unable to fetch rook operator deployment")
+		return err
+	default:
+		ksdVersion, err := getKSDVersion(deployment)
+		if err != nil {
+			log.Error(err, "Could not find KSD version")
+		} else {
+			log.Info("Found KSD version", "ksdVersion", ksdVersion)
+			koorCluster.Status.CurrentVersions.Ksd = ksdVersion
+		}
+		mixedVersions = mixedVersions || isDeploymentRollingOut(deployment)
+	}
+
+	cephCluster, err := r.getCephCluster(ctx, koorCluster.Namespace)
+	if err != nil {
+		log.Error(err, "unable to fetch CephCluster")
+		return err
+	}
+	if cephCluster == nil {
+		log.Info("CephCluster not found")
+	} else {
+		cephVersion, err := getCephVersion(cephCluster)
+		if err != nil {
+			log.Error(err, "Could not find ceph version")
+		} else {
+			log.Info("Found ceph version", "cephVersion", cephVersion)
+			koorCluster.Status.CurrentVersions.Ceph = cephVersion
+		}
+		koorCluster.Status.CephImage = getCephImage(cephCluster)
+		mixedVersions = mixedVersions || hasMixedCephVersions(cephCluster)
+	}
+
+	koorCluster.Status.MixedVersions = mixedVersions
+	return nil
+}
+
+// getKSDVersion returns the image version of the rook operator container
+func getKSDVersion(deployment *appsv1.Deployment) (string, error) {
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return "", fmt.Errorf("Deployment %s has no containers", deployment.Name)
+	}
+
+	image := containers[0].Image
+	for _, container := range containers {
+		if container.Name == rookOperatorName {
+			image = container.Image
+		}
+	}
+
+	parsed, err := utils.ParseImage(image)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Version() == "" {
+		return "", fmt.Errorf("Rook operator image %s has no tag or digest", image)
+	}
+	return parsed.Version(), nil
+}
+
+// isDeploymentRollingOut returns true while some replicas still run an old pod template
+func isDeploymentRollingOut(deployment *appsv1.Deployment) bool {
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return true
+	}
+	return deployment.Status.UpdatedReplicas < deployment.Status.Replicas
+}
+
+// getCephVersion returns the version of the ceph image reported in the CephCluster status
+func getCephVersion(cephCluster *unstructured.Unstructured) (string, error) {
+	image, found, err := unstructured.NestedString(cephCluster.Object, "status", "version", "image")
+	if err != nil {
+		return "", err
+	}
+	if found && image != "" {
+		parsed, err := utils.ParseImage(image)
+		if err != nil {
+			return "", err
+		}
+		if parsed.Tag != "" {
+			return parsed.Tag, nil
+		}
+	}
+
+	// The image is pinned by digest only, use the version reported by the daemons
+	version, found, err := unstructured.NestedString(cephCluster.Object, "status", "version", "version")
+	if err != nil {
+		return "", err
+	}
+	if !found || version == "" {
+		return "", fmt.Errorf("CephCluster %s does not report a version yet", cephCluster.GetName())
+	}
+	return version, nil
+}
+
+// getCephImage returns the ceph image the CephCluster runs, or the image of its spec until rook reports one
+func getCephImage(cephCluster *unstructured.Unstructured) string {
+	if image, _, _ := unstructured.NestedString(cephCluster.Object, "status", "version", "image"); image != "" {
+		return image
+	}
+	image, _, _ := unstructured.NestedString(cephCluster.Object, "spec", "cephVersion", "image")
+	return image
+}
+
+// hasMixedCephVersions returns true if the ceph daemons report more than one version
+func hasMixedCephVersions(cephCluster *unstructured.Unstructured) bool {
+	overall, found, err := unstructured.NestedMap(cephCluster.Object, "status", "ceph", "versions", "overall")
+	if err != nil || !found {
+		return false
+	}
+	return len(overall) > 1
+}
+
+// versionServiceConfig returns the connection to the version service from the upgrade options
+func (r *KoorClusterReconciler) versionServiceConfig(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) (utils.VersionServiceConfig, error) {
+	options := koorCluster.Spec.UpgradeOptions.VersionService
+	config := utils.VersionServiceConfig{
+		Endpoint: koorCluster.Spec.UpgradeOptions.Endpoint,
+		Timeout:  options.Timeout.Duration,
+		Retries:  3,
+	}
+	if options.Retries != nil {
+		config.Retries = int(*options.Retries)
+	}
+	if options.ProxyURL != "" {
+		proxy, err := url.Parse(options.ProxyURL)
+		if err != nil {
+			return config, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		config.Proxy = proxy
+	}
+	if options.TLSSecretName != "" {
+		secret := &corev1.Secret{}
+		key := types.NamespacedName{Name: options.TLSSecretName, Namespace: koorCluster.Namespace}
+		if err := r.Get(ctx, key, secret); err != nil {
+			return config, fmt.Errorf("cannot read the TLS Secret of the version service: %w", err)
+		}
+		config.CABundle = secret.Data[corev1.ServiceAccountRootCAKey]
+		config.ClientCert = secret.Data[corev1.TLSCertKey]
+		config.ClientKey = secret.Data[corev1.TLSPrivateKeyKey]
+	}
+
+	if options.AllowUnsigned {
+		config.AllowUnsigned = true
+		return config, nil
+	}
+	publicKey := []byte(options.PublicKey)
+	if options.PublicKeySecret != nil {
+		value, err := r.secretValue(ctx, koorCluster.Namespace, options.PublicKeySecret)
+		if err != nil {
+			return config, fmt.Errorf("cannot read the public key of the version service: %w", err)
+		}
+		publicKey = []byte(value)
+	}
+	if len(publicKey) == 0 {
+		return config, fmt.Errorf("%w: set publicKey or publicKeySecret, or allowUnsigned, in spec.upgradeOptions.versionService",
+			utils.ErrUnverified)
+	}
+	key, err := utils.ParsePublicKey(publicKey)
+	if err != nil {
+		return config, err
+	}
+	config.PublicKey = key
+	return config, nil
+}
+
+// The keys of the offline catalog ConfigMap
+const (
+	catalogKey          = "catalog.json"
+	catalogSignatureKey = "catalog.json.sig"
+)
+
+// fetchLatestVersions returns the latest versions from the offline catalog or else from the version service.
+// Both must be signed with the public key unless unsigned versions are allowed.
+func (r *KoorClusterReconciler) fetchLatestVersions(ctx context.Context, koorCluster *storagev1alpha1.KoorCluster) (*storagev1alpha1.DetailedProductVersions, error) {
+	config, err := r.versionServiceConfig(ctx, koorCluster)
+	if err != nil {
+		return nil, err
+	}
+	catalogName := koorCluster.Spec.UpgradeOptions.VersionService.CatalogConfigMap
+	if catalogName == "" {
+		return r.vs.LatestVersions(ctx, config, &koorCluster.Status.CurrentVersions)
+	}
+
+	catalog := &corev1.ConfigMap{}
+	key := types.NamespacedName{Name: catalogName, Namespace: koorCluster.Namespace}
+	if err := r.Get(ctx, key, catalog); err != nil {
+		return nil, fmt.Errorf("cannot read the catalog: %w", err)
+	}
+	data, ok := catalog.Data[catalogKey]
+	if !ok {
+		return nil, fmt.Errorf("the ConfigMap %s has no key %s", catalogName, catalogKey)
+	}
+	return utils.ParseCatalog([]byte(data), catalog.Data[catalogSignatureKey], config)
+}
+
+// verifyCephImage checks that a pinned ceph image of the latest ceph version has the digest of the version service
+func verifyCephImage(koorCluster *storagev1alpha1.KoorCluster) error {
+	latest := koorCluster.Status.LatestVersions
+	if latest == nil || latest.Ceph == nil {
+		return nil
+	}
+	image, err := koorCluster.Spec.Versions.CephImage()
+	if err != nil || image == "" {
+		return err
+	}
+	version, err := koorCluster.Spec.Versions.CephVersion()
+	if err != nil || !sameVersion(version, latest.Ceph.Version) {
+		return err
+	}
+	return latest.Ceph.VerifyImageDigest(image)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	storagev1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	"github.com/koor-tech/koor-operator/mocks"
+	"github.com/koor-tech/koor-operator/utils"
+)
+
+var _ = Describe("Signed versions", func() {
+	const (
+		catalog   = `{"versions": {"ceph": {"version": "v18.2.0", "imageUri": "quay.io/ceph/ceph:v18.2.0"}}}`
+		imageHash = "0f6fd0e0a06ecab1bc8ed8bd2e4a7c8d10ec4d1d7ca7fd8c79c01c5c7bd7b4a5"
+	)
+
+	var (
+		koorCluster *storagev1alpha1.KoorCluster
+		mockVs      *mocks.MockVersionService
+		privateKey  ed25519.PrivateKey
+		publicKey   string
+	)
+
+	BeforeEach(func() {
+		mockVs = mocks.NewMockVersionService(gomock.NewController(GinkgoT()))
+		var key ed25519.PublicKey
+		var err error
+		key, privateKey, err = ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalPKIXPublicKey(key)
+		Expect(err).NotTo(HaveOccurred())
+		publicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+		koorCluster = &storagev1alpha1.KoorCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "signed", Namespace: "default"},
+			Spec: storagev1alpha1.KoorClusterSpec{
+				UpgradeOptions: storagev1alpha1.UpgradeOptions{
+					VersionService: storagev1alpha1.VersionServiceOptions{PublicKey: publicKey},
+				},
+			},
+		}
+	})
+
+	newCatalog := func(signature string) *corev1.ConfigMap {
+		data := map[string]string{catalogKey: catalog}
+		if signature != "" {
+			data[catalogSignatureKey] = signature
+		}
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "catalog", Namespace: "default"},
+			Data:       data,
+		}
+	}
+
+	fetch := func(catalog *corev1.ConfigMap) (*storagev1alpha1.DetailedProductVersions, error) {
+		koorCluster.Spec.UpgradeOptions.VersionService.CatalogConfigMap = catalog.Name
+		reconciler := &KoorClusterReconciler{Client: newFakeClient(catalog), Recorder: record.NewFakeRecorder(10), vs: mockVs}
+		return reconciler.fetchLatestVersions(context.Background(), koorCluster)
+	}
+
+	It("Should read signed catalogs without calling the version service", func() {
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(catalog)))
+		versions, err := fetch(newCatalog(signature))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions.Ceph.Version).To(Equal("v18.2.0"))
+	})
+
+	It("Should reject unsigned catalogs", func() {
+		_, err := fetch(newCatalog(""))
+		Expect(err).To(MatchError(utils.ErrUnverified))
+	})
+
+	It("Should pass the public key to the version service", func() {
+		mockVs.EXPECT().LatestVersions(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, config utils.VersionServiceConfig, versions *storagev1alpha1.ProductVersions) (*storagev1alpha1.DetailedProductVersions, error) {
+				Expect(config.PublicKey).NotTo(BeNil())
+				return nil, utils.ErrUnverified
+			})
+		reconciler := &KoorClusterReconciler{Client: newFakeClient(), Recorder: record.NewFakeRecorder(10), vs: mockVs}
+		_, err := reconciler.fetchLatestVersions(context.Background(), koorCluster)
+		Expect(err).To(MatchError(utils.ErrUnverified))
+	})
+
+	It("Should require a public key unless unsigned versions are allowed", func() {
+		koorCluster.Spec.UpgradeOptions.VersionService.PublicKey = ""
+		reconciler := &KoorClusterReconciler{Client: newFakeClient(), Recorder: record.NewFakeRecorder(10), vs: mockVs}
+		_, err := reconciler.fetchLatestVersions(context.Background(), koorCluster)
+		Expect(err).To(MatchError(utils.ErrUnverified))
+		Expect(err).To(MatchError(ContainSubstring("set publicKey or publicKeySecret, or allowUnsigned")))
+
+		_, err = fetch(newCatalog(base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(catalog)))))
+		Expect(err).To(MatchError(utils.ErrUnverified))
+	})
+
+	It("Should accept unsigned catalogs only if unsigned versions are allowed", func() {
+		koorCluster.Spec.UpgradeOptions.VersionService = storagev1alpha1.VersionServiceOptions{AllowUnsigned: true}
+		versions, err := fetch(newCatalog(""))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions.Ceph.Version).To(Equal("v18.2.0"))
+	})
+
+	DescribeTable("Should check the digest of the latest ceph image",
+		func(pinned string, matches bool) {
+			koorCluster.Spec.Versions = &storagev1alpha1.PinnedVersions{Ceph: pinned}
+			koorCluster.Status.LatestVersions = &storagev1alpha1.DetailedProductVersions{
+				Ceph: &storagev1alpha1.DetailedVersion{Version: "v18.2.0", ImageHash: imageHash},
+			}
+			if matches {
+				Expect(verifyCephImage(koorCluster)).To(Succeed())
+			} else {
+				Expect(verifyCephImage(koorCluster)).To(MatchError(ContainSubstring("does not match the hash")))
+			}
+		},
+		Entry("without a digest", "v18.2.0", true),
+		Entry("with the same digest", "v18.2.0@sha256:"+imageHash, true),
+		Entry("with a different digest", "v18.2.0@sha256:"+imageHash[1:]+"0", false),
+		Entry("of another version", "v17.2.6@sha256:"+imageHash[1:]+"0", true),
+	)
+})
@@ -103,6 +103,26 @@ func main() {
 		setupLog.Error(err, "unable to create webhook", "webhook", "KoorCluster")
 		os.Exit(1)
 	}
+	if err = controllers.NewKoorMaintenanceReconciler(mgr).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KoorMaintenance")
+		os.Exit(1)
+	}
+	if err = controllers.NewKoorNodeDecommissionReconciler(mgr).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KoorNodeDecommission")
+		os.Exit(1)
+	}
+	if err = controllers.NewKoorOsdReplacementReconciler(mgr).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KoorOsdReplacement")
+		os.Exit(1)
+	}
+	if err = controllers.NewKoorSnapshotScheduleReconciler(mgr).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KoorSnapshotSchedule")
+		os.Exit(1)
+	}
+	if err = controllers.NewKoorCephCommandReconciler(mgr).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KoorCephCommand")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
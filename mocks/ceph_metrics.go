@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: utils/ceph_metrics.go
+//
+// Generated by this command:
+//
+//	mockgen -source=utils/ceph_metrics.go -package mocks -destination=./mocks/ceph_metrics.go -self_package=. CephMetrics
+//
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	utils "github.com/koor-tech/koor-operator/utils"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCephMetrics is a mock of CephMetrics interface.
+type MockCephMetrics struct {
+	ctrl     *gomock.Controller
+	recorder *MockCephMetricsMockRecorder
+}
+
+// MockCephMetricsMockRecorder is the mock recorder for MockCephMetrics.
+type MockCephMetricsMockRecorder struct {
+	mock *MockCephMetrics
+}
+
+// NewMockCephMetrics creates a new mock instance.
+func NewMockCephMetrics(ctrl *gomock.Controller) *MockCephMetrics {
+	mock := &MockCephMetrics{ctrl: ctrl}
+	mock.recorder = &MockCephMetricsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCephMetrics) EXPECT() *MockCephMetricsMockRecorder {
+	return m.recorder
+}
+
+// Usage mocks base method.
+func (m *MockCephMetrics) Usage(ctx context.Context, endpoint string) (*utils.CephUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Usage", ctx, endpoint)
+	ret0, _ := ret[0].(*utils.CephUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Usage indicates an expected call of Usage.
+func (mr *MockCephMetricsMockRecorder) Usage(ctx, endpoint any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Usage", reflect.TypeOf((*MockCephMetrics)(nil).Usage), ctx, endpoint)
+}
@@ -0,0 +1,60 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: utils/ceph_toolbox.go
+//
+// Generated by this command:
+//
+//	mockgen -source=utils/ceph_toolbox.go -package mocks -destination=./mocks/ceph_toolbox.go -self_package=. CephToolbox
+//
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	io "io"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCephToolbox is a mock of CephToolbox interface.
+type MockCephToolbox struct {
+	ctrl     *gomock.Controller
+	recorder *MockCephToolboxMockRecorder
+}
+
+// MockCephToolboxMockRecorder is the mock recorder for MockCephToolbox.
+type MockCephToolboxMockRecorder struct {
+	mock *MockCephToolbox
+}
+
+// NewMockCephToolbox creates a new mock instance.
+func NewMockCephToolbox(ctrl *gomock.Controller) *MockCephToolbox {
+	mock := &MockCephToolbox{ctrl: ctrl}
+	mock.recorder = &MockCephToolboxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCephToolbox) EXPECT() *MockCephToolboxMockRecorder {
+	return m.recorder
+}
+
+// Exec mocks base method.
+func (m *MockCephToolbox) Exec(ctx context.Context, namespace string, stdin io.Reader, command ...string) (string, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, namespace, stdin}
+	for _, a := range command {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Exec", varargs...)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exec indicates an expected call of Exec.
+func (mr *MockCephToolboxMockRecorder) Exec(ctx, namespace, stdin any, command ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, namespace, stdin}, command...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*MockCephToolbox)(nil).Exec), varargs...)
+}
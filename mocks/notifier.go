@@ -0,0 +1,96 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: utils/notifier.go
+//
+// Generated by this command:
+//
+//	mockgen -source=utils/notifier.go -package mocks -destination=./mocks/notifier.go -self_package=. Notifier
+//
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	utils "github.com/koor-tech/koor-operator/utils"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockNotifier is a mock of Notifier interface.
+type MockNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotifierMockRecorder
+}
+
+// MockNotifierMockRecorder is the mock recorder for MockNotifier.
+type MockNotifierMockRecorder struct {
+	mock *MockNotifier
+}
+
+// NewMockNotifier creates a new mock instance.
+func NewMockNotifier(ctrl *gomock.Controller) *MockNotifier {
+	mock := &MockNotifier{ctrl: ctrl}
+	mock.recorder = &MockNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotifier) EXPECT() *MockNotifierMockRecorder {
+	return m.recorder
+}
+
+// Alertmanager mocks base method.
+func (m *MockNotifier) Alertmanager(ctx context.Context, url string, notification *utils.Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Alertmanager", ctx, url, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Alertmanager indicates an expected call of Alertmanager.
+func (mr *MockNotifierMockRecorder) Alertmanager(ctx, url, notification any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Alertmanager", reflect.TypeOf((*MockNotifier)(nil).Alertmanager), ctx, url, notification)
+}
+
+// Email mocks base method.
+func (m *MockNotifier) Email(ctx context.Context, server utils.SMTPServer, from string, to []string, notification *utils.Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Email", ctx, server, from, to, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Email indicates an expected call of Email.
+func (mr *MockNotifierMockRecorder) Email(ctx, server, from, to, notification any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Email", reflect.TypeOf((*MockNotifier)(nil).Email), ctx, server, from, to, notification)
+}
+
+// Slack mocks base method.
+func (m *MockNotifier) Slack(ctx context.Context, url, channel string, notification *utils.Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Slack", ctx, url, channel, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Slack indicates an expected call of Slack.
+func (mr *MockNotifierMockRecorder) Slack(ctx, url, channel, notification any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Slack", reflect.TypeOf((*MockNotifier)(nil).Slack), ctx, url, channel, notification)
+}
+
+// Webhook mocks base method.
+func (m *MockNotifier) Webhook(ctx context.Context, url string, notification *utils.Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Webhook", ctx, url, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Webhook indicates an expected call of Webhook.
+func (mr *MockNotifierMockRecorder) Webhook(ctx, url, notification any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Webhook", reflect.TypeOf((*MockNotifier)(nil).Webhook), ctx, url, notification)
+}
@@ -0,0 +1,54 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: utils/s3.go
+//
+// Generated by this command:
+//
+//	mockgen -source=utils/s3.go -package mocks -destination=./mocks/s3.go -self_package=. S3Client
+//
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	utils "github.com/koor-tech/koor-operator/utils"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockS3Client is a mock of S3Client interface.
+type MockS3Client struct {
+	ctrl     *gomock.Controller
+	recorder *MockS3ClientMockRecorder
+}
+
+// MockS3ClientMockRecorder is the mock recorder for MockS3Client.
+type MockS3ClientMockRecorder struct {
+	mock *MockS3Client
+}
+
+// NewMockS3Client creates a new mock instance.
+func NewMockS3Client(ctrl *gomock.Controller) *MockS3Client {
+	mock := &MockS3Client{ctrl: ctrl}
+	mock.recorder = &MockS3ClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockS3Client) EXPECT() *MockS3ClientMockRecorder {
+	return m.recorder
+}
+
+// RoundTrip mocks base method.
+func (m *MockS3Client) RoundTrip(ctx context.Context, endpoint string, credentials utils.S3Credentials, bucket string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RoundTrip", ctx, endpoint, credentials, bucket)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RoundTrip indicates an expected call of RoundTrip.
+func (mr *MockS3ClientMockRecorder) RoundTrip(ctx, endpoint, credentials, bucket any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RoundTrip", reflect.TypeOf((*MockS3Client)(nil).RoundTrip), ctx, endpoint, credentials, bucket)
+}
@@ -13,6 +13,7 @@ import (
 	reflect "reflect"
 
 	v1alpha1 "github.com/koor-tech/koor-operator/api/v1alpha1"
+	utils "github.com/koor-tech/koor-operator/utils"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,16 +41,16 @@ func (m *MockVersionService) EXPECT() *MockVersionServiceMockRecorder {
 }
 
 // LatestVersions mocks base method.
-func (m *MockVersionService) LatestVersions(ctx context.Context, endpoint string, versions *v1alpha1.ProductVersions) (*v1alpha1.DetailedProductVersions, error) {
+func (m *MockVersionService) LatestVersions(ctx context.Context, config utils.VersionServiceConfig, versions *v1alpha1.ProductVersions) (*v1alpha1.DetailedProductVersions, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LatestVersions", ctx, endpoint, versions)
+	ret := m.ctrl.Call(m, "LatestVersions", ctx, config, versions)
 	ret0, _ := ret[0].(*v1alpha1.DetailedProductVersions)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // LatestVersions indicates an expected call of LatestVersions.
-func (mr *MockVersionServiceMockRecorder) LatestVersions(ctx, endpoint, versions any) *gomock.Call {
+func (mr *MockVersionServiceMockRecorder) LatestVersions(ctx, config, versions any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestVersions", reflect.TypeOf((*MockVersionService)(nil).LatestVersions), ctx, endpoint, versions)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestVersions", reflect.TypeOf((*MockVersionService)(nil).LatestVersions), ctx, config, versions)
 }
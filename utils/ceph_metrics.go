@@ -0,0 +1,162 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	koapi "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// CephUsage is the storage usage scraped from the ceph mgr prometheus module
+type CephUsage struct {
+	Pools []koapi.CephPoolUsage
+	Osds  *koapi.CephOsdCounts
+	Pgs   *koapi.CephPgCounts
+}
+
+type CephMetrics interface {
+	Usage(ctx context.Context, endpoint string) (*CephUsage, error)
+}
+
+func NewCephMetricsClient() CephMetrics {
+	return &cephMetricsClient{
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type cephMetricsClient struct {
+	client *http.Client
+}
+
+func (mc *cephMetricsClient) Usage(ctx context.Context, endpoint string) (*CephUsage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := mc.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to endpoint %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("endpoint %s returned %s", endpoint, resp.Status)
+	}
+	return ParseCephUsage(resp.Body)
+}
+
+// ParseCephUsage reads the pool and OSD metrics from the prometheus text format
+func ParseCephUsage(in io.Reader) (*CephUsage, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(in)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics failed: %w", err)
+	}
+
+	// Pool metrics only carry the pool id, the name is in the metadata metric
+	pools := map[string]*koapi.CephPoolUsage{}
+	for _, m := range families["ceph_pool_metadata"].GetMetric() {
+		pools[label(m, "pool_id")] = &koapi.CephPoolUsage{Name: label(m, "name")}
+	}
+	for id, pool := range pools {
+		pool.Stored = poolQuantity(families["ceph_pool_stored"], id)
+		pool.MaxAvailable = poolQuantity(families["ceph_pool_max_avail"], id)
+		if quota := poolQuantity(families["ceph_pool_quota_bytes"], id); quota != nil && !quota.IsZero() {
+			pool.Quota = quota
+		}
+	}
+
+	usage := &CephUsage{}
+	for _, pool := range pools {
+		usage.Pools = append(usage.Pools, *pool)
+	}
+	sort.Slice(usage.Pools, func(i, j int) bool {
+		return usage.Pools[i].Name < usage.Pools[j].Name
+	})
+
+	if osdUp, ok := families["ceph_osd_up"]; ok {
+		usage.Osds = &koapi.CephOsdCounts{}
+		for _, m := range osdUp.GetMetric() {
+			usage.Osds.Total++
+			if value(m) == 1 {
+				usage.Osds.Up++
+			}
+		}
+		for _, m := range families["ceph_osd_in"].GetMetric() {
+			if value(m) == 1 {
+				usage.Osds.In++
+			}
+		}
+	}
+
+	// PG metrics are reported per pool
+	if pgTotal, ok := families["ceph_pg_total"]; ok {
+		usage.Pgs = &koapi.CephPgCounts{
+			Total:       sum(pgTotal),
+			Degraded:    sum(families["ceph_pg_degraded"]),
+			Backfilling: sum(families["ceph_pg_backfilling"]),
+		}
+	}
+
+	return usage, nil
+}
+
+func label(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// value returns the sample value, the ceph mgr exports both gauges and untyped metrics
+func value(m *dto.Metric) float64 {
+	switch {
+	case m.GetGauge() != nil:
+		return m.GetGauge().GetValue()
+	case m.GetCounter() != nil:
+		return m.GetCounter().GetValue()
+	}
+	return m.GetUntyped().GetValue()
+}
+
+func sum(family *dto.MetricFamily) int32 {
+	var result int32
+	for _, m := range family.GetMetric() {
+		result += int32(value(m))
+	}
+	return result
+}
+
+func poolQuantity(family *dto.MetricFamily, poolID string) *resource.Quantity {
+	for _, m := range family.GetMetric() {
+		if label(m, "pool_id") == poolID {
+			return resource.NewQuantity(int64(value(m)), resource.BinarySI)
+		}
+	}
+	return nil
+}
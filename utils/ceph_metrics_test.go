@@ -0,0 +1,100 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	koapi "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+var _ = Describe("ParseCephUsage", func() {
+	const metrics = `# HELP ceph_pool_metadata POOL Metadata
+# TYPE ceph_pool_metadata untyped
+ceph_pool_metadata{pool_id="1",name=".mgr",type="replicated",description="replica:3",compression_mode="none"} 1.0
+ceph_pool_metadata{pool_id="2",name="replicapool",type="replicated",description="replica:3",compression_mode="none"} 1.0
+# HELP ceph_pool_stored DF pool stored
+# TYPE ceph_pool_stored gauge
+ceph_pool_stored{pool_id="1"} 1048576.0
+ceph_pool_stored{pool_id="2"} 858993459.0
+# HELP ceph_pool_max_avail DF pool max_avail
+# TYPE ceph_pool_max_avail gauge
+ceph_pool_max_avail{pool_id="1"} 10737418240.0
+ceph_pool_max_avail{pool_id="2"} 10737418240.0
+# HELP ceph_pool_quota_bytes DF pool quota_bytes
+# TYPE ceph_pool_quota_bytes gauge
+ceph_pool_quota_bytes{pool_id="1"} 0.0
+ceph_pool_quota_bytes{pool_id="2"} 1073741824.0
+# HELP ceph_osd_up OSD status up
+# TYPE ceph_osd_up untyped
+ceph_osd_up{ceph_daemon="osd.0"} 1.0
+ceph_osd_up{ceph_daemon="osd.1"} 1.0
+ceph_osd_up{ceph_daemon="osd.2"} 0.0
+# HELP ceph_osd_in OSD status in
+# TYPE ceph_osd_in untyped
+ceph_osd_in{ceph_daemon="osd.0"} 1.0
+ceph_osd_in{ceph_daemon="osd.1"} 0.0
+ceph_osd_in{ceph_daemon="osd.2"} 0.0
+# HELP ceph_pg_total PG Total Count per Pool
+# TYPE ceph_pg_total gauge
+ceph_pg_total{pool_id="1"} 1.0
+ceph_pg_total{pool_id="2"} 32.0
+# HELP ceph_pg_degraded PG degraded per pool
+# TYPE ceph_pg_degraded gauge
+ceph_pg_degraded{pool_id="1"} 0.0
+ceph_pg_degraded{pool_id="2"} 4.0
+# HELP ceph_pg_backfilling PG backfilling per pool
+# TYPE ceph_pg_backfilling gauge
+ceph_pg_backfilling{pool_id="1"} 0.0
+ceph_pg_backfilling{pool_id="2"} 2.0
+`
+
+	It("Should read pool usage and osd counts", func() {
+		usage, err := ParseCephUsage(strings.NewReader(metrics))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(usage.Pools).To(HaveLen(2))
+		Expect(usage.Pools[0].Name).To(Equal(".mgr"))
+		Expect(usage.Pools[0].Stored.Equal(resource.MustParse("1Mi"))).To(BeTrue())
+		Expect(usage.Pools[0].Quota).To(BeNil())
+		Expect(usage.Pools[1].Name).To(Equal("replicapool"))
+		Expect(usage.Pools[1].MaxAvailable.Equal(resource.MustParse("10Gi"))).To(BeTrue())
+		Expect(usage.Pools[1].Quota.Equal(resource.MustParse("1Gi"))).To(BeTrue())
+		percent, ok := usage.Pools[1].QuotaUsedPercent()
+		Expect(ok).To(BeTrue())
+		Expect(percent).To(BeEquivalentTo(79))
+
+		Expect(usage.Osds).NotTo(BeNil())
+		Expect(usage.Osds.Total).To(BeEquivalentTo(3))
+		Expect(usage.Osds.Up).To(BeEquivalentTo(2))
+		Expect(usage.Osds.In).To(BeEquivalentTo(1))
+
+		Expect(*usage.Pgs).To(Equal(koapi.CephPgCounts{Total: 33, Degraded: 4, Backfilling: 2}))
+	})
+
+	It("Should not report osds without osd metrics", func() {
+		usage, err := ParseCephUsage(strings.NewReader(""))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(usage.Pools).To(BeEmpty())
+		Expect(usage.Osds).To(BeNil())
+		Expect(usage.Pgs).To(BeNil())
+	})
+})
@@ -0,0 +1,96 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// The label of the toolbox pods created by the rook-ceph-cluster chart
+const toolboxSelector = "app=rook-ceph-tools"
+
+// CephToolbox runs ceph commands in the rook toolbox
+type CephToolbox interface {
+	// Exec runs the command in the toolbox of the namespace and returns its standard output.
+	// The command reads secrets from stdin, which may be nil, so that they do not show up in its arguments.
+	Exec(ctx context.Context, namespace string, stdin io.Reader, command ...string) (string, error)
+}
+
+func NewCephToolbox(config *rest.Config) CephToolbox {
+	return &cephToolboxClient{config: config}
+}
+
+type cephToolboxClient struct {
+	config *rest.Config
+}
+
+func (tc *cephToolboxClient) Exec(ctx context.Context, namespace string, stdin io.Reader, command ...string) (string, error) {
+	clientset, err := kubernetes.NewForConfig(tc.config)
+	if err != nil {
+		return "", err
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: toolboxSelector})
+	if err != nil {
+		return "", fmt.Errorf("listing toolbox pods failed: %w", err)
+	}
+	var toolbox *corev1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning && pods.Items[i].DeletionTimestamp == nil {
+			toolbox = &pods.Items[i]
+			break
+		}
+	}
+	if toolbox == nil {
+		return "", fmt.Errorf("no ceph toolbox is running in namespace %s", namespace)
+	}
+
+	req := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(toolbox.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Command: command,
+			Stdin:   stdin != nil,
+			Stdout:  true,
+			Stderr:  true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(tc.config, "POST", req.URL())
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdin: stdin, Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		return "", fmt.Errorf("command %q failed: %w: %s", strings.Join(command, " "), err,
+			strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+)
+
+// Image is a parsed container image reference
+type Image struct {
+	// The image name including the registry, e.g. quay.io/ceph/ceph
+	Name string
+	// The image tag, empty if the reference has none
+	Tag string
+	// The image digest, empty if the reference has none
+	Digest string
+}
+
+// ParseImage parses a container image reference such as
+// "registry:5000/ceph/ceph:v17.2.6@sha256:...".
+func ParseImage(image string) (*Image, error) {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not parse image %q", image)
+	}
+
+	named, ok := ref.(reference.Named)
+	if !ok {
+		return nil, errors.Errorf("Image %q has no name", image)
+	}
+
+	result := &Image{Name: named.Name()}
+	if tagged, ok := ref.(reference.Tagged); ok {
+		result.Tag = tagged.Tag()
+	}
+	if digested, ok := ref.(reference.Digested); ok {
+		result.Digest = digested.Digest().String()
+	}
+	return result, nil
+}
+
+// Version returns the tag of the image, falling back to the digest
+func (i *Image) Version() string {
+	if i.Tag != "" {
+		return i.Tag
+	}
+	return i.Digest
+}
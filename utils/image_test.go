@@ -0,0 +1,55 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseImage", func() {
+	const digest = "sha256:0a6ec45d3b5b4a43e6a3a3ec7a5a2b0b8b6c0f0c0a4dbd0f5b6e1f2f3a4b5c6d"
+
+	DescribeTable("Should parse image references",
+		func(image string, expected Image) {
+			parsed, err := ParseImage(image)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*parsed).To(Equal(expected))
+		},
+		Entry("name and tag", "quay.io/ceph/ceph:v17.2.6",
+			Image{Name: "quay.io/ceph/ceph", Tag: "v17.2.6"}),
+		Entry("registry with port", "registry.local:5000/ceph/ceph:v17.2.6",
+			Image{Name: "registry.local:5000/ceph/ceph", Tag: "v17.2.6"}),
+		Entry("digest only", "quay.io/ceph/ceph@"+digest,
+			Image{Name: "quay.io/ceph/ceph", Digest: digest}),
+		Entry("tag and digest", "registry.local:5000/ceph/ceph:v17.2.6@"+digest,
+			Image{Name: "registry.local:5000/ceph/ceph", Tag: "v17.2.6", Digest: digest}),
+		Entry("no tag", "ceph/ceph",
+			Image{Name: "ceph/ceph"}),
+	)
+
+	It("Should reject malformed references", func() {
+		_, err := ParseImage("quay.io/ceph/ceph:v17.2.6:extra")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should fall back to the digest as the version", func() {
+		parsed, err := ParseImage("quay.io/ceph/ceph@" + digest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.Version()).To(Equal(digest))
+	})
+})
@@ -0,0 +1,198 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification is a message about an event of a KoorCluster
+type Notification struct {
+	// The name of the KoorCluster
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	// The event, e.g. UpgradeAvailable
+	Event string `json:"event"`
+	// info, warning or critical
+	Severity string    `json:"severity"`
+	Summary  string    `json:"summary"`
+	Time     time.Time `json:"time"`
+}
+
+func (n *Notification) title() string {
+	return fmt.Sprintf("[%s] KoorCluster %s/%s: %s", n.Severity, n.Namespace, n.Cluster, n.Event)
+}
+
+// SMTPServer is the server and the credentials to send emails with
+type SMTPServer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Notifier delivers notifications to the sinks of a KoorCluster
+type Notifier interface {
+	// Webhook posts the notification as JSON
+	Webhook(ctx context.Context, url string, notification *Notification) error
+	// Slack posts the notification to a Slack-compatible incoming webhook
+	Slack(ctx context.Context, url string, channel string, notification *Notification) error
+	// Email sends the notification through the SMTP server
+	Email(ctx context.Context, server SMTPServer, from string, to []string, notification *Notification) error
+	// Alertmanager posts the notification as an alert to the v2 API of Alertmanager
+	Alertmanager(ctx context.Context, url string, notification *Notification) error
+}
+
+func NewNotifier() Notifier {
+	return &notifier{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		timeout: 30 * time.Second,
+	}
+}
+
+type notifier struct {
+	client *http.Client
+	// The timeout of an email delivery
+	timeout time.Duration
+}
+
+func (n *notifier) Webhook(ctx context.Context, url string, notification *Notification) error {
+	return n.postJSON(ctx, url, notification)
+}
+
+func (n *notifier) Slack(ctx context.Context, url string, channel string, notification *Notification) error {
+	message := map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", notification.title(), notification.Summary),
+	}
+	if channel != "" {
+		message["channel"] = channel
+	}
+	return n.postJSON(ctx, url, message)
+}
+
+func (n *notifier) Alertmanager(ctx context.Context, url string, notification *Notification) error {
+	alerts := []map[string]any{{
+		"labels": map[string]string{
+			"alertname":   "Koor" + notification.Event,
+			"severity":    notification.Severity,
+			"namespace":   notification.Namespace,
+			"koorcluster": notification.Cluster,
+		},
+		"annotations": map[string]string{
+			"summary": notification.Summary,
+		},
+		"startsAt": notification.Time.UTC().Format(time.RFC3339),
+	}}
+	return n.postJSON(ctx, strings.TrimSuffix(url, "/")+"/api/v2/alerts", alerts)
+}
+
+func (n *notifier) postJSON(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("request failed with status %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+func (n *notifier) Email(ctx context.Context, server SMTPServer, from string, to []string, notification *Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	address := net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, server.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: server.Host}); err != nil {
+			return err
+		}
+	}
+	if server.Username != "" {
+		// PlainAuth refuses to send the password without TLS, except to localhost
+		if err := c.Auth(smtp.PlainAuth("", server.Username, server.Password, server.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := c.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(emailMessage(from, to, notification)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailMessage formats the notification as a plain text email
+func emailMessage(from string, to []string, notification *Notification) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", notification.title())
+	fmt.Fprintf(&b, "Date: %s\r\n", notification.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(notification.Summary, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
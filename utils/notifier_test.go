@@ -0,0 +1,111 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Notifier", func() {
+	notification := &Notification{
+		Cluster:   "koor",
+		Namespace: "rook-ceph",
+		Event:     "HealthDegraded",
+		Severity:  "warning",
+		Summary:   "Ceph reports HEALTH_WARN",
+		Time:      time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	// receive starts a server that stores the path and the JSON body of the request
+	receive := func(status int, path *string, body any) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.Method).To(Equal(http.MethodPost))
+			Expect(r.Header.Get("Content-Type")).To(Equal("application/json"))
+			*path = r.URL.Path
+			data, _ := io.ReadAll(r.Body)
+			Expect(json.Unmarshal(data, body)).To(Succeed())
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("done"))
+		}))
+		DeferCleanup(server.Close)
+		return server
+	}
+
+	It("Should post the notification to a webhook", func() {
+		var path string
+		body := map[string]any{}
+		server := receive(http.StatusOK, &path, &body)
+		Expect(NewNotifier().Webhook(context.Background(), server.URL+"/hook", notification)).To(Succeed())
+		Expect(path).To(Equal("/hook"))
+		Expect(body).To(Equal(map[string]any{
+			"cluster":   "koor",
+			"namespace": "rook-ceph",
+			"event":     "HealthDegraded",
+			"severity":  "warning",
+			"summary":   "Ceph reports HEALTH_WARN",
+			"time":      "2023-10-01T12:00:00Z",
+		}))
+	})
+
+	It("Should post a Slack message", func() {
+		var path string
+		body := map[string]any{}
+		server := receive(http.StatusOK, &path, &body)
+		Expect(NewNotifier().Slack(context.Background(), server.URL, "#storage", notification)).To(Succeed())
+		Expect(body).To(Equal(map[string]any{
+			"channel": "#storage",
+			"text":    "*[warning] KoorCluster rook-ceph/koor: HealthDegraded*\nCeph reports HEALTH_WARN",
+		}))
+	})
+
+	It("Should post an alert to Alertmanager", func() {
+		var path string
+		body := []map[string]any{}
+		server := receive(http.StatusOK, &path, &body)
+		Expect(NewNotifier().Alertmanager(context.Background(), server.URL+"/", notification)).To(Succeed())
+		Expect(path).To(Equal("/api/v2/alerts"))
+		Expect(body).To(HaveLen(1))
+		Expect(body[0]["labels"]).To(HaveKeyWithValue("alertname", "KoorHealthDegraded"))
+		Expect(body[0]["labels"]).To(HaveKeyWithValue("koorcluster", "koor"))
+		Expect(body[0]).To(HaveKeyWithValue("startsAt", "2023-10-01T12:00:00Z"))
+	})
+
+	It("Should fail on error responses", func() {
+		var path string
+		body := map[string]any{}
+		server := receive(http.StatusBadRequest, &path, &body)
+		err := NewNotifier().Webhook(context.Background(), server.URL, notification)
+		Expect(err).To(MatchError("request failed with status 400 Bad Request: done"))
+	})
+
+	It("Should format the email", func() {
+		message := string(emailMessage("ceph@example.com", []string{"ops@example.com", "oncall@example.com"}, notification))
+		Expect(message).To(HavePrefix("From: ceph@example.com\r\nTo: ops@example.com, oncall@example.com\r\n" +
+			"Subject: [warning] KoorCluster rook-ceph/koor: HealthDegraded\r\n"))
+		Expect(strings.HasSuffix(message, "\r\n\r\nCeph reports HEALTH_WARN\r\n")).To(BeTrue())
+	})
+})
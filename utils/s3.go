@@ -0,0 +1,184 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The region is not checked by the ceph object gateway, but it is part of the signature
+const s3Region = "us-east-1"
+
+// S3Credentials are the keys of an object store user
+type S3Credentials struct {
+	AccessKey string
+	SecretKey string
+}
+
+// S3Client verifies object stores through their S3 api
+type S3Client interface {
+	// RoundTrip writes an object to the bucket, reads it back and removes it again.
+	// The bucket is created if it does not exist.
+	RoundTrip(ctx context.Context, endpoint string, credentials S3Credentials, bucket string) error
+}
+
+func NewS3Client() S3Client {
+	return &s3Client{
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type s3Client struct {
+	client *http.Client
+}
+
+func (sc *s3Client) RoundTrip(ctx context.Context, endpoint string, credentials S3Credentials, bucket string) error {
+	data := make([]byte, 64*1024)
+	if _, err := rand.Read(data); err != nil {
+		return err
+	}
+	bucketPath := "/" + url.PathEscape(bucket)
+	objectPath := bucketPath + "/koor-smoke-test"
+
+	// Creating an existing bucket of the same user succeeds
+	if _, err := sc.do(ctx, http.MethodPut, endpoint, bucketPath, credentials, nil); err != nil {
+		return fmt.Errorf("creating bucket %s failed: %w", bucket, err)
+	}
+	if _, err := sc.do(ctx, http.MethodPut, endpoint, objectPath, credentials, data); err != nil {
+		return fmt.Errorf("writing object failed: %w", err)
+	}
+	read, err := sc.do(ctx, http.MethodGet, endpoint, objectPath, credentials, nil)
+	if err != nil {
+		return fmt.Errorf("reading object failed: %w", err)
+	}
+	if !bytes.Equal(read, data) {
+		return fmt.Errorf("the object read from bucket %s differs from the object written", bucket)
+	}
+	if _, err := sc.do(ctx, http.MethodDelete, endpoint, objectPath, credentials, nil); err != nil {
+		return fmt.Errorf("deleting object failed: %w", err)
+	}
+	return nil
+}
+
+func (sc *s3Client) do(
+	ctx context.Context,
+	method, endpoint, path string,
+	credentials S3Credentials,
+	body []byte,
+) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	SignS3Request(req, body, credentials, time.Now())
+
+	resp, err := sc.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to endpoint %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, respBody)
+	}
+	return respBody, nil
+}
+
+// SignS3Request adds an AWS signature version 4 to the request
+func SignS3Request(req *http.Request, body []byte, credentials S3Credentials, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	req.Header.Set("x-amz-date", amzDate)
+
+	// Query parameters are sorted and always have a value, e.g. "?lifecycle" becomes "lifecycle="
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var canonicalQuery []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			canonicalQuery = append(canonicalQuery, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+
+	canonicalURI := req.URL.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		strings.Join(canonicalQuery, "&"),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s3Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretKey), date)
+	key = hmacSHA256(key, s3Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -18,59 +18,429 @@ package utils
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
 	"connectrpc.com/connect"
+	"github.com/Masterminds/semver/v3"
 	koapi "github.com/koor-tech/koor-operator/api/v1alpha1"
-	vsapi "github.com/koor-tech/version-service/api/v1"
 	"github.com/koor-tech/version-service/api/v1/apiv1connect"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type VersionService interface {
-	LatestVersions(ctx context.Context, endpoint string,
+	LatestVersions(ctx context.Context, config VersionServiceConfig,
 		versions *koapi.ProductVersions) (*koapi.DetailedProductVersions, error)
 }
 
+// VersionServiceConfig configures the connection to the version service
+type VersionServiceConfig struct {
+	Endpoint string
+	// The timeout of a single request, defaults to 30 seconds
+	Timeout time.Duration
+	// How often a failed request is retried
+	Retries int
+	// PEM encoded certificates that verify the server in addition to the system roots
+	CABundle []byte
+	// The PEM encoded client certificate and key for mutual TLS
+	ClientCert []byte
+	ClientKey  []byte
+	// The proxy to connect through. The proxy of the environment is used if nil.
+	Proxy *url.URL
+	// The key that signs the responses
+	PublicKey crypto.PublicKey
+	// Accepts responses without a valid signature instead of rejecting them
+	AllowUnsigned bool
+}
+
+// The header with the base64 encoded signature of the response body
+const VersionSignatureHeader = "Koor-Signature"
+
+// ErrUnverified is returned for responses and catalogs without a valid signature
+var ErrUnverified = errors.New("the signature is missing or invalid")
+
+// ParsePublicKey parses a PEM encoded Ed25519 or ECDSA public key
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("the public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	switch key.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T, use Ed25519 or ECDSA", key)
+}
+
+// VerifySignature verifies the base64 encoded signature of the data. ECDSA signatures are
+// ASN.1 encoded signatures of the SHA-256 hash, like the signatures of cosign sign-blob.
+func VerifySignature(key crypto.PublicKey, data []byte, signature string) error {
+	if key == nil {
+		return fmt.Errorf("%w: no public key", ErrUnverified)
+	}
+	if signature == "" {
+		return ErrUnverified
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnverified, err)
+	}
+	valid := false
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, data, decoded)
+	case *ecdsa.PublicKey:
+		hash := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(key, hash[:], decoded)
+	}
+	if !valid {
+		return ErrUnverified
+	}
+	return nil
+}
+
+// ParseCatalog returns the versions of an offline catalog, which has the format of a response of the
+// version service. The catalog must be signed with the key of the config unless it allows unsigned catalogs.
+func ParseCatalog(data []byte, signature string, config VersionServiceConfig) (*koapi.DetailedProductVersions, error) {
+	if !config.AllowUnsigned {
+		if err := VerifySignature(config.PublicKey, data, signature); err != nil {
+			return nil, fmt.Errorf("rejected the catalog: %w", err)
+		}
+	}
+	catalog := &operatorResponse{}
+	if err := json.Unmarshal(data, catalog); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %w", err)
+	}
+	latestVersions, err := convertOperatorResponse(catalog)
+	if err != nil {
+		return nil, fmt.Errorf("invalid catalog: %w", err)
+	}
+	return latestVersions, nil
+}
+
+const defaultVersionServiceTimeout = 30 * time.Second
+
 func NewVersionServiceClient() VersionService {
-	return &versionServiceClient{}
+	return &versionServiceClient{
+		retryInterval:    time.Second,
+		maxRetryInterval: 30 * time.Second,
+	}
+}
+
+type versionServiceClient struct {
+	// The delay before the first retry, which doubles with every retry
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+}
+
+// The messages of the version service api. They mirror github.com/koor-tech/version-service/api/v1
+// but are exchanged as JSON, so fields added to the service after that api module are not dropped.
+type operatorRequest struct {
+	Versions *productVersions `json:"versions"`
+}
+
+type productVersions struct {
+	Kube         string `json:"kube,omitempty"`
+	KoorOperator string `json:"koorOperator,omitempty"`
+	Ksd          string `json:"ksd,omitempty"`
+	Ceph         string `json:"ceph,omitempty"`
+}
+
+type operatorResponse struct {
+	Versions *detailedProductVersions `json:"versions"`
+}
+
+type detailedProductVersions struct {
+	KoorOperator *detailedVersion `json:"koorOperator"`
+	Ksd          *detailedVersion `json:"ksd"`
+	Ceph         *detailedVersion `json:"ceph"`
+	// The end of life dates of the requested versions
+	CurrentEndOfLife *endOfLifeDates `json:"currentEndOfLife"`
 }
 
-type versionServiceClient struct{}
+type detailedVersion struct {
+	Version          string   `json:"version"`
+	ImageUri         string   `json:"imageUri"`
+	ImageHash        string   `json:"imageHash"`
+	HelmRepository   string   `json:"helmRepository"`
+	HelmChart        string   `json:"helmChart"`
+	HelmChartVersion string   `json:"helmChartVersion"`
+	ReleaseNotesUrl  string   `json:"releaseNotesUrl"`
+	Severity         string   `json:"severity"`
+	Advisories       []string `json:"advisories"`
+	EndOfLife        string   `json:"endOfLife"`
+}
+
+// endOfLifeDates are dates like 2024-06-30 or RFC 3339 timestamps
+type endOfLifeDates struct {
+	KoorOperator string `json:"koorOperator"`
+	Ksd          string `json:"ksd"`
+	Ceph         string `json:"ceph"`
+}
 
-func (vc *versionServiceClient) LatestVersions(ctx context.Context, endpoint string,
+// jsonCodec uses the connect protocol with JSON payloads. It keeps the last
+// payload it decoded, so that the signature of the response can be verified.
+type jsonCodec struct {
+	payload []byte
+}
+
+func (*jsonCodec) Name() string {
+	return "json"
+}
+
+func (*jsonCodec) Marshal(msg any) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (c *jsonCodec) Unmarshal(data []byte, msg any) error {
+	c.payload = slices.Clone(data)
+	return json.Unmarshal(data, msg)
+}
+
+func (vc *versionServiceClient) LatestVersions(ctx context.Context, config VersionServiceConfig,
 	versions *koapi.ProductVersions) (*koapi.DetailedProductVersions, error) {
 	if versions == nil {
 		return nil, fmt.Errorf("current versions is empty")
 	}
-	client := apiv1connect.NewVersionServiceClient(
-		http.DefaultClient,
-		endpoint,
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("the endpoint %q is not an absolute http or https URL", config.Endpoint)
+	}
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	codec := &jsonCodec{}
+	client := connect.NewClient[operatorRequest, operatorResponse](
+		httpClient,
+		strings.TrimSuffix(config.Endpoint, "/")+apiv1connect.VersionServiceOperatorProcedure,
+		connect.WithCodec(codec),
 	)
-	resp, err := client.Operator(ctx, connect.NewRequest(&vsapi.OperatorRequest{
-		Versions: &vsapi.ProductVersions{
+	request := &operatorRequest{
+		Versions: &productVersions{
 			KoorOperator: versions.KoorOperator,
 			Ksd:          versions.Ksd,
 			Ceph:         versions.Ceph,
 		},
-	}))
-	if err != nil {
-		return nil, fmt.Errorf("connecting to endpoint %s failed: %w", endpoint, err)
 	}
-	latestVersions := &koapi.DetailedProductVersions{
-		KoorOperator: convertDetailedVersion(resp.Msg.Versions.KoorOperator),
-		Ksd:          convertDetailedVersion(resp.Msg.Versions.Ksd),
-		Ceph:         convertDetailedVersion(resp.Msg.Versions.Ceph),
+
+	backoff := vc.retryInterval
+	for attempt := 0; ; attempt++ {
+		resp, err := client.CallUnary(ctx, connect.NewRequest(request))
+		if err == nil {
+			if !config.AllowUnsigned {
+				if err := VerifySignature(config.PublicKey, codec.payload, resp.Header().Get(VersionSignatureHeader)); err != nil {
+					return nil, fmt.Errorf("rejected the response from endpoint %s: %w", config.Endpoint, err)
+				}
+			}
+			latestVersions, err := convertOperatorResponse(resp.Msg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid response from endpoint %s: %w", config.Endpoint, err)
+			}
+			return latestVersions, nil
+		}
+		if attempt >= config.Retries || !isRetryable(err) {
+			return nil, fmt.Errorf("connecting to endpoint %s failed: %w", config.Endpoint, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("connecting to endpoint %s failed: %w", config.Endpoint, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, vc.maxRetryInterval)
+	}
+}
+
+// newHTTPClient returns a client with the timeout, TLS and proxy settings of the config
+func newHTTPClient(config VersionServiceConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if config.Proxy != nil {
+		transport.Proxy = http.ProxyURL(config.Proxy)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(config.CABundle) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(config.CABundle) {
+			return nil, errors.New("the CA bundle contains no PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(config.ClientCert) > 0 || len(config.ClientKey) > 0 {
+		certificate, err := tls.X509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultVersionServiceTimeout
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// isRetryable returns true for errors that may go away on their own, like network errors
+func isRetryable(err error) bool {
+	switch connect.CodeOf(err) {
+	case connect.CodeUnavailable, connect.CodeDeadlineExceeded, connect.CodeResourceExhausted,
+		connect.CodeAborted, connect.CodeUnknown, connect.CodeInternal:
+		return true
+	}
+	return false
+}
+
+// convertOperatorResponse validates the response and converts it to the versions of the api
+func convertOperatorResponse(resp *operatorResponse) (*koapi.DetailedProductVersions, error) {
+	if resp == nil || resp.Versions == nil {
+		return nil, errors.New("the response has no versions")
+	}
+	versions := resp.Versions
+	if versions.KoorOperator == nil && versions.Ksd == nil && versions.Ceph == nil {
+		return nil, errors.New("the response has no versions")
+	}
+	var errs []error
+	latestVersions := &koapi.DetailedProductVersions{}
+	for _, product := range []struct {
+		name    string
+		version *detailedVersion
+		target  **koapi.DetailedVersion
+	}{
+		{"koorOperator", versions.KoorOperator, &latestVersions.KoorOperator},
+		{"ksd", versions.Ksd, &latestVersions.Ksd},
+		{"ceph", versions.Ceph, &latestVersions.Ceph},
+	} {
+		if product.version == nil {
+			continue
+		}
+		if err := validateDetailedVersion(product.version); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", product.name, err))
+			continue
+		}
+		*product.target = convertDetailedVersion(product.version)
+	}
+	if eol := versions.CurrentEndOfLife; eol != nil {
+		latestVersions.CurrentEndOfLife = &koapi.EndOfLifeDates{}
+		for _, product := range []struct {
+			name   string
+			date   string
+			target **metav1.Time
+		}{
+			{"koorOperator", eol.KoorOperator, &latestVersions.CurrentEndOfLife.KoorOperator},
+			{"ksd", eol.Ksd, &latestVersions.CurrentEndOfLife.Ksd},
+			{"ceph", eol.Ceph, &latestVersions.CurrentEndOfLife.Ceph},
+		} {
+			date, err := parseDate(product.date)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("currentEndOfLife.%s: %w", product.name, err))
+				continue
+			}
+			*product.target = date
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 	return latestVersions, nil
 }
 
-func convertDetailedVersion(dv *vsapi.DetailedVersion) *koapi.DetailedVersion {
+// parseDate parses a date or an RFC 3339 timestamp. Empty strings are nil.
+func parseDate(value string) (*metav1.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		date, err = time.Parse(time.RFC3339, value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q", value)
+	}
+	return &metav1.Time{Time: date}, nil
+}
+
+func validateDetailedVersion(dv *detailedVersion) error {
+	if _, err := semver.NewVersion(dv.Version); err != nil {
+		return fmt.Errorf("invalid version %q: %w", dv.Version, err)
+	}
+	if dv.ImageUri != "" {
+		if _, err := ParseImage(dv.ImageUri); err != nil {
+			return err
+		}
+	}
+	if dv.ImageHash != "" {
+		hash := strings.TrimPrefix(dv.ImageHash, "sha256:")
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 64 {
+			return fmt.Errorf("invalid image hash %q", dv.ImageHash)
+		}
+	}
+	if err := convertDetailedVersion(dv).VerifyImageDigest(dv.ImageUri); err != nil {
+		return err
+	}
+	if dv.HelmChartVersion != "" {
+		if _, err := semver.NewVersion(dv.HelmChartVersion); err != nil {
+			return fmt.Errorf("invalid helm chart version %q: %w", dv.HelmChartVersion, err)
+		}
+	}
+	if dv.HelmRepository != "" {
+		if u, err := url.Parse(dv.HelmRepository); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid helm repository %q", dv.HelmRepository)
+		}
+	}
+	if dv.ReleaseNotesUrl != "" {
+		if u, err := url.Parse(dv.ReleaseNotesUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid release notes URL %q", dv.ReleaseNotesUrl)
+		}
+	}
+	switch koapi.VersionSeverity(dv.Severity) {
+	case "", koapi.VersionSeverityRoutine, koapi.VersionSeveritySecurity, koapi.VersionSeverityCritical:
+	default:
+		return fmt.Errorf("invalid severity %q", dv.Severity)
+	}
+	if _, err := parseDate(dv.EndOfLife); err != nil {
+		return fmt.Errorf("endOfLife: %w", err)
+	}
+	return nil
+}
+
+func convertDetailedVersion(dv *detailedVersion) *koapi.DetailedVersion {
+	// The date is validated before the conversion
+	endOfLife, _ := parseDate(dv.EndOfLife)
 	return &koapi.DetailedVersion{
-		Version:        dv.Version,
-		ImageUri:       dv.ImageUri,
-		ImageHash:      dv.ImageHash,
-		HelmRepository: dv.HelmRepository,
-		HelmChart:      dv.HelmChart,
+		Version:          dv.Version,
+		ImageUri:         dv.ImageUri,
+		ImageHash:        dv.ImageHash,
+		HelmRepository:   dv.HelmRepository,
+		HelmChart:        dv.HelmChart,
+		HelmChartVersion: dv.HelmChartVersion,
+		ReleaseNotesURL:  dv.ReleaseNotesUrl,
+		Severity:         koapi.VersionSeverity(dv.Severity),
+		Advisories:       dv.Advisories,
+		EndOfLife:        endOfLife,
 	}
 }
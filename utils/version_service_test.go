@@ -0,0 +1,334 @@
+/*
+Copyright 2023 Koor Technologies, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	koapi "github.com/koor-tech/koor-operator/api/v1alpha1"
+)
+
+// selfSignedCertificate returns a PEM encoded client certificate and key
+func selfSignedCertificate() ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "koor-operator"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+var _ = Describe("Version service", func() {
+	const validResponse = `{"versions": {
+		"ksd": {"version": "v1.12.0", "helmRepository": "https://charts.koor.tech/release", "helmChart": "rook-ceph"},
+		"ceph": {"version": "v18.2.0", "imageUri": "quay.io/ceph/ceph:v18.2.0",
+			"imageHash": "0f6fd0e0a06ecab1bc8ed8bd2e4a7c8d10ec4d1d7ca7fd8c79c01c5c7bd7b4a5"}
+	}}`
+	current := &koapi.ProductVersions{Ksd: "v1.11.0", Ceph: "v17.2.6"}
+
+	var (
+		client   *versionServiceClient
+		requests atomic.Int32
+	)
+
+	BeforeEach(func() {
+		client = &versionServiceClient{retryInterval: time.Millisecond, maxRetryInterval: time.Millisecond}
+		requests.Store(0)
+	})
+
+	// respond answers the requests with the statuses in order, the last one repeats, and the body on success
+	respond := func(body string, statuses ...int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			n := int(requests.Add(1))
+			status := statuses[min(n, len(statuses))-1]
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			if status == http.StatusOK {
+				_, _ = w.Write([]byte(body))
+			}
+		}
+	}
+
+	It("Should return the validated versions", func() {
+		server := httptest.NewServer(respond(validResponse, http.StatusOK))
+		DeferCleanup(server.Close)
+		versions, err := client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: server.URL, AllowUnsigned: true}, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions.KoorOperator).To(BeNil())
+		Expect(versions.Ksd.Version).To(Equal("v1.12.0"))
+		Expect(versions.Ceph.Version).To(Equal("v18.2.0"))
+	})
+
+	It("Should return the release notes, advisories and end of life dates", func() {
+		response := `{"versions": {
+			"ceph": {"version": "v18.2.1", "releaseNotesUrl": "https://docs.ceph.com/en/latest/releases/reef/#v18-2-1-reef",
+				"severity": "Critical", "advisories": ["CVE-2023-43040"], "endOfLife": "2025-08-01"},
+			"currentEndOfLife": {"ksd": "2024-06-30", "ceph": "2024-07-31T00:00:00Z"}
+		}}`
+		server := httptest.NewServer(respond(response, http.StatusOK))
+		DeferCleanup(server.Close)
+		versions, err := client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: server.URL, AllowUnsigned: true}, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions.Ceph.ReleaseNotesURL).To(Equal("https://docs.ceph.com/en/latest/releases/reef/#v18-2-1-reef"))
+		Expect(versions.Ceph.Severity).To(Equal(koapi.VersionSeverityCritical))
+		Expect(versions.Ceph.IsSecurityUpdate()).To(BeTrue())
+		Expect(versions.Ceph.Advisories).To(ConsistOf("CVE-2023-43040"))
+		Expect(versions.Ceph.EndOfLife.Time).To(Equal(time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)))
+		Expect(versions.CurrentEndOfLife.KoorOperator).To(BeNil())
+		Expect(versions.CurrentEndOfLife.Ksd.Time).To(Equal(time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)))
+		Expect(versions.CurrentEndOfLife.Ceph.Time).To(Equal(time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)))
+	})
+
+	DescribeTable("Should reject invalid responses",
+		func(body string, message string) {
+			server := httptest.NewServer(respond(body, http.StatusOK))
+			DeferCleanup(server.Close)
+			_, err := client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: server.URL, AllowUnsigned: true}, current)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("without versions", `{}`, "the response has no versions"),
+		Entry("with empty versions", `{"versions": {}}`, "the response has no versions"),
+		Entry("with an invalid version", `{"versions": {"ceph": {"version": "latest"}}}`, `ceph: invalid version "latest"`),
+		Entry("with an invalid hash", `{"versions": {"ceph": {"version": "v18.2.0", "imageHash": "abc"}}}`, `ceph: invalid image hash "abc"`),
+		Entry("with an invalid image", `{"versions": {"ceph": {"version": "v18.2.0", "imageUri": "Quay.io/Ceph"}}}`, "Could not parse image"),
+		Entry("with an invalid severity", `{"versions": {"ceph": {"version": "v18.2.0", "severity": "urgent"}}}`, `ceph: invalid severity "urgent"`),
+		Entry("with an invalid release notes URL", `{"versions": {"ksd": {"version": "v1.12.0", "releaseNotesUrl": "notes.md"}}}`,
+			`ksd: invalid release notes URL "notes.md"`),
+		Entry("with an invalid end of life", `{"versions": {"ceph": {"version": "v18.2.0", "endOfLife": "soon"}}}`, `ceph: endOfLife: invalid date "soon"`),
+		Entry("with an invalid current end of life", `{"versions": {"ceph": {"version": "v18.2.0"}, "currentEndOfLife": {"ksd": "06/30/2024"}}}`,
+			`currentEndOfLife.ksd: invalid date "06/30/2024"`),
+	)
+
+	It("Should retry unavailable servers with a backoff", func() {
+		server := httptest.NewServer(respond(validResponse, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK))
+		DeferCleanup(server.Close)
+		_, err := client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: server.URL, AllowUnsigned: true, Retries: 3}, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests.Load()).To(BeEquivalentTo(3))
+
+		requests.Store(0)
+		_, err = client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: server.URL, AllowUnsigned: true, Retries: 1}, current)
+		Expect(err).To(HaveOccurred())
+		Expect(requests.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("Should not retry rejected requests", func() {
+		server := httptest.NewServer(respond(validResponse, http.StatusBadRequest))
+		DeferCleanup(server.Close)
+		_, err := client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: server.URL, AllowUnsigned: true, Retries: 3}, current)
+		Expect(err).To(HaveOccurred())
+		Expect(requests.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("Should time out slow requests", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		DeferCleanup(server.Close)
+		_, err := client.LatestVersions(context.Background(),
+			VersionServiceConfig{Endpoint: server.URL, AllowUnsigned: true, Timeout: 20 * time.Millisecond}, current)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should verify the server with the CA bundle and authenticate with the client certificate", func() {
+		clientCert, clientKey := selfSignedCertificate()
+		clientCAs := x509.NewCertPool()
+		Expect(clientCAs.AppendCertsFromPEM(clientCert)).To(BeTrue())
+
+		server := httptest.NewUnstartedServer(respond(validResponse, http.StatusOK))
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+		server.StartTLS()
+		DeferCleanup(server.Close)
+		caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+		_, err := client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: server.URL, AllowUnsigned: true}, current)
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+
+		_, err = client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: server.URL, AllowUnsigned: true, CABundle: caBundle}, current)
+		Expect(err).To(HaveOccurred())
+
+		_, err = client.LatestVersions(context.Background(), VersionServiceConfig{
+			Endpoint:      server.URL,
+			CABundle:      caBundle,
+			ClientCert:    clientCert,
+			ClientKey:     clientKey,
+			AllowUnsigned: true,
+		}, current)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should connect through the proxy", func() {
+		var host string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host = r.URL.Host
+			respond(validResponse, http.StatusOK)(w, r)
+		}))
+		DeferCleanup(proxy.Close)
+		proxyURL, err := url.Parse(proxy.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.LatestVersions(context.Background(),
+			VersionServiceConfig{Endpoint: "http://versions.example.com", AllowUnsigned: true, Proxy: proxyURL}, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(host).To(Equal("versions.example.com"))
+	})
+
+	It("Should reject invalid endpoints and client certificates", func() {
+		_, err := client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: "versions.koor.tech", AllowUnsigned: true}, current)
+		Expect(err).To(MatchError(ContainSubstring("is not an absolute http or https URL")))
+
+		_, err = client.LatestVersions(context.Background(), VersionServiceConfig{
+			Endpoint:   "https://versions.koor.tech",
+			ClientCert: []byte("certificate"),
+		}, current)
+		Expect(err).To(MatchError(ContainSubstring("invalid client certificate")))
+	})
+
+	Context("Signatures", func() {
+		var (
+			publicKey  ed25519.PublicKey
+			privateKey ed25519.PrivateKey
+		)
+
+		BeforeEach(func() {
+			var err error
+			publicKey, privateKey, err = ed25519.GenerateKey(rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		sign := func(data string) string {
+			return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(data)))
+		}
+
+		// signedServer answers with the body and the signature header
+		signedServer := func(body string, signature string) *httptest.Server {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if signature != "" {
+					w.Header().Set(VersionSignatureHeader, signature)
+				}
+				_, _ = w.Write([]byte(body))
+			}))
+			DeferCleanup(server.Close)
+			return server
+		}
+
+		It("Should accept signed responses", func() {
+			server := signedServer(validResponse, sign(validResponse))
+			versions, err := client.LatestVersions(context.Background(),
+				VersionServiceConfig{Endpoint: server.URL, PublicKey: publicKey}, current)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions.Ceph.Version).To(Equal("v18.2.0"))
+		})
+
+		It("Should reject responses without a key", func() {
+			server := signedServer(validResponse, sign(validResponse))
+			_, err := client.LatestVersions(context.Background(), VersionServiceConfig{Endpoint: server.URL}, current)
+			Expect(err).To(MatchError(ErrUnverified))
+		})
+
+		It("Should reject unsigned and tampered responses", func() {
+			server := signedServer(validResponse, "")
+			_, err := client.LatestVersions(context.Background(),
+				VersionServiceConfig{Endpoint: server.URL, PublicKey: publicKey}, current)
+			Expect(err).To(MatchError(ErrUnverified))
+
+			tampered := strings.Replace(validResponse, "v18.2.0", "v18.2.1", 1)
+			server = signedServer(tampered, sign(validResponse))
+			_, err = client.LatestVersions(context.Background(),
+				VersionServiceConfig{Endpoint: server.URL, PublicKey: publicKey}, current)
+			Expect(err).To(MatchError(ErrUnverified))
+		})
+
+		It("Should verify ECDSA signatures of the SHA-256 hash", func() {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			hash := sha256.Sum256([]byte(validResponse))
+			signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+			Expect(err).NotTo(HaveOccurred())
+
+			der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+			Expect(err).NotTo(HaveOccurred())
+			parsed, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(VerifySignature(parsed, []byte(validResponse), base64.StdEncoding.EncodeToString(signature))).To(Succeed())
+			Expect(VerifySignature(parsed, []byte(validResponse), sign(validResponse))).To(MatchError(ErrUnverified))
+		})
+
+		It("Should reject unsupported keys", func() {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			Expect(err).To(MatchError(ContainSubstring("unsupported public key type")))
+		})
+
+		It("Should parse signed catalogs", func() {
+			versions, err := ParseCatalog([]byte(validResponse), sign(validResponse), VersionServiceConfig{PublicKey: publicKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions.Ksd.Version).To(Equal("v1.12.0"))
+
+			_, err = ParseCatalog([]byte(validResponse), "", VersionServiceConfig{PublicKey: publicKey})
+			Expect(err).To(MatchError(ErrUnverified))
+
+			// Catalogs are only accepted without a key if unsigned catalogs are allowed
+			_, err = ParseCatalog([]byte(validResponse), sign(validResponse), VersionServiceConfig{})
+			Expect(err).To(MatchError(ErrUnverified))
+			_, err = ParseCatalog([]byte(validResponse), "", VersionServiceConfig{AllowUnsigned: true})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should reject images pinned to a different digest than the hash", func() {
+			response := `{"versions": {"ceph": {"version": "v18.2.0",
+				"imageUri": "quay.io/ceph/ceph:v18.2.0@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				"imageHash": "0f6fd0e0a06ecab1bc8ed8bd2e4a7c8d10ec4d1d7ca7fd8c79c01c5c7bd7b4a5"}}}`
+			_, err := ParseCatalog([]byte(response), "", VersionServiceConfig{AllowUnsigned: true})
+			Expect(err).To(MatchError(ContainSubstring("does not match the hash")))
+		})
+	})
+})
@@ -20,3 +20,6 @@ import "embed"
 
 //go:embed *.yaml
 var Templates embed.FS
+
+//go:embed dashboards/*.json
+var Dashboards embed.FS